@@ -0,0 +1,275 @@
+// Package chanmatch matches the send and receive statements of a MiGo
+// program which may communicate, i.e. act on the same channel.
+//
+// Channels are identified by their creation site (a let newchan statement)
+// or their free name, and tracked through the parameters of calls and
+// spawns by package pointsto. A statement acts on every channel its name
+// may denote, in any context.
+//
+// The analysis is flow-insensitive: a send and a receive on the same
+// channel may pair even if they are never ready at the same time.
+package chanmatch
+
+import (
+	"fmt"
+	"go/token"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/analysis/pointsto"
+)
+
+// Channel is a channel of a program, i.e. a creation site or a free name,
+// as found by the points-to analysis.
+type Channel = pointsto.Site
+
+// Unmatched is a send or receive which can never communicate.
+type Unmatched struct {
+	Stmt migo.Statement // *migo.SendStatement or *migo.RecvStatement.
+	Func string         // Function containing Stmt.
+	Chan *Channel       // Channel acted on.
+}
+
+func (u Unmatched) String() string {
+	if send, ok := u.Stmt.(*migo.SendStatement); ok {
+		return fmt.Sprintf("%s: send %s is never received (channel %s)", u.Func, send.Chan, u.Chan)
+	}
+	return fmt.Sprintf("%s: recv %s is never sent to or closed (channel %s)", u.Func, u.Stmt.(*migo.RecvStatement).Chan, u.Chan)
+}
+
+// Result is the result of the analysis.
+type Result struct {
+	// Sends are the sends which may pair with each receive reachable from
+	// the entry function, including receive cases of select.
+	Sends map[*migo.RecvStatement][]*migo.SendStatement
+	// Recvs are the receives which may pair with each send reachable from
+	// the entry function, including send cases of select.
+	Recvs map[*migo.SendStatement][]*migo.RecvStatement
+	// Chans are the channels each send or receive may act on.
+	Chans map[migo.Statement][]*Channel
+	// Unmatched are the sends without receives, and the receives without
+	// sends on channels which are never closed.
+	Unmatched []Unmatched
+}
+
+// Annotate sets the Sends of each receive in the result to the positions
+// of the sends which may pair with it. Match annotates its result, so this
+// is only needed to restore Sends after they are changed.
+func (r *Result) Annotate() {
+	for recv, sends := range r.Sends {
+		recv.Sends = nil
+		seen := make(map[token.Position]bool)
+		for _, send := range sends {
+			if !seen[send.Pos] {
+				seen[send.Pos] = true
+				recv.Sends = append(recv.Sends, send.Pos)
+			}
+		}
+	}
+}
+
+// Match matches the sends and receives of Program prog reachable from the
+// entry function, and sets the Sends of each receive to the positions of
+// the sends which may pair with it (see Annotate).
+//
+// Returns a *migo.ErrFuncNotFound error if entry is not in prog, and a
+// *migo.ErrUnsupportedStatement error if prog contains a statement kind
+// that is unknown and not a migo.ExtStatement.
+func Match(prog *migo.Program, entry string) (*Result, error) {
+	// Without return values, the channels a statement may act on in any
+	// context do not depend on the length of contexts.
+	pts, err := pointsto.Analyse(prog, entry, 0)
+	if err != nil {
+		return nil, err
+	}
+	fn, _ := prog.Function(entry)
+	m := &matcher{
+		pts:      pts,
+		visited:  make(map[string]bool),
+		sends:    make(map[*Channel][]*endpoint),
+		recvs:    make(map[*Channel][]*endpoint),
+		closed:   make(map[*Channel]bool),
+		actionOn: make(map[migo.Statement][]*Channel),
+	}
+	m.enter(fn, pointsto.Context{})
+	for len(m.queue) > 0 {
+		var c context
+		c, m.queue = m.queue[0], m.queue[1:]
+		if err := m.visitStmts(c, c.fn.Stmts); err != nil {
+			return nil, err
+		}
+	}
+	res := m.result()
+	res.Annotate()
+	return res, nil
+}
+
+// endpoint is a send or receive in a function.
+type endpoint struct {
+	stmt migo.Statement
+	fn   string
+}
+
+// context is a function in a context of the points-to analysis.
+type context struct {
+	fn  *migo.Function
+	ctx pointsto.Context
+}
+
+type matcher struct {
+	pts      *pointsto.Result
+	visited  map[string]bool // Contexts queued, by function and context.
+	queue    []context
+	sends    map[*Channel][]*endpoint
+	recvs    map[*Channel][]*endpoint
+	closed   map[*Channel]bool
+	actionOn map[migo.Statement][]*Channel
+	order    []*Channel // Channels in order of first action.
+}
+
+// enter queues fn in context ctx, if not already.
+func (m *matcher) enter(fn *migo.Function, ctx pointsto.Context) {
+	key := fn.Name + "|" + ctx.Key()
+	if !m.visited[key] {
+		m.visited[key] = true
+		m.queue = append(m.queue, context{fn: fn, ctx: ctx})
+	}
+}
+
+// action records a send or receive stmt on channel name in c.
+func (m *matcher) action(c context, name string, stmt migo.Statement, to map[*Channel][]*endpoint) {
+	for _, ch := range m.pts.PointsTo(c.fn, c.ctx, name) {
+		if containsChan(m.actionOn[stmt], ch) {
+			continue
+		}
+		if len(m.sends[ch]) == 0 && len(m.recvs[ch]) == 0 {
+			m.order = append(m.order, ch)
+		}
+		m.actionOn[stmt] = append(m.actionOn[stmt], ch)
+		to[ch] = append(to[ch], &endpoint{stmt: stmt, fn: c.fn.Name})
+	}
+}
+
+func containsChan(chans []*Channel, c *Channel) bool {
+	for _, other := range chans {
+		if other == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *matcher) visitStmts(c context, stmts []migo.Statement) error {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *migo.SendStatement:
+			m.action(c, stmt.Chan, stmt, m.sends)
+		case *migo.RecvStatement:
+			m.action(c, stmt.Chan, stmt, m.recvs)
+		case *migo.CloseStatement:
+			for _, ch := range m.pts.PointsTo(c.fn, c.ctx, stmt.Chan) {
+				m.closed[ch] = true
+			}
+		case *migo.CallStatement, *migo.SpawnStatement:
+			if callee, ctx, ok := m.pts.Callee(c.ctx, stmt); ok {
+				m.enter(callee, ctx)
+			}
+		case *migo.IfStatement:
+			if err := m.visitBlocks(c, stmt.Then, stmt.Else); err != nil {
+				return err
+			}
+		case *migo.IfForStatement:
+			if err := m.visitBlocks(c, stmt.Then, stmt.Else); err != nil {
+				return err
+			}
+		case *migo.SelectStatement:
+			if err := m.visitBlocks(c, stmt.Cases...); err != nil {
+				return err
+			}
+		case migo.ExtStatement:
+			if err := m.visitBlocks(c, stmt.Blocks()...); err != nil {
+				return err
+			}
+		case *migo.TauStatement, *migo.NewChanStatement, *migo.NewMem, *migo.MemRead, *migo.MemWrite,
+			*migo.NewSyncMutex, *migo.SyncMutexLock, *migo.SyncMutexUnlock,
+			*migo.NewSyncRWMutex, *migo.SyncRWMutexRLock, *migo.SyncRWMutexRUnlock:
+		default:
+			return &migo.ErrUnsupportedStatement{Func: c.fn.Name, Stmt: stmt}
+		}
+	}
+	return nil
+}
+
+func (m *matcher) visitBlocks(c context, blocks ...[]migo.Statement) error {
+	for _, block := range blocks {
+		if err := m.visitStmts(c, block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *matcher) result() *Result {
+	r := &Result{
+		Sends: make(map[*migo.RecvStatement][]*migo.SendStatement),
+		Recvs: make(map[*migo.SendStatement][]*migo.RecvStatement),
+		Chans: m.actionOn,
+	}
+	var endpoints []*endpoint
+	seen := make(map[migo.Statement]bool)
+	for _, c := range m.order {
+		for _, e := range append(m.sends[c], m.recvs[c]...) {
+			if !seen[e.stmt] {
+				seen[e.stmt] = true
+				endpoints = append(endpoints, e)
+			}
+		}
+		for _, s := range m.sends[c] {
+			send := s.stmt.(*migo.SendStatement)
+			for _, rv := range m.recvs[c] {
+				recv := rv.stmt.(*migo.RecvStatement)
+				r.Recvs[send] = appendRecv(r.Recvs[send], recv)
+				r.Sends[recv] = appendSend(r.Sends[recv], send)
+			}
+		}
+	}
+	for _, e := range endpoints {
+		matched := false
+		switch stmt := e.stmt.(type) {
+		case *migo.SendStatement:
+			if _, ok := r.Recvs[stmt]; !ok {
+				r.Recvs[stmt] = nil
+			}
+			matched = len(r.Recvs[stmt]) > 0
+		case *migo.RecvStatement:
+			if _, ok := r.Sends[stmt]; !ok {
+				r.Sends[stmt] = nil
+			}
+			matched = len(r.Sends[stmt]) > 0
+			for _, c := range m.actionOn[stmt] {
+				matched = matched || m.closed[c]
+			}
+		}
+		if !matched {
+			r.Unmatched = append(r.Unmatched, Unmatched{Stmt: e.stmt, Func: e.fn, Chan: m.actionOn[e.stmt][0]})
+		}
+	}
+	return r
+}
+
+func appendSend(sends []*migo.SendStatement, send *migo.SendStatement) []*migo.SendStatement {
+	for _, s := range sends {
+		if s == send {
+			return sends
+		}
+	}
+	return append(sends, send)
+}
+
+func appendRecv(recvs []*migo.RecvStatement, recv *migo.RecvStatement) []*migo.RecvStatement {
+	for _, r := range recvs {
+		if r == recv {
+			return recvs
+		}
+	}
+	return append(recvs, recv)
+}
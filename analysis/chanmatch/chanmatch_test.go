@@ -0,0 +1,185 @@
+package chanmatch
+
+import (
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+// number gives each send and recv of prog a distinct position, by line.
+func number(prog *migo.Program) {
+	line := 0
+	var number func(stmts []migo.Statement)
+	number = func(stmts []migo.Statement) {
+		for _, stmt := range stmts {
+			line++
+			switch stmt := stmt.(type) {
+			case *migo.SendStatement:
+				stmt.Pos = token.Position{Filename: "x.go", Line: line}
+			case *migo.RecvStatement:
+				stmt.Pos = token.Position{Filename: "x.go", Line: line}
+			case *migo.IfStatement:
+				number(stmt.Then)
+				number(stmt.Else)
+			case *migo.SelectStatement:
+				for _, c := range stmt.Cases {
+					number(c)
+				}
+			}
+		}
+	}
+	for _, fn := range prog.Funcs {
+		number(fn.Stmts)
+	}
+}
+
+// stmts returns the sends and recvs of fn in order.
+func stmts(prog *migo.Program, fn string) []migo.Statement {
+	f, _ := prog.Function(fn)
+	var ss []migo.Statement
+	var walk func(stmts []migo.Statement)
+	walk = func(stmts []migo.Statement) {
+		for _, stmt := range stmts {
+			switch stmt := stmt.(type) {
+			case *migo.SendStatement, *migo.RecvStatement:
+				ss = append(ss, stmt)
+			case *migo.IfStatement:
+				walk(stmt.Then)
+				walk(stmt.Else)
+			case *migo.SelectStatement:
+				for _, c := range stmt.Cases {
+					walk(c)
+				}
+			}
+		}
+	}
+	walk(f.Stmts)
+	return ss
+}
+
+func TestMatch(t *testing.T) {
+	s := `
+def main():
+	let a = newchan a, 0;
+	let b = newchan b, 0;
+	spawn w(a);
+	spawn w(b);
+	recv a;
+	select
+		case recv b;
+		case tau;
+	endselect;
+def w(c):
+	send c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	number(prog)
+	res, err := Match(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, w := stmts(prog, "main"), stmts(prog, "w")
+	recvA, recvB, send := main[0].(*migo.RecvStatement), main[1].(*migo.RecvStatement), w[0].(*migo.SendStatement)
+	for _, recv := range []*migo.RecvStatement{recvA, recvB} {
+		if sends := res.Sends[recv]; len(sends) != 1 || sends[0] != send {
+			t.Errorf("expected %s to match %s but got %v", recv, send, sends)
+		}
+	}
+	if recvs := res.Recvs[send]; len(recvs) != 2 {
+		t.Errorf("expected %s to match both receives but got %v", send, recvs)
+	}
+	if want, got := 2, len(res.Chans[send]); want != got {
+		t.Errorf("expected send on %d channels but got %v", want, res.Chans[send])
+	}
+	if len(res.Unmatched) != 0 {
+		t.Errorf("expected no unmatched statements but got %v", res.Unmatched)
+	}
+
+	// Match annotates the receives with the positions of their sends.
+	if want, got := "recv a (x.go:5) -> (x.go:9)", recvA.String(); want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+}
+
+func TestMatchChannels(t *testing.T) {
+	// Sends on a are not received by receives on b.
+	s := `
+def main():
+	let a = newchan a, 1;
+	let b = newchan b, 0;
+	call s(a);
+	recv b;
+def s(c):
+	send c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	number(prog)
+	res, err := Match(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv, send := stmts(prog, "main")[0], stmts(prog, "s")[0]
+	if len(res.Sends[recv.(*migo.RecvStatement)]) != 0 || len(res.Recvs[send.(*migo.SendStatement)]) != 0 {
+		t.Errorf("expected no matches but got %v and %v", res.Sends, res.Recvs)
+	}
+	if want, got := 2, len(res.Unmatched); want != got {
+		t.Fatalf("expected %d unmatched but got %v", want, res.Unmatched)
+	}
+	for i, want := range []string{
+		"main: recv b is never sent to or closed (channel main.b)",
+		"s: send c is never received (channel main.a)",
+	} {
+		if got := res.Unmatched[i].String(); want != got {
+			t.Errorf("expected %s but got %s", want, got)
+		}
+	}
+}
+
+func TestMatchClosed(t *testing.T) {
+	// The receive on done is matched by the close, and the receive on g
+	// in r does not count, as r is not reachable from main.
+	s := `
+def main():
+	let done = newchan done, 0;
+	spawn loop(done);
+	close done;
+def loop(d):
+	select
+		case recv d;
+		case send g; call loop(d);
+	endselect;
+def r():
+	recv g;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	number(prog)
+	res, err := Match(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(res.Unmatched); want != got {
+		t.Fatalf("expected %d unmatched but got %v", want, res.Unmatched)
+	}
+	if want, got := "loop: send g is never received (channel g)", res.Unmatched[0].String(); want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+}
+
+func TestMatchNotFound(t *testing.T) {
+	prog, _ := parser.Parse(strings.NewReader(`def main(): tau;`))
+	if _, err := Match(prog, "missing"); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,602 @@
+// Package effects summarises the behaviour of each function of a MiGo
+// program on its parameters, so that functions can be reasoned about one
+// at a time rather than by exploring the whole program.
+//
+// The summary of a function says which of its parameters and free names
+// it may send on, receive on, close, lock or unlock, directly or through
+// the functions it calls or spawns, whether it may spawn goroutines,
+// whether it may return or run forever, and the range of the net number
+// of times it locks each name when it returns.
+//
+// Summaries are computed bottom-up over the strongly connected components
+// of the control-flow graph of the program, callees first, iterating each
+// recursive component to a fixpoint. Branches of if, select and extension
+// statements are all taken to be possible, and the analysis does not
+// consider whether a statement may block forever.
+package effects
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/alias"
+	"github.com/JorgeGCoelho/migo/v3/internal/ctrlflow"
+)
+
+// Effect is a set of actions on a name.
+type Effect uint8
+
+// Actions on a name.
+const (
+	Send Effect = 1 << iota
+	Recv
+	Close
+	Lock
+	Unlock
+	RLock
+	RUnlock
+)
+
+var effectNames = []string{"send", "recv", "close", "lock", "unlock", "rlock", "runlock"}
+
+// Has returns true if e includes all the actions of f.
+func (e Effect) Has(f Effect) bool {
+	return e&f == f
+}
+
+func (e Effect) String() string {
+	var names []string
+	for i, name := range effectNames {
+		if e&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// Unbounded is the bound of a Balance which may grow without limit,
+// positive for Max and negative for Min.
+const Unbounded = 1 << 30
+
+// widenAfter is the number of rounds of a recursive component after which
+// growing balances are widened to Unbounded.
+const widenAfter = 3
+
+// Balance is the range of the net number of times a function locks a name
+// (lock and rlock count 1, unlock and runlock count -1) on the executions
+// where it returns.
+type Balance struct {
+	Min, Max int
+}
+
+func (b Balance) String() string {
+	bound := func(n int) string {
+		switch {
+		case n >= Unbounded:
+			return "∞"
+		case n <= -Unbounded:
+			return "-∞"
+		}
+		return fmt.Sprint(n)
+	}
+	if b.Min == b.Max {
+		return bound(b.Min)
+	}
+	return fmt.Sprintf("[%s, %s]", bound(b.Min), bound(b.Max))
+}
+
+func (b Balance) add(c Balance) Balance {
+	return Balance{Min: clamp(b.Min + c.Min), Max: clamp(b.Max + c.Max)}
+}
+
+func (b Balance) join(c Balance) Balance {
+	if c.Min < b.Min {
+		b.Min = c.Min
+	}
+	if c.Max > b.Max {
+		b.Max = c.Max
+	}
+	return b
+}
+
+func clamp(n int) int {
+	switch {
+	case n > Unbounded:
+		return Unbounded
+	case n < -Unbounded:
+		return -Unbounded
+	}
+	return n
+}
+
+// Summary is the summary of the effects of a function.
+type Summary struct {
+	Func *migo.Function
+
+	// Effects are the actions the function may take on each of its
+	// parameters and free names, including through the functions it calls
+	// or spawns. Names without effects are omitted.
+	Effects map[string]Effect
+	// Balance is the lock balance of the names the function may lock or
+	// unlock itself or through the functions it calls, on the executions
+	// where it returns. Goroutines it spawns are not counted.
+	Balance map[string]Balance
+
+	Spawns          bool // May spawn a goroutine, directly or not.
+	MayReturn       bool // Has an execution which returns.
+	MayNotTerminate bool // May recurse through calls forever.
+}
+
+// Effect returns the actions the function may take on name.
+func (s *Summary) Effect(name string) Effect {
+	return s.Effects[name]
+}
+
+// Names returns the names with effects or a balance, parameters first in
+// order, then free names in lexical order.
+func (s *Summary) Names() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, p := range s.Func.Params {
+		name := p.Callee.Name()
+		if !seen[name] && (s.Effects[name] != 0 || hasBalance(s, name)) {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	var free []string
+	for name := range s.Effects {
+		if !seen[name] {
+			seen[name] = true
+			free = append(free, name)
+		}
+	}
+	for name := range s.Balance {
+		if !seen[name] {
+			seen[name] = true
+			free = append(free, name)
+		}
+	}
+	sort.Strings(free)
+	return append(names, free...)
+}
+
+func hasBalance(s *Summary, name string) bool {
+	_, ok := s.Balance[name]
+	return ok
+}
+
+func (s *Summary) String() string {
+	var sb strings.Builder
+	params := make([]string, len(s.Func.Params))
+	for i, p := range s.Func.Params {
+		params[i] = p.Callee.Name()
+	}
+	fmt.Fprintf(&sb, "%s(%s):\n", s.Func.SimpleName(), strings.Join(params, ", "))
+	for _, name := range s.Names() {
+		fmt.Fprintf(&sb, "\t%s: %s", name, s.Effects[name])
+		if b, ok := s.Balance[name]; ok {
+			fmt.Fprintf(&sb, "; balance %s", b)
+		}
+		sb.WriteString("\n")
+	}
+	if s.Spawns {
+		sb.WriteString("\tmay spawn\n")
+	}
+	switch {
+	case !s.MayReturn:
+		sb.WriteString("\tnever returns\n")
+	case s.MayNotTerminate:
+		sb.WriteString("\tmay not terminate\n")
+	}
+	return sb.String()
+}
+
+// Result is the result of the analysis.
+type Result struct {
+	Summaries []*Summary // In the order of the functions of the program.
+
+	byName map[string]*Summary
+}
+
+// Summary returns the summary of the function named fn, or nil if there is
+// no such function.
+func (r *Result) Summary(fn string) *Summary {
+	return r.byName[fn]
+}
+
+// String returns a readable report of the summaries.
+func (r *Result) String() string {
+	var sb strings.Builder
+	for _, s := range r.Summaries {
+		sb.WriteString(s.String())
+	}
+	return sb.String()
+}
+
+// Summarise computes the summaries of the functions of Program prog.
+//
+// Returns a *migo.ErrUnsupportedStatement error if prog contains
+// a statement kind that is unknown and not a migo.ExtStatement.
+func Summarise(prog *migo.Program) (*Result, error) {
+	g, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		return nil, err
+	}
+	a := &analyser{
+		prog:      prog,
+		summaries: make(map[*migo.Function]*Summary),
+		scope:     make(alias.Scope),
+	}
+	for _, scc := range g.SCCs() {
+		if err := a.component(scc); err != nil {
+			return nil, err
+		}
+	}
+	r := &Result{byName: make(map[string]*Summary)}
+	for _, fn := range prog.Funcs {
+		if s, ok := a.summaries[fn]; ok {
+			r.Summaries = append(r.Summaries, s)
+			r.byName[fn.Name] = s
+		}
+	}
+	return r, nil
+}
+
+type analyser struct {
+	prog      *migo.Program
+	summaries map[*migo.Function]*Summary
+	scope     alias.Scope
+}
+
+// component computes the summaries of the functions in a strongly
+// connected component, whose callees outside of it are summarised.
+func (a *analyser) component(scc []*ctrlflow.Node) error {
+	for _, n := range scc {
+		a.summaries[n.Func()] = &Summary{
+			Func:    n.Func(),
+			Effects: make(map[string]Effect),
+			Balance: make(map[string]Balance),
+		}
+	}
+	diverges := divergent(a.prog, scc)
+	for round := 1; ; round++ {
+		changed := false
+		for _, n := range scc {
+			fn := n.Func()
+			s, err := a.summarise(fn)
+			if err != nil {
+				return err
+			}
+			s.MayNotTerminate = s.MayNotTerminate || diverges[fn]
+			if a.update(a.summaries[fn], s, round > widenAfter) {
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+	}
+}
+
+// update joins s into the summary old, widening growing balances if
+// widen, and returns true if old changed.
+func (a *analyser) update(old, s *Summary, widen bool) bool {
+	changed := false
+	for name, e := range s.Effects {
+		if old.Effects[name]|e != old.Effects[name] {
+			old.Effects[name] |= e
+			changed = true
+		}
+	}
+	for name, b := range s.Balance {
+		prev, ok := old.Balance[name]
+		if !ok {
+			old.Balance[name] = b
+			changed = true
+			continue
+		}
+		next := prev.join(b)
+		if widen {
+			if next.Min < prev.Min {
+				next.Min = -Unbounded
+			}
+			if next.Max > prev.Max {
+				next.Max = Unbounded
+			}
+		}
+		if next != prev {
+			old.Balance[name] = next
+			changed = true
+		}
+	}
+	for _, f := range []struct{ old, new *bool }{
+		{&old.Spawns, &s.Spawns},
+		{&old.MayReturn, &s.MayReturn},
+		{&old.MayNotTerminate, &s.MayNotTerminate},
+	} {
+		if *f.new && !*f.old {
+			*f.old = true
+			changed = true
+		}
+	}
+	return changed
+}
+
+// divergent returns the functions of a strongly connected component which
+// may call themselves through calls within it, so may recurse forever.
+func divergent(prog *migo.Program, scc []*ctrlflow.Node) map[*migo.Function]bool {
+	inSCC := make(map[*migo.Function]bool)
+	for _, n := range scc {
+		inSCC[n.Func()] = true
+	}
+	calls := make(map[*migo.Function][]*migo.Function)
+	for fn := range inSCC {
+		forEachCall(fn.Stmts, func(call *migo.CallStatement) {
+			if callee, found := prog.Function(call.Name); found && inSCC[callee] {
+				calls[fn] = append(calls[fn], callee)
+			}
+		})
+	}
+	diverges := make(map[*migo.Function]bool)
+	for fn := range inSCC {
+		seen := make(map[*migo.Function]bool)
+		queue := []*migo.Function{fn}
+		for len(queue) > 0 && !diverges[fn] {
+			f := queue[0]
+			queue = queue[1:]
+			for _, callee := range calls[f] {
+				if callee == fn {
+					diverges[fn] = true
+				}
+				if !seen[callee] {
+					seen[callee] = true
+					queue = append(queue, callee)
+				}
+			}
+		}
+	}
+	return diverges
+}
+
+func forEachCall(stmts []migo.Statement, fn func(*migo.CallStatement)) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *migo.CallStatement:
+			fn(s)
+		case *migo.IfStatement:
+			forEachCall(s.Then, fn)
+			forEachCall(s.Else, fn)
+		case *migo.IfForStatement:
+			forEachCall(s.Then, fn)
+			forEachCall(s.Else, fn)
+		case *migo.SelectStatement:
+			for _, c := range s.Cases {
+				forEachCall(c, fn)
+			}
+		case migo.ExtStatement:
+			for _, b := range s.Blocks() {
+				forEachCall(b, fn)
+			}
+		}
+	}
+}
+
+// path is the abstract state at a point of a function body: whether the
+// point may be reached, and the lock balance of each name so far.
+type path struct {
+	reached bool
+	balance map[string]Balance
+}
+
+func (p path) lock(name string, n int) path {
+	b := p.balance[name]
+	balance := make(map[string]Balance, len(p.balance)+1)
+	for k, v := range p.balance {
+		balance[k] = v
+	}
+	balance[name] = b.add(Balance{Min: n, Max: n})
+	return path{reached: p.reached, balance: balance}
+}
+
+// join returns the state reached by either p or q.
+func join(p, q path) path {
+	switch {
+	case !p.reached:
+		return q
+	case !q.reached:
+		return p
+	}
+	balance := make(map[string]Balance)
+	for name, b := range p.balance {
+		balance[name] = b.join(q.balance[name])
+	}
+	for name, b := range q.balance {
+		if _, ok := p.balance[name]; !ok {
+			balance[name] = b.join(Balance{})
+		}
+	}
+	return path{reached: true, balance: balance}
+}
+
+// summarise computes a summary of fn from the current summaries.
+func (a *analyser) summarise(fn *migo.Function) (*Summary, error) {
+	s := &Summary{
+		Func:    fn,
+		Effects: make(map[string]Effect),
+		Balance: make(map[string]Balance),
+	}
+	end, err := a.visitStmts(fn, s, path{reached: true}, fn.Stmts)
+	if err != nil {
+		return nil, err
+	}
+	if end.reached {
+		s.MayReturn = true
+		for name, b := range end.balance {
+			s.Balance[name] = b
+		}
+	}
+	return s, nil
+}
+
+// visible returns true if name in fn is a parameter or a free name, so
+// its effects are visible to callers.
+func (a *analyser) visible(fn *migo.Function, name string) bool {
+	for _, p := range fn.Params {
+		if p.Callee.Name() == name {
+			return true
+		}
+	}
+	return !a.scope.Bound(fn, name)
+}
+
+func (a *analyser) act(fn *migo.Function, s *Summary, name string, e Effect) {
+	if a.visible(fn, name) {
+		s.Effects[name] |= e
+	}
+}
+
+func (a *analyser) visitStmts(fn *migo.Function, s *Summary, p path, stmts []migo.Statement) (path, error) {
+	for _, stmt := range stmts {
+		if !p.reached {
+			return p, nil
+		}
+		switch stmt := stmt.(type) {
+		case *migo.SendStatement:
+			a.act(fn, s, stmt.Chan, Send)
+		case *migo.RecvStatement:
+			a.act(fn, s, stmt.Chan, Recv)
+		case *migo.CloseStatement:
+			a.act(fn, s, stmt.Chan, Close)
+		case *migo.SyncMutexLock:
+			a.act(fn, s, stmt.Name, Lock)
+			p = a.lock(fn, p, stmt.Name, 1)
+		case *migo.SyncMutexUnlock:
+			a.act(fn, s, stmt.Name, Unlock)
+			p = a.lock(fn, p, stmt.Name, -1)
+		case *migo.SyncRWMutexRLock:
+			a.act(fn, s, stmt.Name, RLock)
+			p = a.lock(fn, p, stmt.Name, 1)
+		case *migo.SyncRWMutexRUnlock:
+			a.act(fn, s, stmt.Name, RUnlock)
+			p = a.lock(fn, p, stmt.Name, -1)
+		case *migo.CallStatement:
+			if callee, found := a.prog.Function(stmt.Name); found {
+				p = a.call(fn, s, p, callee, stmt.Params)
+			}
+		case *migo.SpawnStatement:
+			s.Spawns = true
+			if callee, found := a.prog.Function(stmt.Name); found {
+				a.effects(fn, s, a.summaries[callee], stmt.Params)
+			}
+		case *migo.IfStatement:
+			var err error
+			if p, err = a.visitBlocks(fn, s, p, stmt.Then, stmt.Else); err != nil {
+				return p, err
+			}
+		case *migo.IfForStatement:
+			var err error
+			if p, err = a.visitBlocks(fn, s, p, stmt.Then, stmt.Else); err != nil {
+				return p, err
+			}
+		case *migo.SelectStatement:
+			var err error
+			if p, err = a.visitBlocks(fn, s, p, stmt.Cases...); err != nil {
+				return p, err
+			}
+		case migo.ExtStatement:
+			var err error
+			if p, err = a.visitBlocks(fn, s, p, stmt.Blocks()...); err != nil {
+				return p, err
+			}
+		case *migo.NewChanStatement, *migo.TauStatement, *migo.NewMem, *migo.MemRead, *migo.MemWrite,
+			*migo.NewSyncMutex, *migo.NewSyncRWMutex:
+		default:
+			return p, &migo.ErrUnsupportedStatement{Func: fn.Name, Stmt: stmt}
+		}
+	}
+	return p, nil
+}
+
+// visitBlocks visits each of the alternative blocks from p, and returns
+// the join of their ends.
+func (a *analyser) visitBlocks(fn *migo.Function, s *Summary, p path, blocks ...[]migo.Statement) (path, error) {
+	if len(blocks) == 0 {
+		return p, nil
+	}
+	end := path{}
+	for _, block := range blocks {
+		q, err := a.visitStmts(fn, s, p, block)
+		if err != nil {
+			return q, err
+		}
+		end = join(end, q)
+	}
+	return end, nil
+}
+
+// lock adds n to the balance of name in p, if visible to callers.
+func (a *analyser) lock(fn *migo.Function, p path, name string, n int) path {
+	if !a.visible(fn, name) {
+		return p
+	}
+	return p.lock(name, n)
+}
+
+// call applies the summary of callee, called with params from fn, to p.
+func (a *analyser) call(fn *migo.Function, s *Summary, p path, callee *migo.Function, params []*migo.Parameter) path {
+	cs := a.summaries[callee]
+	a.effects(fn, s, cs, params)
+	if cs.Spawns {
+		s.Spawns = true
+	}
+	if cs.MayNotTerminate {
+		s.MayNotTerminate = true
+	}
+	if !cs.MayReturn {
+		return path{}
+	}
+	for name, b := range cs.Balance {
+		if caller, ok := a.rename(fn, callee, params, name); ok {
+			q := p.lock(caller, 0)
+			q.balance[caller] = q.balance[caller].add(b)
+			p = q
+		}
+	}
+	return p
+}
+
+// effects adds the effects of callee, called or spawned with params from
+// fn, to s.
+func (a *analyser) effects(fn *migo.Function, s *Summary, cs *Summary, params []*migo.Parameter) {
+	if cs.Spawns {
+		s.Spawns = true
+	}
+	for name, e := range cs.Effects {
+		if caller, ok := a.rename(fn, cs.Func, params, name); ok {
+			s.Effects[caller] |= e
+		}
+	}
+}
+
+// rename returns the name in fn of name in callee, called with params,
+// if it is visible to the callers of fn.
+func (a *analyser) rename(fn, callee *migo.Function, params []*migo.Parameter, name string) (string, bool) {
+	for i, p := range callee.Params {
+		if p.Callee.Name() == name {
+			if i >= len(params) {
+				return "", false
+			}
+			caller := params[i].Caller.Name()
+			return caller, a.visible(fn, caller)
+		}
+	}
+	// Free names of callee are global, and are not visible in fn if fn
+	// binds the same name, e.g. as a parameter.
+	return name, !a.scope.Bound(fn, name)
+}
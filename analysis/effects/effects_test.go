@@ -0,0 +1,198 @@
+package effects
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+func TestEffects(t *testing.T) {
+	s := `
+def main():
+	let ch = newchan ch, 0;
+	letsync mu mutex;
+	spawn worker(ch, mu);
+	call producer(ch);
+def producer(out):
+	send out;
+	close out;
+def worker(in, l):
+	lock l;
+	recv in;
+	unlock l;
+	send g;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Summarise(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		fn, name string
+		want     Effect
+	}{
+		{"producer", "out", Send | Close},
+		{"worker", "in", Recv},
+		{"worker", "l", Lock | Unlock},
+		{"worker", "g", Send},
+		{"main", "g", Send},
+		{"main", "ch", 0}, // Local to main.
+	}
+	for _, test := range tests {
+		if got := res.Summary(test.fn).Effect(test.name); got != test.want {
+			t.Errorf("%s: expected %s to have effects %s but got %s", test.fn, test.name, test.want, got)
+		}
+	}
+	if main := res.Summary("main"); !main.Spawns || res.Summary("producer").Spawns {
+		t.Errorf("expected only main to spawn")
+	}
+	if want, got := "0", res.Summary("worker").Balance["l"].String(); want != got {
+		t.Errorf("expected balance %s but got %s", want, got)
+	}
+	if res.Summary("missing") != nil {
+		t.Errorf("expected no summary for missing function")
+	}
+}
+
+func TestEffectsShadowed(t *testing.T) {
+	s := `
+def main():
+	let x = newchan x, 0;
+	call f(x);
+def f(g):
+	call h();
+def h():
+	send g;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Summarise(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Summary("f").Effect("g"); got != 0 {
+		t.Errorf("expected parameter g of f to have no effects but got %s", got)
+	}
+	if got := res.Summary("h").Effect("g"); got != Send {
+		t.Errorf("expected free g of h to have effects %s but got %s", Send, got)
+	}
+}
+
+func TestBalance(t *testing.T) {
+	s := `
+def acquire(m):
+	if lock m; else tau; endif;
+def pair(m):
+	call acquire(m);
+	call acquire(m);
+def release(m):
+	select
+		case recv c; unlock m;
+		case tau;
+	endselect;
+def loop(m):
+	if lock m; call loop(m); else tau; endif;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Summarise(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		fn, want string
+	}{
+		{"acquire", "[0, 1]"},
+		{"pair", "[0, 2]"},
+		{"release", "[-1, 0]"},
+		{"loop", "[0, ∞]"},
+	}
+	for _, test := range tests {
+		if got := res.Summary(test.fn).Balance["m"].String(); got != test.want {
+			t.Errorf("%s: expected balance %s but got %s", test.fn, test.want, got)
+		}
+	}
+	if want, got := Recv, res.Summary("release").Effect("c"); want != got {
+		t.Errorf("expected effects %s on free name c but got %s", want, got)
+	}
+}
+
+func TestTermination(t *testing.T) {
+	s := `
+def main():
+	call loop();
+	spawn forever();
+def loop():
+	if call loop(); else tau; endif;
+def forever():
+	send g;
+	call forever();
+def server(c):
+	call forever();
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Summarise(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		fn                         string
+		mayReturn, mayNotTerminate bool
+	}{
+		{"main", true, true},
+		{"loop", true, true},
+		{"forever", false, true},
+		{"server", false, true},
+	}
+	for _, test := range tests {
+		s := res.Summary(test.fn)
+		if s.MayReturn != test.mayReturn || s.MayNotTerminate != test.mayNotTerminate {
+			t.Errorf("%s: expected may return %t and may not terminate %t but got %t and %t",
+				test.fn, test.mayReturn, test.mayNotTerminate, s.MayReturn, s.MayNotTerminate)
+		}
+	}
+	if want, got := Send, res.Summary("server").Effect("g"); want != got {
+		t.Errorf("expected effects %s but got %s", want, got)
+	}
+}
+
+func TestReport(t *testing.T) {
+	s := `
+def main():
+	letsync mu mutex;
+	spawn w(mu, mu);
+def w(a, b):
+	lock a;
+	unlock b;
+	if call w(a, b); else tau; endif;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Summarise(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `main():
+	may spawn
+w(a, b):
+	a: lock; balance [1, ∞]
+	b: unlock; balance [-∞, -1]
+	may not terminate
+`
+	if got := res.String(); want != got {
+		t.Errorf("expected report\n%s\nbut got\n%s", want, got)
+	}
+}
@@ -0,0 +1,130 @@
+// Package fence decides whether a MiGo program is fenced, i.e. whether
+// recursion in the program can only create finitely many goroutines and
+// channels, which is needed for its state space to be finite.
+//
+// A program is fenced if no function in a recursive strongly connected
+// component of its control-flow graph (by call or spawn) spawns a goroutine
+// or creates a channel, memory or mutex. Each iteration of the recursion
+// would otherwise create a new goroutine or object, so exploring the
+// program may not terminate.
+package fence
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/ctrlflow"
+)
+
+// Issue is a statement which makes a program unfenced.
+type Issue struct {
+	Func  string         // Function containing Stmt.
+	Stmt  migo.Statement // spawn, newchan, letmem or letsync statement.
+	Cycle []string       // Recursion through Func, starting and ending with Func.
+}
+
+func (i Issue) String() string {
+	what := "creates a new object"
+	switch stmt := i.Stmt.(type) {
+	case *migo.SpawnStatement:
+		what = fmt.Sprintf("spawns a new goroutine running %s", stmt.Name)
+	case *migo.NewChanStatement:
+		what = fmt.Sprintf("creates a new channel %s", stmt.Name.Name())
+	}
+	return fmt.Sprintf("%s: %q in recursive %s: each iteration of %s %s",
+		i.Func, i.Stmt, i.Func, strings.Join(i.Cycle, " → "), what)
+}
+
+// Report is the result of a fence check.
+type Report struct {
+	Fenced bool    // No issues are found.
+	Issues []Issue // Statements which make the program unfenced.
+}
+
+// Check returns whether Program prog is fenced.
+//
+// Returns a *migo.ErrUnsupportedStatement error if prog contains
+// a statement kind that is unknown and not a migo.ExtStatement.
+func Check(prog *migo.Program) (*Report, error) {
+	g, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Fenced: true}
+	for _, scc := range g.SCCs() {
+		if !ctrlflow.IsRecursive(scc) {
+			continue
+		}
+		inSCC := make(map[*ctrlflow.Node]bool)
+		for _, n := range scc {
+			inSCC[n] = true
+		}
+		for _, n := range scc {
+			forEachCreate(n.Func().Stmts, func(stmt migo.Statement) {
+				report.Issues = append(report.Issues, Issue{
+					Func:  n.Func().Name,
+					Stmt:  stmt,
+					Cycle: cycle(n, inSCC),
+				})
+			})
+		}
+	}
+	report.Fenced = len(report.Issues) == 0
+	return report, nil
+}
+
+// cycle returns the names of the functions on a shortest cycle from n
+// back to n within a strongly connected component.
+func cycle(n *ctrlflow.Node, inSCC map[*ctrlflow.Node]bool) []string {
+	prev := make(map[*ctrlflow.Node]*ctrlflow.Node)
+	queue := []*ctrlflow.Node{n}
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		for _, s := range m.Succs {
+			if !inSCC[s] {
+				continue
+			}
+			if _, seen := prev[s]; seen {
+				continue
+			}
+			prev[s] = m
+			if s == n {
+				names := []string{n.Func().Name}
+				for p := prev[n]; p != n; p = prev[p] {
+					names = append([]string{p.Func().Name}, names...)
+				}
+				return append([]string{n.Func().Name}, names...)
+			}
+			queue = append(queue, s)
+		}
+	}
+	return nil
+}
+
+// forEachCreate calls fn on each statement in stmts creating a goroutine
+// or an object.
+func forEachCreate(stmts []migo.Statement, fn func(migo.Statement)) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *migo.SpawnStatement, *migo.NewChanStatement, *migo.NewMem,
+			*migo.NewSyncMutex, *migo.NewSyncRWMutex:
+			fn(stmt)
+		case *migo.IfStatement:
+			forEachCreate(s.Then, fn)
+			forEachCreate(s.Else, fn)
+		case *migo.IfForStatement:
+			forEachCreate(s.Then, fn)
+			forEachCreate(s.Else, fn)
+		case *migo.SelectStatement:
+			for _, c := range s.Cases {
+				forEachCreate(c, fn)
+			}
+		case migo.ExtStatement:
+			for _, b := range s.Blocks() {
+				forEachCreate(b, fn)
+			}
+		}
+	}
+}
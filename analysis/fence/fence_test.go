@@ -0,0 +1,79 @@
+package fence
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+func TestFenced(t *testing.T) {
+	s := `
+def main(): let ch = newchan ch, 0; spawn worker(ch); call loop(ch);
+def loop(c): if send c; call loop(c); else tau; endif;
+def worker(c): recv c; call worker(c);
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Check(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Fenced {
+		t.Errorf("expected program to be fenced but got %v", report.Issues)
+	}
+}
+
+func TestUnfencedSpawn(t *testing.T) {
+	s := `
+def main(): let ch = newchan ch, 0; call loop(ch);
+def loop(c): spawn worker(c); call next(c);
+def next(c): if call loop(c); else tau; endif;
+def worker(c): send c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Check(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Fenced {
+		t.Fatalf("expected program not to be fenced")
+	}
+	if want, got := 1, len(report.Issues); want != got {
+		t.Fatalf("expected %d issue but got %d: %v", want, got, report.Issues)
+	}
+	issue := report.Issues[0]
+	if want, got := "loop → next → loop", strings.Join(issue.Cycle, " → "); want != got {
+		t.Errorf("expected cycle %s but got %s", want, got)
+	}
+	if want, got := `loop: "spawn worker(c)" in recursive loop: each iteration of loop → next → loop spawns a new goroutine running worker`, issue.String(); want != got {
+		t.Errorf("expected diagnostic\n%s\nbut got\n%s", want, got)
+	}
+}
+
+func TestUnfencedNewChan(t *testing.T) {
+	s := `
+def main(): call f();
+def f(): let c = newchan c, 0; spawn f();
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Check(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both the channel and the spawn are created in each iteration.
+	if want, got := 2, len(report.Issues); want != got {
+		t.Fatalf("expected %d issues but got %d: %v", want, got, report.Issues)
+	}
+	if want, got := "f → f", strings.Join(report.Issues[0].Cycle, " → "); want != got {
+		t.Errorf("expected cycle %s but got %s", want, got)
+	}
+}
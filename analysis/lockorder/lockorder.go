@@ -0,0 +1,494 @@
+// Package lockorder builds the lock acquisition order graph of a MiGo
+// program, and reports its cycles as potential deadlocks.
+//
+// There is an edge A→B in the graph if a goroutine locks B while holding A.
+// If a goroutine locks A then B while another locks B then A, each may hold
+// the lock the other is waiting for, i.e. the cycle A→B→A is a potential
+// deadlock. Read locks (rlock) do not block each other, so a cycle is only
+// reported if at least one of its edges is a write lock.
+//
+// Mutexes are identified by their letsync statement, or by name for free
+// names (i.e. global mutexes). The analysis follows mutexes passed as
+// parameters of call and spawn, and is context-sensitive: a function is
+// analysed once per binding of its mutex parameters and set of locks held
+// on entry. Branches of if and select statements are merged, so a lock is
+// held after a branch if it may be held at the end of any of the branches,
+// and a name bound in a branch may denote the mutex of any of them.
+//
+// Goroutines are analysed once per spawn statement and binding of their
+// parameters. A goroutine whose spawn statement may run more than once
+// (see ctrlflow.Repeated), e.g. in a loop, stands for several goroutines,
+// so a cycle created by it alone is a potential deadlock.
+//
+// # Usage
+//
+//	g, err := lockorder.Analyse(prog, "main.main")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for _, c := range g.Cycles() {
+//		fmt.Println(c)
+//	}
+package lockorder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/alias"
+	"github.com/JorgeGCoelho/migo/v3/internal/ctrlflow"
+)
+
+// Mutex is a mutex in the lock order graph.
+type Mutex struct {
+	Name string         // Name the mutex is bound to when created.
+	Func string         // Function creating the mutex, empty for free names.
+	Site migo.Statement // letsync statement, nil for free names.
+
+	id int
+}
+
+func (m *Mutex) String() string {
+	if m.Func == "" {
+		return m.Name
+	}
+	return fmt.Sprintf("%s.%s", m.Func, m.Name)
+}
+
+// Edge is an edge of the lock order graph, i.e. a lock of mutex To by a
+// goroutine holding mutex From.
+type Edge struct {
+	From, To  *Mutex
+	Goroutine []string       // Spawn chain of the goroutine, entry first.
+	Path      []string       // Call path from the goroutine function to the lock of To.
+	HeldAt    migo.Statement // Statement locking From.
+	LockAt    migo.Statement // Statement locking To.
+	Read      bool           // From is held and To is locked for reading only.
+
+	g *goroutine
+}
+
+func (e *Edge) String() string {
+	return fmt.Sprintf("%s → %s in [%s] via %s",
+		e.From, e.To, strings.Join(e.Goroutine, " > "), strings.Join(e.Path, " > "))
+}
+
+// Cycle is a cycle in the lock order graph, i.e. a potential deadlock.
+type Cycle struct {
+	Edges []*Edge // Edges of the cycle, Edges[i].To is Edges[i+1].From.
+}
+
+func (c Cycle) String() string {
+	var sb strings.Builder
+	names := make([]string, 0, len(c.Edges)+1)
+	for _, e := range c.Edges {
+		names = append(names, e.From.String())
+	}
+	names = append(names, c.Edges[0].From.String())
+	fmt.Fprintf(&sb, "potential deadlock: %s", strings.Join(names, " → "))
+	for _, e := range c.Edges {
+		fmt.Fprintf(&sb, "\n    %s", e)
+	}
+	return sb.String()
+}
+
+// Graph is the lock order graph of a program.
+type Graph struct {
+	Mutexes []*Mutex // Mutexes locked in the program.
+	Edges   []*Edge  // At most one edge per pair of mutexes, goroutine and Read.
+}
+
+// Analyse returns the lock order graph of Program prog, starting from the
+// function entry.
+//
+// Returns a *migo.ErrFuncNotFound error if entry is not in prog, and a
+// *migo.ErrUnsupportedStatement error if prog contains a statement kind
+// that is unknown.
+func Analyse(prog *migo.Program, entry string) (*Graph, error) {
+	entryFn, found := prog.Function(entry)
+	if !found {
+		return nil, &migo.ErrFuncNotFound{Name: entry}
+	}
+	repeated, err := ctrlflow.Repeated(prog)
+	if err != nil {
+		return nil, err
+	}
+	a := &analyser{
+		prog:     prog,
+		graph:    new(Graph),
+		repeated: repeated,
+		binder:   alias.NewBinder(),
+		mutexes:  make(map[*alias.Object]*Mutex),
+		edges:    make(map[edgeKey]bool),
+		exits:    make(map[string][]acquisition),
+		active:   make(map[string]bool),
+		spawned:  make(map[string]bool),
+	}
+	a.queue = []*goroutine{{chain: []string{entryFn.Name}, fn: entryFn, env: alias.Env{}}}
+	for len(a.queue) > 0 {
+		var g *goroutine
+		g, a.queue = a.queue[0], a.queue[1:]
+		if _, err := a.visitFunc(g, g.fn, g.env, nil, []string{g.fn.Name}); err != nil {
+			return nil, err
+		}
+	}
+	return a.graph, nil
+}
+
+// goroutine is a goroutine started by the entry or a spawn statement.
+type goroutine struct {
+	chain    []string // Spawn chain.
+	fn       *migo.Function
+	env      alias.Env // Parameters of fn.
+	repeated bool      // Stands for several goroutines.
+}
+
+func mutexesKey(ms []*Mutex) string {
+	ids := make([]string, len(ms))
+	for i, m := range ms {
+		ids[i] = fmt.Sprint(m.id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+// acquisition is a lock held by a goroutine.
+type acquisition struct {
+	mu   *Mutex
+	stmt migo.Statement
+	read bool // Locked with rlock.
+}
+
+func heldKey(held []acquisition) string {
+	ms := make([]*Mutex, len(held))
+	for i, h := range held {
+		ms[i] = h.mu
+	}
+	return mutexesKey(ms)
+}
+
+type edgeKey struct {
+	from, to *Mutex
+	g        *goroutine
+	read     bool
+}
+
+type analyser struct {
+	prog    *migo.Program
+	graph   *Graph
+	binder  *alias.Binder
+	mutexes map[*alias.Object]*Mutex
+	edges   map[edgeKey]bool
+	exits   map[string][]acquisition // Locks held on return, by context.
+	active  map[string]bool          // Contexts being visited.
+	spawned map[string]bool          // Goroutines queued, by spawn statement and env.
+	queue   []*goroutine
+
+	repeated map[*migo.SpawnStatement]bool // Spawns which may run more than once.
+}
+
+// lookup returns the mutexes name may refer to in fn, in any of the
+// alternative Envs es.
+func (a *analyser) lookup(fn *migo.Function, es []alias.Env, name string) []*Mutex {
+	var ms []*Mutex
+	for _, e := range es {
+		o := a.binder.Lookup(fn, e, name)
+		if o == nil {
+			continue // Not a mutex.
+		}
+		if _, ok := a.mutexes[o]; !ok {
+			a.mutexes[o] = &Mutex{Name: o.Name, Func: o.Func, Site: o.Stmt, id: o.ID}
+		}
+		if !contains(ms, a.mutexes[o]) {
+			ms = append(ms, a.mutexes[o])
+		}
+	}
+	return ms
+}
+
+// visitFunc visits the body of fn with held locks, and returns the locks
+// held on return.
+func (a *analyser) visitFunc(g *goroutine, fn *migo.Function, e alias.Env, held []acquisition, path []string) ([]acquisition, error) {
+	ctx := fmt.Sprintf("%p|%s|%s|%s", g, fn.Name, e.Key(), heldKey(held))
+	if exit, ok := a.exits[ctx]; ok {
+		return exit, nil
+	}
+	if a.active[ctx] {
+		return held, nil // Recursive call, assume locks held are unchanged.
+	}
+	a.active[ctx] = true
+	exit, _, err := a.visitStmts(g, fn, []alias.Env{e}, held, path, fn.Stmts)
+	delete(a.active, ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.exits[ctx] = exit
+	return exit, nil
+}
+
+// visitStmts visits stmts with held locks and the alternative Envs es of
+// the names of fn, and returns the locks held and the alternative Envs at
+// the end of stmts.
+func (a *analyser) visitStmts(g *goroutine, fn *migo.Function, es []alias.Env, held []acquisition, path []string, stmts []migo.Statement) ([]acquisition, []alias.Env, error) {
+	var err error
+	for _, stmt := range stmts {
+		for i, e := range es {
+			es[i] = a.binder.Let(fn, e, stmt)
+		}
+		switch stmt := stmt.(type) {
+		case *migo.SyncMutexLock:
+			held = a.lock(g, fn, es, held, path, stmt.Name, stmt, false)
+		case *migo.SyncRWMutexRLock:
+			held = a.lock(g, fn, es, held, path, stmt.Name, stmt, true)
+		case *migo.SyncMutexUnlock:
+			held = a.unlock(fn, es, held, stmt.Name)
+		case *migo.SyncRWMutexRUnlock:
+			held = a.unlock(fn, es, held, stmt.Name)
+		case *migo.CallStatement:
+			callee, found := a.prog.Function(stmt.Name)
+			if !found {
+				continue
+			}
+			calleePath := append(append([]string(nil), path...), callee.Name)
+			var merged []acquisition
+			for _, e := range es {
+				exit, err := a.visitFunc(g, callee, a.binder.Bind(fn, e, callee, stmt.Params), held, calleePath)
+				if err != nil {
+					return nil, nil, err
+				}
+				merged = merge(merged, exit)
+			}
+			held = merged
+		case *migo.SpawnStatement:
+			callee, found := a.prog.Function(stmt.Name)
+			if !found {
+				continue
+			}
+			for _, e := range es {
+				calleeEnv := a.binder.Bind(fn, e, callee, stmt.Params)
+				key := fmt.Sprintf("%p|%s", stmt, calleeEnv.Key())
+				if a.spawned[key] {
+					continue
+				}
+				a.spawned[key] = true
+				a.queue = append(a.queue, &goroutine{
+					chain:    append(append([]string(nil), g.chain...), callee.Name),
+					fn:       callee,
+					env:      calleeEnv,
+					repeated: g.repeated || a.repeated[stmt],
+				})
+			}
+		case *migo.IfStatement:
+			held, es, err = a.visitBlocks(g, fn, es, held, path, stmt.Then, stmt.Else)
+		case *migo.IfForStatement:
+			held, es, err = a.visitBlocks(g, fn, es, held, path, stmt.Then, stmt.Else)
+		case *migo.SelectStatement:
+			held, es, err = a.visitBlocks(g, fn, es, held, path, stmt.Cases...)
+		case migo.ExtStatement:
+			held, es, err = a.visitBlocks(g, fn, es, held, path, stmt.Blocks()...)
+		case *migo.SendStatement, *migo.RecvStatement, *migo.CloseStatement, *migo.NewChanStatement,
+			*migo.TauStatement, *migo.NewMem, *migo.MemRead, *migo.MemWrite,
+			*migo.NewSyncMutex, *migo.NewSyncRWMutex:
+		default:
+			return nil, nil, &migo.ErrUnsupportedStatement{Func: fn.Name, Stmt: stmt}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return held, es, nil
+}
+
+// visitBlocks visits each of the alternative blocks, and returns the locks
+// that may be held after any of them, and the distinct Envs at the end of
+// each of them, so names bound in a block are kept after it.
+func (a *analyser) visitBlocks(g *goroutine, fn *migo.Function, es []alias.Env, held []acquisition, path []string, blocks ...[]migo.Statement) ([]acquisition, []alias.Env, error) {
+	if len(blocks) == 0 {
+		return held, es, nil
+	}
+	var merged []acquisition
+	var envs []alias.Env
+	seen := make(map[string]bool)
+	for _, block := range blocks {
+		exit, exitEnvs, err := a.visitStmts(g, fn, append([]alias.Env(nil), es...), held, path, block)
+		if err != nil {
+			return nil, nil, err
+		}
+		merged = merge(merged, exit)
+		for _, e := range exitEnvs {
+			if k := e.Key(); !seen[k] {
+				seen[k] = true
+				envs = append(envs, e)
+			}
+		}
+	}
+	return merged, envs, nil
+}
+
+// merge returns held with the locks of exit not in held.
+func merge(held, exit []acquisition) []acquisition {
+	for _, h := range exit {
+		if !holds(held, h.mu) {
+			held = append(held, h)
+		}
+	}
+	return held
+}
+
+func (a *analyser) lock(g *goroutine, fn *migo.Function, es []alias.Env, held []acquisition, path []string, name string, stmt migo.Statement, read bool) []acquisition {
+	before := held // Alternative mutexes of name are not locked in sequence.
+	for _, mu := range a.lookup(fn, es, name) {
+		a.register(mu)
+		lock := acquisition{mu: mu, stmt: stmt, read: read}
+		for _, h := range before {
+			if h.mu != mu {
+				a.addEdge(g, h, lock, path)
+			}
+		}
+		if !holds(held, mu) {
+			held = append(held[:len(held):len(held)], lock)
+		}
+	}
+	return held
+}
+
+func (a *analyser) unlock(fn *migo.Function, es []alias.Env, held []acquisition, name string) []acquisition {
+	ms := a.lookup(fn, es, name)
+	var rest []acquisition
+	for _, h := range held {
+		if !contains(ms, h.mu) {
+			rest = append(rest, h)
+		}
+	}
+	return rest
+}
+
+func (a *analyser) register(mu *Mutex) {
+	for _, m := range a.graph.Mutexes {
+		if m == mu {
+			return
+		}
+	}
+	a.graph.Mutexes = append(a.graph.Mutexes, mu)
+}
+
+func (a *analyser) addEdge(g *goroutine, h, lock acquisition, path []string) {
+	key := edgeKey{from: h.mu, to: lock.mu, g: g, read: h.read && lock.read}
+	if a.edges[key] {
+		return
+	}
+	a.edges[key] = true
+	a.graph.Edges = append(a.graph.Edges, &Edge{
+		From:      h.mu,
+		To:        lock.mu,
+		Goroutine: g.chain,
+		Path:      append([]string(nil), path...),
+		HeldAt:    h.stmt,
+		LockAt:    lock.stmt,
+		Read:      key.read,
+		g:         g,
+	})
+}
+
+func holds(held []acquisition, mu *Mutex) bool {
+	for _, h := range held {
+		if h.mu == mu {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(ms []*Mutex, mu *Mutex) bool {
+	for _, m := range ms {
+		if m == mu {
+			return true
+		}
+	}
+	return false
+}
+
+// Cycles returns the elementary cycles of the graph which are created by
+// at least two goroutines, with at least one edge which is not Read. A
+// cycle created by a single goroutine is not a deadlock, as the goroutine
+// locks the mutexes of the cycle in sequence, unless its spawn statement
+// may run more than once. A cycle of read locks is not a deadlock either,
+// as read locks do not block each other.
+func (g *Graph) Cycles() []Cycle {
+	out := make(map[*Mutex][]*Mutex)
+	edges := make(map[[2]*Mutex][]*Edge)
+	for _, e := range g.Edges {
+		k := [2]*Mutex{e.From, e.To}
+		if len(edges[k]) == 0 {
+			out[e.From] = append(out[e.From], e.To)
+		}
+		edges[k] = append(edges[k], e)
+	}
+	index := make(map[*Mutex]int)
+	for i, m := range g.Mutexes {
+		index[m] = i
+	}
+	var cycles []Cycle
+	// Enumerate each elementary cycle once, from its first mutex.
+	var path []*Mutex
+	onPath := make(map[*Mutex]bool)
+	var visit func(start, m *Mutex)
+	visit = func(start, m *Mutex) {
+		path = append(path, m)
+		onPath[m] = true
+		for _, next := range out[m] {
+			if next == start {
+				if c, ok := pickEdges(path, edges); ok {
+					cycles = append(cycles, c)
+				}
+			} else if !onPath[next] && index[next] > index[start] {
+				visit(start, next)
+			}
+		}
+		path = path[:len(path)-1]
+		delete(onPath, m)
+	}
+	for _, m := range g.Mutexes {
+		visit(m, m)
+	}
+	return cycles
+}
+
+// pickEdges returns a Cycle over the mutexes of path with a write edge,
+// and edges from at least two goroutines or from a repeated goroutine, or
+// false if there is none.
+func pickEdges(path []*Mutex, edges map[[2]*Mutex][]*Edge) (Cycle, bool) {
+	candidates := make([][]*Edge, len(path))
+	for i, m := range path {
+		candidates[i] = edges[[2]*Mutex{m, path[(i+1)%len(path)]}]
+	}
+	for i := range path {
+		for _, w := range candidates[i] {
+			if w.Read {
+				continue
+			}
+			c := Cycle{Edges: make([]*Edge, len(path))}
+			for j := range path {
+				c.Edges[j] = candidates[j][0]
+			}
+			c.Edges[i] = w
+			if w.g.repeated {
+				return c, true
+			}
+			for j := range path {
+				if j == i {
+					continue
+				}
+				for _, e := range candidates[j] {
+					if e.g != w.g || e.g.repeated {
+						c.Edges[j] = e
+						return c, true
+					}
+				}
+			}
+		}
+	}
+	return Cycle{}, false
+}
@@ -0,0 +1,285 @@
+package lockorder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+func TestCycle(t *testing.T) {
+	s := `
+def main(): letsync a mutex; letsync b mutex; spawn ab(a, b); call ba(a, b);
+def ab(x, y): lock x; call inner(y); unlock x;
+def inner(m): lock m; unlock m;
+def ba(x, y): lock y; lock x; unlock x; unlock y;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(g.Edges); want != got {
+		t.Fatalf("expected %d edges but got %d: %v", want, got, g.Edges)
+	}
+	cycles := g.Cycles()
+	if want, got := 1, len(cycles); want != got {
+		t.Fatalf("expected %d cycle but got %d", want, got)
+	}
+	for _, e := range cycles[0].Edges {
+		switch e.From.Name {
+		case "a":
+			if want, got := "main > ab", strings.Join(e.Goroutine, " > "); want != got {
+				t.Errorf("expected edge a→b in goroutine %s but got %s", want, got)
+			}
+			if want, got := "ab > inner", strings.Join(e.Path, " > "); want != got {
+				t.Errorf("expected edge a→b created via %s but got %s", want, got)
+			}
+		case "b":
+			if want, got := "main > ba", strings.Join(e.Path, " > "); want != got {
+				t.Errorf("expected edge b→a created via %s but got %s", want, got)
+			}
+		}
+	}
+}
+
+func TestSameGoroutine(t *testing.T) {
+	s := `
+def main(): letsync a mutex; letsync b mutex; lock a; lock b; unlock b; unlock a; lock b; lock a; unlock a; unlock b;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(g.Edges); want != got {
+		t.Errorf("expected %d edges but got %d", want, got)
+	}
+	if want, got := 0, len(g.Cycles()); want != got {
+		t.Errorf("expected no cycles in a single goroutine but got %d", got)
+	}
+}
+
+func TestConsistentOrder(t *testing.T) {
+	s := `
+def main(): letsync a mutex; letsync b mutex; spawn w(a, b); spawn w(a, b);
+def w(x, y): lock x; lock y; unlock y; unlock x;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 0, len(g.Cycles()); want != got {
+		t.Errorf("expected no cycles but got %d", got)
+	}
+}
+
+func TestParamSwap(t *testing.T) {
+	// Same function spawned with mutexes swapped.
+	s := `
+def main(): letsync a mutex; letsync b mutex; spawn w(a, b); spawn w(b, a);
+def w(x, y): lock x; if lock y; unlock y; else tau; endif; unlock x;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(g.Cycles()); want != got {
+		t.Errorf("expected %d cycle but got %d", want, got)
+	}
+}
+
+func TestSpawnBindings(t *testing.T) {
+	// One spawn statement, reached with the mutexes swapped.
+	s := `
+def main(): letsync a mutex; letsync b mutex; call s(a, b); call s(b, a);
+def s(x, y): spawn w(x, y);
+def w(x, y): lock x; lock y; unlock y; unlock x;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(g.Cycles()); want != got {
+		t.Errorf("expected %d cycle but got %d", want, got)
+	}
+}
+
+func TestRepeatedSpawn(t *testing.T) {
+	// Goroutines spawned in a loop may lock in both orders at once.
+	s := `
+def main(): letsync a mutex; letsync b mutex; call loop(a, b);
+def loop(x, y): if spawn w(x, y); call loop(x, y); else tau; endif;
+def w(x, y): if lock x; lock y; unlock y; unlock x; else lock y; lock x; unlock x; unlock y; endif;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(g.Cycles()); want != got {
+		t.Errorf("expected %d cycle but got %d", want, got)
+	}
+	s = `
+def main(): letsync a mutex; letsync b mutex; spawn w(a, b);
+def w(x, y): if lock x; lock y; unlock y; unlock x; else lock y; lock x; unlock x; unlock y; endif;
+`
+	prog, err = parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err = Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 0, len(g.Cycles()); want != got {
+		t.Errorf("expected no cycles in a single goroutine but got %d", got)
+	}
+}
+
+func TestReadLocks(t *testing.T) {
+	// Read locks do not block each other.
+	s := `
+def main(): letsync a rwmutex; letsync b rwmutex; spawn f(a, b); spawn f(b, a);
+def f(x, y): rlock x; rlock y; runlock y; runlock x;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 0, len(g.Cycles()); want != got {
+		t.Errorf("expected no cycles of read locks but got %v", g.Cycles())
+	}
+	s = `
+def main(): letsync a rwmutex; letsync b rwmutex; spawn f(a, b); spawn w(b, a);
+def f(x, y): rlock x; rlock y; runlock y; runlock x;
+def w(x, y): lock x; rlock y; runlock y; unlock x;
+`
+	prog, err = parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err = Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(g.Cycles()); want != got {
+		t.Errorf("expected %d cycle with a write lock but got %d", want, got)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	s := `
+def main(): spawn f(); lock b; lock a; unlock a; unlock b;
+def f(): if lock a; lock b; unlock b; unlock a; else tau; endif;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cycles := g.Cycles()
+	if want, got := 1, len(cycles); want != got {
+		t.Fatalf("expected %d cycle but got %d", want, got)
+	}
+	if want, got := "potential deadlock: b → a → b", strings.SplitN(cycles[0].String(), "\n", 2)[0]; want != got {
+		t.Errorf("expected %q but got %q", want, got)
+	}
+}
+
+func TestThreeCycle(t *testing.T) {
+	s := `
+def main(): letsync a mutex; letsync b mutex; letsync c mutex; spawn w(a, b); spawn w(b, c); spawn w(c, a);
+def w(x, y): lock x; lock y; unlock y; unlock x;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, c := range g.Cycles() {
+		if len(c.Edges) == 3 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected cycle a → b → c → a")
+	}
+}
+
+type unknownStmt struct{}
+
+func (unknownStmt) String() string { return "unknown" }
+
+func TestUnsupported(t *testing.T) {
+	prog := migo.NewProgram()
+	main := migo.NewFunction("main")
+	main.AddStmts(unknownStmt{})
+	prog.AddFunction(main)
+	_, err := Analyse(prog, "main")
+	var unsupported *migo.ErrUnsupportedStatement
+	if !errors.As(err, &unsupported) {
+		t.Errorf("expected ErrUnsupportedStatement but got %v", err)
+	}
+	_, err = Analyse(prog, "nomain")
+	var notFound *migo.ErrFuncNotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected ErrFuncNotFound but got %v", err)
+	}
+}
+
+func TestBranchBindings(t *testing.T) {
+	// b is bound in either branch, and locked after them.
+	s := `
+def main(): letsync a mutex; if letsync b mutex; else letsync b mutex; endif; spawn w(a, b); lock b; lock a; unlock a; unlock b;
+def w(x, y): lock x; lock y; unlock y; unlock x;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Analyse(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 4, len(g.Edges); want != got {
+		t.Fatalf("expected %d edges but got %d: %v", want, got, g.Edges)
+	}
+	if want, got := 2, len(g.Cycles()); want != got {
+		t.Errorf("expected %d cycles but got %d", want, got)
+	}
+}
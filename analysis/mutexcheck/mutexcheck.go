@@ -0,0 +1,416 @@
+// Package mutexcheck finds misuses of mutexes in MiGo programs.
+//
+// Each goroutine, i.e. the entry function and each spawned function, is
+// analysed separately, once per spawn statement and binding of its
+// parameters to mutexes, along each path through if, ifFor and select
+// branches, following calls with the mutexes passed as parameters. The
+// misuses found are:
+//
+//   - unlock or runlock of a mutex that is not held by the goroutine
+//   - lock of a mutex already locked by the goroutine (self-deadlock)
+//   - lock of a rwmutex read-locked by the goroutine (upgrade, which also
+//     deadlocks)
+//   - function or goroutine returning while holding a lock it acquired
+//
+// Mutexes are identified by their letsync statement, or by name for free
+// names (i.e. global mutexes).
+package mutexcheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/alias"
+)
+
+// Kind is the kind of a mutex misuse.
+type Kind int
+
+// Kinds of mutex misuse.
+const (
+	UnlockOfUnlocked Kind = iota // unlock or runlock of a mutex not held.
+	DoubleLock                   // lock or rlock of a mutex locked by the goroutine.
+	Upgrade                      // lock of a rwmutex read-locked by the goroutine.
+	LeakedLock                   // Function or goroutine returns holding a lock.
+)
+
+func (k Kind) String() string {
+	switch k {
+	case UnlockOfUnlocked:
+		return "unlock of unlocked mutex"
+	case DoubleLock:
+		return "double lock"
+	case Upgrade:
+		return "lock upgrade from rlock"
+	case LeakedLock:
+		return "leaked lock"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Issue is a misuse of a mutex.
+type Issue struct {
+	Kind      Kind
+	Mutex     string         // Name of the mutex, qualified by its function if local.
+	Func      string         // Function of Stmt, or returning for LeakedLock.
+	Stmt      migo.Statement // Statement misusing the mutex, or the lock leaked.
+	Goroutine []string       // Spawn chain of the goroutine, entry first.
+	Path      []string       // Branches and calls from the goroutine start to Stmt.
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s %s in %s: %s [%s] via %s", i.Kind, i.Mutex, i.Func, i.Stmt,
+		strings.Join(i.Goroutine, " > "), strings.Join(i.Path, ", "))
+}
+
+// Check returns the misuses of mutexes in Program prog, starting from the
+// function entry, at most one per kind, statement and mutex.
+//
+// Returns a *migo.ErrFuncNotFound error if entry is not in prog, and a
+// *migo.ErrUnsupportedStatement error if prog contains a statement kind
+// that is unknown.
+func Check(prog *migo.Program, entry string) ([]Issue, error) {
+	entryFn, found := prog.Function(entry)
+	if !found {
+		return nil, &migo.ErrFuncNotFound{Name: entry}
+	}
+	c := &checker{
+		prog:    prog,
+		binder:  alias.NewBinder(),
+		exits:   make(map[string][]exit),
+		active:  make(map[string]bool),
+		spawned: make(map[string]bool),
+		found:   make(map[issueKey]bool),
+	}
+	c.queue = []*goroutine{{chain: []string{entryFn.Name}, fn: entryFn, env: alias.Env{}}}
+	for len(c.queue) > 0 {
+		var g *goroutine
+		g, c.queue = c.queue[0], c.queue[1:]
+		exits, err := c.visitFunc(g, g.fn, g.env, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range exits {
+			for _, h := range x.held {
+				c.report(LeakedLock, g, g.fn, h.mu, h.stmt, x.path)
+			}
+		}
+	}
+	return c.issues, nil
+}
+
+// mutex is a mutex allocation site, or a free name.
+type mutex = alias.Object
+
+// goroutine is a goroutine started by the entry or a spawn statement.
+type goroutine struct {
+	chain []string
+	fn    *migo.Function
+	env   alias.Env
+}
+
+// holding is a lock held by a goroutine.
+type holding struct {
+	mu      *mutex
+	readers int            // Number of rlocks held, 0 if locked for writing.
+	stmt    migo.Statement // First statement acquiring the lock.
+}
+
+// state is the locks held along a path, and the names bound on it.
+type state struct {
+	held []holding
+	path []string
+	env  alias.Env
+}
+
+func (s state) key() string {
+	kv := make([]string, len(s.held))
+	for i, h := range s.held {
+		kv[i] = fmt.Sprintf("%d:%d", h.mu.ID, h.readers)
+	}
+	sort.Strings(kv)
+	return strings.Join(kv, ",") + "|" + s.env.Key()
+}
+
+func (s state) find(mu *mutex) int {
+	for i, h := range s.held {
+		if h.mu == mu {
+			return i
+		}
+	}
+	return -1
+}
+
+// with returns a copy of s with held lock i replaced by h, or removed if h
+// has no mutex. If i is len(s.held), h is added.
+func (s state) with(i int, h holding) state {
+	held := make([]holding, 0, len(s.held)+1)
+	held = append(held, s.held[:i]...)
+	if h.mu != nil {
+		held = append(held, h)
+	}
+	if i < len(s.held) {
+		held = append(held, s.held[i+1:]...)
+	}
+	return state{held: held, path: s.path, env: s.env}
+}
+
+func (s state) step(step string) state {
+	return state{held: s.held, path: append(s.path[:len(s.path):len(s.path)], step), env: s.env}
+}
+
+// exit is a state on return of a function, with the path from its start.
+type exit state
+
+type issueKey struct {
+	kind Kind
+	stmt migo.Statement
+	mu   *mutex
+}
+
+type checker struct {
+	prog    *migo.Program
+	binder  *alias.Binder
+	exits   map[string][]exit
+	active  map[string]bool
+	spawned map[string]bool // Goroutines queued, by spawn statement and env.
+	queue   []*goroutine
+	found   map[issueKey]bool
+	issues  []Issue
+}
+
+func (c *checker) report(kind Kind, g *goroutine, fn *migo.Function, mu *mutex, stmt migo.Statement, path []string) {
+	key := issueKey{kind: kind, stmt: stmt, mu: mu}
+	if c.found[key] {
+		return
+	}
+	c.found[key] = true
+	c.issues = append(c.issues, Issue{
+		Kind:      kind,
+		Mutex:     mu.String(),
+		Func:      fn.Name,
+		Stmt:      stmt,
+		Goroutine: g.chain,
+		Path:      append([]string(nil), path...),
+	})
+}
+
+// visitFunc visits the body of fn with held locks, and returns the states
+// on return, with paths from the start of fn. prefix is the path from the
+// start of the goroutine, for reporting.
+func (c *checker) visitFunc(g *goroutine, fn *migo.Function, e alias.Env, held []holding, prefix []string) ([]exit, error) {
+	ctx := fmt.Sprintf("%p|%s|%s", g, fn.Name, state{held: held, env: e}.key())
+	if exits, ok := c.exits[ctx]; ok {
+		return exits, nil
+	}
+	if c.active[ctx] {
+		// Recursive call, assume locks held are unchanged.
+		return []exit{{held: held, env: e}}, nil
+	}
+	c.active[ctx] = true
+	states, err := c.visitStmts(g, fn, []state{{held: held, env: e}}, prefix, fn.Stmts)
+	delete(c.active, ctx)
+	if err != nil {
+		return nil, err
+	}
+	exits := make([]exit, len(states))
+	for i, s := range states {
+		exits[i] = exit(s)
+	}
+	c.exits[ctx] = exits
+	return exits, nil
+}
+
+// visitStmts visits stmts from each of the states, and returns the
+// distinct states at the end of stmts. Paths which block or panic on a
+// misuse are dropped.
+func (c *checker) visitStmts(g *goroutine, fn *migo.Function, states []state, prefix []string, stmts []migo.Statement) ([]state, error) {
+	for _, stmt := range stmts {
+		var next []state
+		for _, s := range states {
+			ss, err := c.visitStmt(g, fn, s, prefix, stmt)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range ss {
+				s.env = c.binder.Let(fn, s.env, stmt)
+				next = append(next, s)
+			}
+		}
+		states = dedup(next)
+	}
+	return states, nil
+}
+
+// dedup removes the states holding the same locks with the same bindings
+// as an earlier state.
+func dedup(states []state) []state {
+	seen := make(map[string]bool)
+	var out []state
+	for _, s := range states {
+		if k := s.key(); !seen[k] {
+			seen[k] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (c *checker) visitStmt(g *goroutine, fn *migo.Function, s state, prefix []string, stmt migo.Statement) ([]state, error) {
+	e := s.env
+	path := func(s state) []string { return append(prefix[:len(prefix):len(prefix)], s.path...) }
+	switch stmt := stmt.(type) {
+	case *migo.SyncMutexLock:
+		mu := c.binder.Lookup(fn, e, stmt.Name)
+		if mu == nil {
+			return []state{s}, nil
+		}
+		if i := s.find(mu); i >= 0 {
+			kind := DoubleLock
+			if s.held[i].readers > 0 {
+				kind = Upgrade
+			}
+			c.report(kind, g, fn, mu, stmt, path(s))
+			return nil, nil
+		}
+		return []state{s.with(len(s.held), holding{mu: mu, stmt: stmt})}, nil
+	case *migo.SyncRWMutexRLock:
+		mu := c.binder.Lookup(fn, e, stmt.Name)
+		if mu == nil {
+			return []state{s}, nil
+		}
+		i := s.find(mu)
+		if i < 0 {
+			return []state{s.with(len(s.held), holding{mu: mu, readers: 1, stmt: stmt})}, nil
+		}
+		if s.held[i].readers == 0 {
+			c.report(DoubleLock, g, fn, mu, stmt, path(s))
+			return nil, nil
+		}
+		h := s.held[i]
+		h.readers++
+		return []state{s.with(i, h)}, nil
+	case *migo.SyncMutexUnlock:
+		mu := c.binder.Lookup(fn, e, stmt.Name)
+		if mu == nil {
+			return []state{s}, nil
+		}
+		i := s.find(mu)
+		if i < 0 || s.held[i].readers > 0 {
+			c.report(UnlockOfUnlocked, g, fn, mu, stmt, path(s))
+			return nil, nil
+		}
+		return []state{s.with(i, holding{})}, nil
+	case *migo.SyncRWMutexRUnlock:
+		mu := c.binder.Lookup(fn, e, stmt.Name)
+		if mu == nil {
+			return []state{s}, nil
+		}
+		i := s.find(mu)
+		if i < 0 || s.held[i].readers == 0 {
+			c.report(UnlockOfUnlocked, g, fn, mu, stmt, path(s))
+			return nil, nil
+		}
+		h := s.held[i]
+		if h.readers--; h.readers == 0 {
+			h = holding{}
+		}
+		return []state{s.with(i, h)}, nil
+	case *migo.CallStatement:
+		callee, found := c.prog.Function(stmt.Name)
+		if !found {
+			return []state{s}, nil
+		}
+		s = s.step("call " + callee.Name)
+		exits, err := c.visitFunc(g, callee, c.binder.Bind(fn, e, callee, stmt.Params), s.held, path(s))
+		if err != nil {
+			return nil, err
+		}
+		var next []state
+		for _, x := range exits {
+			for _, h := range x.held {
+				if s.find(h.mu) < 0 {
+					c.report(LeakedLock, g, callee, h.mu, h.stmt, append(path(s), x.path...))
+				}
+			}
+			next = append(next, state{held: x.held, path: append(s.path[:len(s.path):len(s.path)], x.path...), env: e})
+		}
+		return next, nil
+	case *migo.SpawnStatement:
+		callee, found := c.prog.Function(stmt.Name)
+		if !found {
+			return []state{s}, nil
+		}
+		calleeEnv := c.binder.Bind(fn, e, callee, stmt.Params)
+		if key := fmt.Sprintf("%p|%s", stmt, calleeEnv.Key()); !c.spawned[key] {
+			c.spawned[key] = true
+			c.queue = append(c.queue, &goroutine{
+				chain: append(g.chain[:len(g.chain):len(g.chain)], callee.Name),
+				fn:    callee,
+				env:   calleeEnv,
+			})
+		}
+		return []state{s}, nil
+	case *migo.IfStatement:
+		return c.visitBranches(g, fn, s, prefix, "if", stmt.Then, stmt.Else)
+	case *migo.IfForStatement:
+		return c.visitBranches(g, fn, s, prefix, fmt.Sprintf("ifFor (int %s)", stmt.ForCond), stmt.Then, stmt.Else)
+	case *migo.SelectStatement:
+		var next []state
+		for i, cse := range stmt.Cases {
+			step := fmt.Sprintf("select case %d", i)
+			if len(cse) > 0 {
+				step = fmt.Sprintf("select case %s", describe(cse[0]))
+			}
+			ss, err := c.visitStmts(g, fn, []state{s.step(step)}, prefix, cse)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, ss...)
+		}
+		return next, nil
+	case migo.ExtStatement:
+		var next []state
+		for i, b := range stmt.Blocks() {
+			ss, err := c.visitStmts(g, fn, []state{s.step(fmt.Sprintf("%s block %d", stmt, i))}, prefix, b)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, ss...)
+		}
+		if len(stmt.Blocks()) == 0 {
+			next = []state{s}
+		}
+		return next, nil
+	case *migo.SendStatement, *migo.RecvStatement, *migo.CloseStatement, *migo.NewChanStatement,
+		*migo.TauStatement, *migo.NewMem, *migo.MemRead, *migo.MemWrite,
+		*migo.NewSyncMutex, *migo.NewSyncRWMutex:
+		return []state{s}, nil
+	}
+	return nil, &migo.ErrUnsupportedStatement{Func: fn.Name, Stmt: stmt}
+}
+
+func (c *checker) visitBranches(g *goroutine, fn *migo.Function, s state, prefix []string, desc string, then, els []migo.Statement) ([]state, error) {
+	thenStates, err := c.visitStmts(g, fn, []state{s.step(desc + " then")}, prefix, then)
+	if err != nil {
+		return nil, err
+	}
+	elseStates, err := c.visitStmts(g, fn, []state{s.step(desc + " else")}, prefix, els)
+	if err != nil {
+		return nil, err
+	}
+	return append(thenStates, elseStates...), nil
+}
+
+// describe returns a short description of a select case prefix.
+func describe(stmt migo.Statement) string {
+	switch stmt := stmt.(type) {
+	case *migo.SendStatement:
+		return "send " + stmt.Chan
+	case *migo.RecvStatement:
+		return "recv " + stmt.Chan
+	}
+	return stmt.String()
+}
@@ -0,0 +1,176 @@
+package mutexcheck
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+func TestNoIssues(t *testing.T) {
+	s := `
+def main(): letsync mu mutex; letsync rw rwmutex; spawn f(mu); lock mu; call g(rw); unlock mu;
+def f(m): if lock m; unlock m; else tau; endif;
+def g(r): rlock r; rlock r; runlock r; runlock r; lock r; unlock r;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Check(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("expected no issues but got %v", issues)
+	}
+}
+
+func TestUnlockOfUnlocked(t *testing.T) {
+	s := `
+def main(): letsync mu mutex; if lock mu; else tau; endif; unlock mu;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Check(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(issues); want != got {
+		t.Fatalf("expected %d issue but got %d: %v", want, got, issues)
+	}
+	if want, got := UnlockOfUnlocked, issues[0].Kind; want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+	if want, got := "if else", strings.Join(issues[0].Path, ", "); want != got {
+		t.Errorf("expected path %q but got %q", want, got)
+	}
+}
+
+func TestDoubleLock(t *testing.T) {
+	s := `
+def main(): letsync mu mutex; lock mu; call f(mu); unlock mu;
+def f(m): select case recv ch; lock m; case tau; endselect;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Check(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(issues); want != got {
+		t.Fatalf("expected %d issue but got %d: %v", want, got, issues)
+	}
+	if want, got := DoubleLock, issues[0].Kind; want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+	if want, got := "f", issues[0].Func; want != got {
+		t.Errorf("expected issue in %s but got %s", want, got)
+	}
+	if want, got := "call f, select case recv ch", strings.Join(issues[0].Path, ", "); want != got {
+		t.Errorf("expected path %q but got %q", want, got)
+	}
+	if want, got := "main.mu", issues[0].Mutex; want != got {
+		t.Errorf("expected mutex %s but got %s", want, got)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	s := `
+def main(): letsync rw rwmutex; spawn w(rw);
+def w(r): rlock r; lock r; unlock r; runlock r;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Check(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(issues); want != got {
+		t.Fatalf("expected %d issue but got %d: %v", want, got, issues)
+	}
+	if want, got := Upgrade, issues[0].Kind; want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+	if want, got := "main > w", strings.Join(issues[0].Goroutine, " > "); want != got {
+		t.Errorf("expected goroutine %s but got %s", want, got)
+	}
+}
+
+func TestLeakedLock(t *testing.T) {
+	s := `
+def main(): spawn w(); call acquire(); unlock g;
+def acquire(): lock g;
+def w(): if lock g; unlock g; else lock g; endif;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Check(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]Kind)
+	for _, issue := range issues {
+		kinds[issue.Func] = issue.Kind
+	}
+	if want, got := 2, len(issues); want != got {
+		t.Fatalf("expected %d issues but got %d: %v", want, got, issues)
+	}
+	for _, fn := range []string{"acquire", "w"} {
+		if kind, ok := kinds[fn]; !ok || kind != LeakedLock {
+			t.Errorf("expected leaked lock in %s but got %v", fn, issues)
+		}
+	}
+}
+
+func TestSpawnBindings(t *testing.T) {
+	// One spawn statement, reached with the same mutex for both parameters.
+	s := `
+def main(): letsync a mutex; letsync b mutex; call s(a, b); call s(a, a);
+def s(x, y): spawn w(x, y);
+def w(x, y): lock x; lock y; unlock y; unlock x;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Check(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(issues); want != got {
+		t.Fatalf("expected %d issue but got %d: %v", want, got, issues)
+	}
+	if want, got := DoubleLock, issues[0].Kind; want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+}
+
+func TestBranchBindings(t *testing.T) {
+	// mu is bound in a branch, and used after it.
+	s := `
+def main(): if letsync mu mutex; else tau; endif; lock mu; lock mu;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Check(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(issues); want != got {
+		t.Fatalf("expected %d issue but got %d: %v", want, got, issues)
+	}
+	if want, got := DoubleLock, issues[0].Kind; want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+}
@@ -0,0 +1,464 @@
+// Package pointsto computes which allocation sites the channel, memory and
+// mutex names of a MiGo program may denote.
+//
+// The analysis is flow-insensitive and context-sensitive in the style of
+// k-CFA: a function is analysed once per context, i.e. the last k call or
+// spawn statements leading to it, and a name denotes the union of the
+// sites it is bound to in the context, whatever the statement using it.
+// With k = 0 the analysis is context-insensitive.
+//
+// Names which are not bound in a function by a parameter or a let-like
+// statement are free, and denote a single global site.
+//
+// The result is the binding of names to sites shared by the other analyses:
+// they walk the functions in the contexts of the result, follow calls and
+// spawns with Callee, and look up the names of statements with PointsTo.
+package pointsto
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/alias"
+)
+
+// Kind is the kind of an allocation site.
+type Kind int
+
+// Kinds of allocation sites.
+const (
+	Chan    Kind = iota // let newchan.
+	Mem                 // letmem.
+	Mutex               // letsync mutex.
+	RWMutex             // letsync rwmutex.
+	Free                // Free name.
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Chan:
+		return "chan"
+	case Mem:
+		return "mem"
+	case Mutex:
+		return "mutex"
+	case RWMutex:
+		return "rwmutex"
+	case Free:
+		return "free"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Site is an allocation site, or a free name.
+type Site struct {
+	Kind Kind
+	Name string         // Name bound by the statement, or the free name.
+	Func string         // Function containing Stmt, empty for free names.
+	Stmt migo.Statement // Allocating statement, nil for free names.
+}
+
+func (s *Site) String() string {
+	if s.Kind == Free {
+		return s.Name
+	}
+	return fmt.Sprintf("%s.%s", s.Func, s.Name)
+}
+
+// Context is a calling context of a function, i.e. the last call or spawn
+// statements leading to it from the entry function.
+type Context struct {
+	Calls []migo.Statement // Outermost first.
+	key   string
+}
+
+// Key returns a string identifying c among the contexts of a Result.
+func (c Context) Key() string {
+	return c.key
+}
+
+func (c Context) String() string {
+	calls := make([]string, len(c.Calls))
+	for i, call := range c.Calls {
+		calls[i] = call.String()
+	}
+	return "[" + strings.Join(calls, "; ") + "]"
+}
+
+// Pointer is a name of a function in a context.
+type Pointer struct {
+	Func    *migo.Function
+	Context Context
+	Name    string
+}
+
+func (p Pointer) String() string {
+	return fmt.Sprintf("%s%s: %s", p.Func.Name, p.Context, p.Name)
+}
+
+// Result is the result of the analysis. It is not modified by queries, so
+// it may be queried concurrently.
+type Result struct {
+	K int // Length of contexts.
+
+	prog     *migo.Program
+	ids      map[migo.Statement]int // Call and spawn statements, for context keys.
+	sites    []*Site
+	bySite   map[interface{}]*Site // By statement or free name.
+	contexts map[*migo.Function][]Context
+	pts      map[ptrKey]map[*Site]bool
+	pointers []Pointer // Pointers in order of discovery.
+	bound    map[*migo.Function]map[string]bool
+}
+
+type ptrKey struct {
+	fn   *migo.Function
+	ctx  string
+	name string
+}
+
+// Analyse computes the points-to sets of Program prog from the entry
+// function, with contexts of length k.
+//
+// Returns a *migo.ErrFuncNotFound error if entry is not in prog, and a
+// *migo.ErrUnsupportedStatement error if prog contains a statement kind
+// that is unknown and not a migo.ExtStatement.
+func Analyse(prog *migo.Program, entry string, k int) (*Result, error) {
+	fn, found := prog.Function(entry)
+	if !found {
+		return nil, &migo.ErrFuncNotFound{Name: entry}
+	}
+	if k < 0 {
+		k = 0
+	}
+	a := &analyser{
+		prog: prog,
+		res: &Result{
+			K:        k,
+			prog:     prog,
+			ids:      make(map[migo.Statement]int),
+			bySite:   make(map[interface{}]*Site),
+			contexts: make(map[*migo.Function][]Context),
+			pts:      make(map[ptrKey]map[*Site]bool),
+			bound:    make(map[*migo.Function]map[string]bool),
+		},
+		seen: make(map[string]bool),
+	}
+	a.enter(fn, Context{})
+	for changed := true; changed; {
+		changed = false
+		for i := 0; i < len(a.nodes); i++ {
+			c, err := a.visitStmts(a.nodes[i], a.nodes[i].fn.Stmts)
+			if err != nil {
+				return nil, err
+			}
+			changed = changed || c
+		}
+	}
+	return a.res, nil
+}
+
+// Sites returns the allocation sites and free names of the program, in
+// the order they are found.
+func (r *Result) Sites() []*Site {
+	return r.sites
+}
+
+// Site returns the allocation site of stmt, a newchan, letmem or letsync
+// statement, or nil if stmt is not reachable from the entry function.
+func (r *Result) Site(stmt migo.Statement) *Site {
+	return r.bySite[stmt]
+}
+
+// Contexts returns the contexts function fn is analysed in, or nil if fn is
+// not reachable from the entry function.
+func (r *Result) Contexts(fn *migo.Function) []Context {
+	return r.contexts[fn]
+}
+
+// Callee returns the function called or spawned by stmt, a call or spawn
+// statement of a function in context ctx, and the context of the callee
+// for the call. Returns false if stmt is not a call or spawn of a function
+// of the program which is reachable from the entry function.
+func (r *Result) Callee(ctx Context, stmt migo.Statement) (*migo.Function, Context, bool) {
+	var name string
+	switch stmt := stmt.(type) {
+	case *migo.CallStatement:
+		name = stmt.Name
+	case *migo.SpawnStatement:
+		name = stmt.Name
+	default:
+		return nil, Context{}, false
+	}
+	callee, found := r.prog.Function(name)
+	if _, reachable := r.ids[stmt]; !found || !reachable {
+		return nil, Context{}, false
+	}
+	return callee, r.push(ctx, stmt), true
+}
+
+// PointsTo returns the sites name may denote in function fn in context ctx.
+func (r *Result) PointsTo(fn *migo.Function, ctx Context, name string) []*Site {
+	if !r.isBound(fn, name) {
+		if s, ok := r.bySite[name]; ok {
+			return []*Site{s}
+		}
+		return nil
+	}
+	return r.sorted(r.pts[ptrKey{fn: fn, ctx: ctx.key, name: name}])
+}
+
+// PointsToAny returns the sites name may denote in function fn in any
+// context.
+func (r *Result) PointsToAny(fn *migo.Function, name string) []*Site {
+	set := make(map[*Site]bool)
+	for _, ctx := range r.contexts[fn] {
+		for _, s := range r.PointsTo(fn, ctx, name) {
+			set[s] = true
+		}
+	}
+	return r.sorted(set)
+}
+
+// MayAlias returns true if name1 in function fn1 and name2 in function fn2
+// may denote the same site, in any context.
+func (r *Result) MayAlias(fn1 *migo.Function, name1 string, fn2 *migo.Function, name2 string) bool {
+	sites := make(map[*Site]bool)
+	for _, s := range r.PointsToAny(fn1, name1) {
+		sites[s] = true
+	}
+	for _, s := range r.PointsToAny(fn2, name2) {
+		if sites[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// Pointers returns the names of functions in contexts which may denote
+// site, excluding free names.
+func (r *Result) Pointers(site *Site) []Pointer {
+	var ps []Pointer
+	for _, p := range r.pointers {
+		if r.pts[ptrKey{fn: p.Func, ctx: p.Context.key, name: p.Name}][site] {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// Names returns the bound names of function fn in context ctx which may
+// denote a site, in sorted order.
+func (r *Result) Names(fn *migo.Function, ctx Context) []string {
+	var names []string
+	for _, p := range r.pointers {
+		key := ptrKey{fn: fn, ctx: ctx.key, name: p.Name}
+		if p.Func == fn && p.Context.key == ctx.key && len(r.pts[key]) > 0 {
+			names = append(names, p.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Result) isBound(fn *migo.Function, name string) bool {
+	bound, ok := r.bound[fn]
+	if !ok {
+		bound = alias.Bound(fn)
+	}
+	return bound[name]
+}
+
+// sorted returns the sites in set in the order they are found.
+func (r *Result) sorted(set map[*Site]bool) []*Site {
+	var sites []*Site
+	for _, s := range r.sites {
+		if set[s] {
+			sites = append(sites, s)
+		}
+	}
+	return sites
+}
+
+type node struct {
+	fn  *migo.Function
+	ctx Context
+}
+
+type analyser struct {
+	prog  *migo.Program
+	res   *Result
+	nodes []node
+	seen  map[string]bool // Nodes by function and context key.
+}
+
+// enter adds function fn in context ctx to the nodes, and returns true if
+// it is new.
+func (a *analyser) enter(fn *migo.Function, ctx Context) bool {
+	key := fn.Name + "|" + ctx.key
+	if a.seen[key] {
+		return false
+	}
+	a.seen[key] = true
+	a.nodes = append(a.nodes, node{fn: fn, ctx: ctx})
+	a.res.contexts[fn] = append(a.res.contexts[fn], ctx)
+	if _, ok := a.res.bound[fn]; !ok {
+		a.res.bound[fn] = alias.Bound(fn)
+	}
+	return true
+}
+
+// push returns the context of a call from ctx by stmt, whose identifier
+// is assigned by the analysis.
+func (r *Result) push(ctx Context, stmt migo.Statement) Context {
+	if r.K == 0 {
+		return Context{}
+	}
+	calls := append(ctx.Calls[:len(ctx.Calls):len(ctx.Calls)], stmt)
+	if len(calls) > r.K {
+		calls = calls[len(calls)-r.K:]
+	}
+	keys := make([]string, len(calls))
+	for i, call := range calls {
+		keys[i] = strconv.Itoa(r.ids[call])
+	}
+	return Context{Calls: calls, key: strings.Join(keys, ",")}
+}
+
+func (a *analyser) site(key interface{}, kind Kind, name, fn string, stmt migo.Statement) *Site {
+	if _, ok := a.res.bySite[key]; !ok {
+		a.res.bySite[key] = &Site{Kind: kind, Name: name, Func: fn, Stmt: stmt}
+		a.res.sites = append(a.res.sites, a.res.bySite[key])
+	}
+	return a.res.bySite[key]
+}
+
+// add adds sites to the points-to set of name in n, and returns true if
+// it changed.
+func (a *analyser) add(n node, name string, sites ...*Site) bool {
+	key := ptrKey{fn: n.fn, ctx: n.ctx.key, name: name}
+	set, ok := a.res.pts[key]
+	if !ok {
+		set = make(map[*Site]bool)
+		a.res.pts[key] = set
+		a.res.pointers = append(a.res.pointers, Pointer{Func: n.fn, Context: n.ctx, Name: name})
+	}
+	changed := false
+	for _, s := range sites {
+		if !set[s] {
+			set[s] = true
+			changed = true
+		}
+	}
+	return changed
+}
+
+// lookup returns the sites name may denote in n.
+func (a *analyser) lookup(n node, name string) []*Site {
+	if !a.res.isBound(n.fn, name) {
+		return []*Site{a.site(name, Free, name, "", nil)}
+	}
+	return a.res.sorted(a.res.pts[ptrKey{fn: n.fn, ctx: n.ctx.key, name: name}])
+}
+
+// use records a use of name in n, so free names have a site.
+func (a *analyser) use(n node, name string) {
+	if !a.res.isBound(n.fn, name) {
+		a.site(name, Free, name, "", nil)
+	}
+}
+
+// visitStmts visits the statements of n, and returns true if any
+// points-to set or the nodes changed.
+func (a *analyser) visitStmts(n node, stmts []migo.Statement) (bool, error) {
+	changed := false
+	for _, stmt := range stmts {
+		var c bool
+		var err error
+		switch stmt := stmt.(type) {
+		case *migo.NewChanStatement:
+			c = a.add(n, stmt.Name.Name(), a.site(stmt, Chan, stmt.Name.Name(), n.fn.Name, stmt))
+		case *migo.NewMem:
+			c = a.add(n, stmt.Name.Name(), a.site(stmt, Mem, stmt.Name.Name(), n.fn.Name, stmt))
+		case *migo.NewSyncMutex:
+			c = a.add(n, stmt.Name.Name(), a.site(stmt, Mutex, stmt.Name.Name(), n.fn.Name, stmt))
+		case *migo.NewSyncRWMutex:
+			c = a.add(n, stmt.Name.Name(), a.site(stmt, RWMutex, stmt.Name.Name(), n.fn.Name, stmt))
+		case *migo.SendStatement:
+			a.use(n, stmt.Chan)
+		case *migo.RecvStatement:
+			a.use(n, stmt.Chan)
+		case *migo.CloseStatement:
+			a.use(n, stmt.Chan)
+		case *migo.MemRead:
+			a.use(n, stmt.Name)
+		case *migo.MemWrite:
+			a.use(n, stmt.Name)
+		case *migo.SyncMutexLock:
+			a.use(n, stmt.Name)
+		case *migo.SyncMutexUnlock:
+			a.use(n, stmt.Name)
+		case *migo.SyncRWMutexRLock:
+			a.use(n, stmt.Name)
+		case *migo.SyncRWMutexRUnlock:
+			a.use(n, stmt.Name)
+		case *migo.CallStatement:
+			c = a.call(n, stmt, stmt.Name, stmt.Params)
+		case *migo.SpawnStatement:
+			c = a.call(n, stmt, stmt.Name, stmt.Params)
+		case *migo.IfStatement:
+			c, err = a.visitBlocks(n, stmt.Then, stmt.Else)
+		case *migo.IfForStatement:
+			c, err = a.visitBlocks(n, stmt.Then, stmt.Else)
+		case *migo.SelectStatement:
+			c, err = a.visitBlocks(n, stmt.Cases...)
+		case migo.ExtStatement:
+			c, err = a.visitBlocks(n, stmt.Blocks()...)
+		case *migo.TauStatement:
+		default:
+			return false, &migo.ErrUnsupportedStatement{Func: n.fn.Name, Stmt: stmt}
+		}
+		if err != nil {
+			return false, err
+		}
+		changed = changed || c
+	}
+	return changed, nil
+}
+
+func (a *analyser) visitBlocks(n node, blocks ...[]migo.Statement) (bool, error) {
+	changed := false
+	for _, block := range blocks {
+		c, err := a.visitStmts(n, block)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || c
+	}
+	return changed, nil
+}
+
+// call binds the parameters of the function called or spawned by stmt in n.
+func (a *analyser) call(n node, stmt migo.Statement, name string, params []*migo.Parameter) bool {
+	callee, found := a.prog.Function(name)
+	if !found {
+		return false
+	}
+	if _, ok := a.res.ids[stmt]; !ok {
+		a.res.ids[stmt] = len(a.res.ids)
+	}
+	m := node{fn: callee, ctx: a.res.push(n.ctx, stmt)}
+	changed := a.enter(m.fn, m.ctx)
+	for i, p := range params {
+		if i >= len(callee.Params) {
+			break
+		}
+		if a.add(m, callee.Params[i].Callee.Name(), a.lookup(n, p.Caller.Name())...) {
+			changed = true
+		}
+	}
+	return changed
+}
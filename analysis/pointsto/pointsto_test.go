@@ -0,0 +1,207 @@
+package pointsto
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+// Two channels reach id through wrap, which k = 1 does not tell apart.
+const wrapped = `
+def main():
+	let a = newchan a, 0;
+	let b = newchan b, 0;
+	call wrap(a);
+	call wrap(b);
+def wrap(x):
+	call id(x);
+def id(y):
+	send y;
+`
+
+func TestContextSensitivity(t *testing.T) {
+	tests := []struct {
+		k        int
+		contexts int
+		sites    string // Sites of y in each context of id.
+	}{
+		{0, 1, "[[main.a main.b]]"},
+		{1, 1, "[[main.a main.b]]"},
+		{2, 2, "[[main.a] [main.b]]"},
+		{3, 2, "[[main.a] [main.b]]"},
+	}
+	for _, test := range tests {
+		prog, err := parser.Parse(strings.NewReader(wrapped))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := Analyse(prog, "main", test.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := prog.Function("id")
+		contexts := res.Contexts(id)
+		if len(contexts) != test.contexts {
+			t.Errorf("k=%d: expected %d contexts of id but got %v", test.k, test.contexts, contexts)
+			continue
+		}
+		var sites [][]*Site
+		for _, ctx := range contexts {
+			sites = append(sites, res.PointsTo(id, ctx, "y"))
+		}
+		if got := fmt.Sprint(sites); got != test.sites {
+			t.Errorf("k=%d: expected %s but got %s", test.k, test.sites, got)
+		}
+		if got := fmt.Sprint(res.PointsToAny(id, "y")); got != "[main.a main.b]" {
+			t.Errorf("k=%d: expected y to denote both channels but got %s", test.k, got)
+		}
+	}
+}
+
+func TestQueries(t *testing.T) {
+	s := `
+def main():
+	let ch = newchan ch, 1;
+	letmem m;
+	letsync mu mutex;
+	letsync rw rwmutex;
+	spawn worker(ch, m, mu);
+	call reader(rw);
+	send g;
+def worker(c, x, l):
+	lock l;
+	write x;
+	unlock l;
+	recv c;
+def reader(r):
+	rlock r;
+	runlock r;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Analyse(prog, "main", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, _ := prog.Function("main")
+	worker, _ := prog.Function("worker")
+	reader, _ := prog.Function("reader")
+
+	var kinds []string
+	for _, s := range res.Sites() {
+		kinds = append(kinds, fmt.Sprintf("%s %s", s.Kind, s))
+	}
+	if want, got := "chan main.ch, mem main.m, mutex main.mu, rwmutex main.rw, free g", strings.Join(kinds, ", "); want != got {
+		t.Errorf("expected sites %s but got %s", want, got)
+	}
+	if got := res.Site(main.Stmts[0]); got == nil || got.Name != "ch" {
+		t.Errorf("expected site of newchan but got %v", got)
+	}
+
+	ctx := res.Contexts(worker)[0]
+	if want, got := "[spawn worker(ch, m, mu)]", ctx.String(); want != got {
+		t.Errorf("expected context %s but got %s", want, got)
+	}
+	if want, got := "[c l x]", fmt.Sprint(res.Names(worker, ctx)); want != got {
+		t.Errorf("expected names %s but got %s", want, got)
+	}
+	if want, got := "[main.mu]", fmt.Sprint(res.PointsTo(worker, ctx, "l")); want != got {
+		t.Errorf("expected l to denote %s but got %s", want, got)
+	}
+	if want, got := "[g]", fmt.Sprint(res.PointsTo(worker, ctx, "g")); want != got {
+		t.Errorf("expected free name g to denote %s but got %s", want, got)
+	}
+	if !res.MayAlias(main, "ch", worker, "c") || res.MayAlias(main, "mu", reader, "r") {
+		t.Errorf("unexpected aliasing")
+	}
+
+	callee, calleeCtx, ok := res.Callee(res.Contexts(main)[0], main.Stmts[4])
+	if !ok || callee != worker || calleeCtx.Key() != ctx.Key() {
+		t.Errorf("expected spawn to enter worker in %s but got %v %v", ctx, callee, calleeCtx)
+	}
+	if _, _, ok := res.Callee(ctx, main.Stmts[0]); ok {
+		t.Errorf("expected no callee of newchan")
+	}
+
+	var ptrs []string
+	for _, p := range res.Pointers(res.Site(main.Stmts[3])) {
+		ptrs = append(ptrs, p.String())
+	}
+	if want, got := "main[]: rw, reader[call reader(rw)]: r", strings.Join(ptrs, ", "); want != got {
+		t.Errorf("expected pointers %s but got %s", want, got)
+	}
+}
+
+func TestRecursion(t *testing.T) {
+	// Each iteration passes a new channel, from the same site.
+	s := `
+def main():
+	let a = newchan a, 0;
+	call loop(a);
+def loop(x):
+	let n = newchan n, 0;
+	if call loop(n); else call loop(x); endif;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Analyse(prog, "main", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop, _ := prog.Function("loop")
+	if want, got := "[main.a loop.n]", fmt.Sprint(res.PointsToAny(loop, "x")); want != got {
+		t.Errorf("expected x to denote %s but got %s", want, got)
+	}
+	if _, err := Analyse(prog, "missing", 1); err == nil {
+		t.Errorf("expected error for missing entry")
+	}
+}
+
+// Tests that queries do not modify the result, so they may run
+// concurrently (with -race).
+func TestConcurrentQueries(t *testing.T) {
+	s := `
+def main(): let ch = newchan ch, 0; call f(ch); spawn f(ch);
+def f(c): call g(c);
+def g(d): send d;
+def unused(): call g(x);
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Analyse(prog, "main", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _ := prog.Function("f")
+	unused, _ := prog.Function("unused")
+	var wg sync.WaitGroup
+	keys := make([]string, 4)
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, ctx := range res.Contexts(f) {
+				_, calleeCtx, _ := res.Callee(ctx, f.Stmts[0])
+				keys[i] += calleeCtx.Key() + ";"
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, k := range keys[1:] {
+		if k != keys[0] {
+			t.Errorf("expected the same contexts in each goroutine but got %v", keys)
+		}
+	}
+	if _, _, ok := res.Callee(Context{}, unused.Stmts[0]); ok {
+		t.Errorf("expected no callee of an unreachable call")
+	}
+}
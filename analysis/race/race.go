@@ -0,0 +1,499 @@
+// Package race finds potential data races on shared memory (letmem, read
+// and write) in MiGo programs.
+//
+// The analysis is Eraser-style: each access to a shared variable is
+// recorded with the set of mutexes the goroutine must hold at the access
+// (its lockset). Two accesses to the same variable from different
+// goroutines, at least one of them a write, race if their locksets have no
+// mutex in common, and neither access happens before the other.
+//
+// An access happens before the accesses of a goroutine spawned after it on
+// all paths. With Options.HappensBefore, an access also happens before the
+// accesses of another goroutine after it receives on a channel the
+// goroutine of the first access sends on, or closes, after the access on
+// all paths.
+//
+// Goroutines are analysed once per spawn statement and binding of their
+// parameters. A goroutine whose spawn statement may run more than once
+// (see ctrlflow.Repeated), e.g. in a loop, stands for several goroutines,
+// so its accesses may also race with each other. Branches of if and select
+// statements are merged, a lock is in the lockset after a branch if it is
+// held at the end of all the branches, and a name bound in a branch may
+// denote the object of any of them.
+package race
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/alias"
+	"github.com/JorgeGCoelho/migo/v3/internal/ctrlflow"
+)
+
+// Options are the options of the analysis.
+type Options struct {
+	HappensBefore bool // Order accesses by channel communication.
+}
+
+// Access is an access to a shared variable.
+type Access struct {
+	Write     bool
+	Func      string         // Function of Stmt.
+	Stmt      migo.Statement // read or write statement.
+	Goroutine []string       // Spawn chain of the goroutine, entry first.
+	Path      []string       // Call path from the goroutine function to Func.
+	Locks     []string       // Locks held at the access.
+
+	g        *goroutine
+	locks    map[*object]bool
+	acquired map[*object]bool // Channels received on and spawns before the access.
+	released map[*object]bool // Channels sent on and spawns after the access, on all paths.
+}
+
+func (a *Access) String() string {
+	kind := "read"
+	if a.Write {
+		kind = "write"
+	}
+	locks := "no locks"
+	if len(a.Locks) > 0 {
+		locks = "locks " + strings.Join(a.Locks, ", ")
+	}
+	return fmt.Sprintf("%s in [%s] via %s holding %s", kind,
+		strings.Join(a.Goroutine, " > "), strings.Join(a.Path, " > "), locks)
+}
+
+// Race is a pair of accesses to a shared variable which may race.
+type Race struct {
+	Mem           string // Name of the variable, qualified by its function if local.
+	First, Second *Access
+}
+
+func (r Race) String() string {
+	return fmt.Sprintf("race on %s:\n    %s\n    %s", r.Mem, r.First, r.Second)
+}
+
+// Detect returns the potential races of Program prog, starting from the
+// function entry, at most one per pair of statements.
+//
+// Returns a *migo.ErrFuncNotFound error if entry is not in prog, and a
+// *migo.ErrUnsupportedStatement error if prog contains a statement kind
+// that is unknown.
+func Detect(prog *migo.Program, entry string, opts Options) ([]Race, error) {
+	entryFn, found := prog.Function(entry)
+	if !found {
+		return nil, &migo.ErrFuncNotFound{Name: entry}
+	}
+	repeated, err := ctrlflow.Repeated(prog)
+	if err != nil {
+		return nil, err
+	}
+	d := &detector{
+		prog:     prog,
+		opts:     opts,
+		repeated: repeated,
+		binder:   alias.NewBinder(),
+		accesses: make(map[*object][]*Access),
+		seen:     make(map[string]*Access),
+		active:   make(map[string]bool),
+		spawned:  make(map[string]*object),
+	}
+	d.queue = []*goroutine{{chain: []string{entryFn.Name}, fn: entryFn, env: alias.Env{}, acquired: map[*object]bool{}}}
+	for len(d.queue) > 0 {
+		var g *goroutine
+		g, d.queue = d.queue[0], d.queue[1:]
+		init := &state{locks: map[*object]bool{}, wlocks: map[*object]bool{}, acquired: g.acquired, done: map[*Access]map[*object]bool{}}
+		s, err := d.visitFunc(g, g.fn, g.env, init, []string{g.fn.Name})
+		if err != nil {
+			return nil, err
+		}
+		for a, released := range s.done {
+			a.released = released
+		}
+	}
+	return d.races(), nil
+}
+
+// object is an allocation site of a variable, mutex or channel, a free
+// name, or a spawn statement.
+type object = alias.Object
+
+// goroutine is a goroutine started by the entry or a spawn statement.
+type goroutine struct {
+	chain    []string
+	fn       *migo.Function
+	env      alias.Env
+	acquired map[*object]bool // Inherited from the spawning goroutine.
+	repeated bool             // Stands for several goroutines.
+}
+
+// state is the state of a goroutine at a statement.
+type state struct {
+	locks    map[*object]bool // Locks held, for reading or writing.
+	wlocks   map[*object]bool // Locks held for writing.
+	acquired map[*object]bool // Channels received on and spawns, on all paths.
+
+	// Accesses which may have happened, with the channels sent on and
+	// spawns after them, on all paths. The sets are shared between states,
+	// and copied before they are changed.
+	done map[*Access]map[*object]bool
+}
+
+func (s *state) copy() *state {
+	done := make(map[*Access]map[*object]bool, len(s.done))
+	for a, released := range s.done {
+		done[a] = released
+	}
+	return &state{
+		locks:    copySet(s.locks),
+		wlocks:   copySet(s.wlocks),
+		acquired: copySet(s.acquired),
+		done:     done,
+	}
+}
+
+// release returns s with o sent on or spawned after the accesses done.
+func (s *state) release(o *object) *state {
+	s = s.copy()
+	for a, released := range s.done {
+		released = copySet(released)
+		released[o] = true
+		s.done[a] = released
+	}
+	return s
+}
+
+func copySet(m map[*object]bool) map[*object]bool {
+	m2 := make(map[*object]bool, len(m))
+	for k := range m {
+		m2[k] = true
+	}
+	return m2
+}
+
+// merge returns the state after alternative branches.
+func merge(states []*state) *state {
+	s := states[0].copy()
+	for _, t := range states[1:] {
+		intersect(s.locks, t.locks)
+		intersect(s.wlocks, t.wlocks)
+		intersect(s.acquired, t.acquired)
+		for a, released := range t.done {
+			if r, ok := s.done[a]; ok {
+				r = copySet(r)
+				intersect(r, released)
+				released = r
+			}
+			s.done[a] = released
+		}
+	}
+	return s
+}
+
+func intersect(m, other map[*object]bool) {
+	for k := range m {
+		if !other[k] {
+			delete(m, k)
+		}
+	}
+}
+
+func setKey(m map[*object]bool) string {
+	ids := make([]string, 0, len(m))
+	for o := range m {
+		ids = append(ids, fmt.Sprint(o.ID))
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+type detector struct {
+	prog     *migo.Program
+	opts     Options
+	binder   *alias.Binder
+	accesses map[*object][]*Access
+	seen     map[string]*Access
+	active   map[string]bool
+	spawned  map[string]*object // Spawns of the goroutines queued, by spawn statement and env.
+	queue    []*goroutine
+	repeated map[*migo.SpawnStatement]bool // Spawns which may run more than once.
+}
+
+func (d *detector) visitFunc(g *goroutine, fn *migo.Function, e alias.Env, s *state, path []string) (*state, error) {
+	ctx := fmt.Sprintf("%p|%s|%s", g, fn.Name, e.Key())
+	if d.active[ctx] {
+		return s, nil // Recursive call, its accesses are already recorded.
+	}
+	d.active[ctx] = true
+	defer delete(d.active, ctx)
+	s, _, err := d.visitStmts(g, fn, []alias.Env{e}, s, path, fn.Stmts)
+	return s, err
+}
+
+// visitStmts visits stmts from state s, with the alternative Envs es of the
+// names of fn, and returns the state and the alternative Envs at the end of
+// stmts.
+func (d *detector) visitStmts(g *goroutine, fn *migo.Function, es []alias.Env, s *state, path []string, stmts []migo.Statement) (*state, []alias.Env, error) {
+	var err error
+	for _, stmt := range stmts {
+		for i, e := range es {
+			es[i] = d.binder.Let(fn, e, stmt)
+		}
+		switch stmt := stmt.(type) {
+		case *migo.MemRead:
+			for _, mem := range d.lookup(fn, es, stmt.Name) {
+				s = d.access(g, fn, mem, s, path, stmt, false)
+			}
+		case *migo.MemWrite:
+			for _, mem := range d.lookup(fn, es, stmt.Name) {
+				s = d.access(g, fn, mem, s, path, stmt, true)
+			}
+		case *migo.SyncMutexLock:
+			if o := d.unique(fn, es, stmt.Name); o != nil {
+				s = s.copy()
+				s.locks[o], s.wlocks[o] = true, true
+			}
+		case *migo.SyncRWMutexRLock:
+			if o := d.unique(fn, es, stmt.Name); o != nil {
+				s = s.copy()
+				s.locks[o] = true
+			}
+		case *migo.SyncMutexUnlock:
+			if os := d.lookup(fn, es, stmt.Name); len(os) > 0 {
+				s = s.copy()
+				for _, o := range os {
+					delete(s.locks, o)
+					delete(s.wlocks, o)
+				}
+			}
+		case *migo.SyncRWMutexRUnlock:
+			if os := d.lookup(fn, es, stmt.Name); len(os) > 0 {
+				s = s.copy()
+				for _, o := range os {
+					delete(s.locks, o)
+				}
+			}
+		case *migo.SendStatement:
+			s = d.release(d.unique(fn, es, stmt.Chan), s)
+		case *migo.CloseStatement:
+			s = d.release(d.unique(fn, es, stmt.Chan), s)
+		case *migo.RecvStatement:
+			if o := d.unique(fn, es, stmt.Chan); o != nil && d.opts.HappensBefore {
+				s = s.copy()
+				s.acquired[o] = true
+			}
+		case *migo.CallStatement:
+			callee, found := d.prog.Function(stmt.Name)
+			if !found {
+				continue
+			}
+			calleePath := append(path[:len(path):len(path)], callee.Name)
+			states := make([]*state, len(es))
+			for i, e := range es {
+				if states[i], err = d.visitFunc(g, callee, d.binder.Bind(fn, e, callee, stmt.Params), s, calleePath); err != nil {
+					return nil, nil, err
+				}
+			}
+			s = merge(states)
+		case *migo.SpawnStatement:
+			callee, found := d.prog.Function(stmt.Name)
+			if !found {
+				continue
+			}
+			for _, e := range es {
+				s = d.spawn(g, fn, e, s, callee, stmt)
+			}
+		case *migo.IfStatement:
+			s, es, err = d.visitBlocks(g, fn, es, s, path, stmt.Then, stmt.Else)
+		case *migo.IfForStatement:
+			s, es, err = d.visitBlocks(g, fn, es, s, path, stmt.Then, stmt.Else)
+		case *migo.SelectStatement:
+			s, es, err = d.visitBlocks(g, fn, es, s, path, stmt.Cases...)
+		case migo.ExtStatement:
+			s, es, err = d.visitBlocks(g, fn, es, s, path, stmt.Blocks()...)
+		case *migo.TauStatement, *migo.NewChanStatement, *migo.NewMem,
+			*migo.NewSyncMutex, *migo.NewSyncRWMutex:
+		default:
+			return nil, nil, &migo.ErrUnsupportedStatement{Func: fn.Name, Stmt: stmt}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return s, es, nil
+}
+
+// visitBlocks visits each of the alternative blocks from state s, and
+// returns the merged state and the distinct Envs at the end of each of
+// them, so names bound in a block are kept after it.
+func (d *detector) visitBlocks(g *goroutine, fn *migo.Function, es []alias.Env, s *state, path []string, blocks ...[]migo.Statement) (*state, []alias.Env, error) {
+	if len(blocks) == 0 {
+		return s, es, nil
+	}
+	states := make([]*state, len(blocks))
+	var envs []alias.Env
+	seen := make(map[string]bool)
+	for i, block := range blocks {
+		var exitEnvs []alias.Env
+		var err error
+		if states[i], exitEnvs, err = d.visitStmts(g, fn, append([]alias.Env(nil), es...), s, path, block); err != nil {
+			return nil, nil, err
+		}
+		for _, e := range exitEnvs {
+			if k := e.Key(); !seen[k] {
+				seen[k] = true
+				envs = append(envs, e)
+			}
+		}
+	}
+	return merge(states), envs, nil
+}
+
+// lookup returns the distinct objects name may denote in fn, in any of the
+// alternative Envs es.
+func (d *detector) lookup(fn *migo.Function, es []alias.Env, name string) []*object {
+	var os []*object
+	seen := make(map[*object]bool)
+	for _, e := range es {
+		if o := d.binder.Lookup(fn, e, name); o != nil && !seen[o] {
+			seen[o] = true
+			os = append(os, o)
+		}
+	}
+	return os
+}
+
+// unique returns the object name denotes in fn in all the alternative Envs
+// es, or nil if it may denote different objects, as locks held and
+// happens-before hold on all paths.
+func (d *detector) unique(fn *migo.Function, es []alias.Env, name string) *object {
+	var o *object
+	for _, e := range es {
+		o2 := d.binder.Lookup(fn, e, name)
+		if o2 == nil || o != nil && o2 != o {
+			return nil
+		}
+		o = o2
+	}
+	return o
+}
+
+// spawn queues the goroutine of callee spawned by stmt from fn with Env e,
+// if not yet queued, and returns s with the spawn after the accesses done.
+func (d *detector) spawn(g *goroutine, fn *migo.Function, e alias.Env, s *state, callee *migo.Function, stmt *migo.SpawnStatement) *state {
+	calleeEnv := d.binder.Bind(fn, e, callee, stmt.Params)
+	key := fmt.Sprintf("%p|%s", stmt, calleeEnv.Key())
+	if spawn, ok := d.spawned[key]; ok {
+		return s.release(spawn)
+	}
+	spawn := d.binder.New("spawn "+callee.Name, fn.Name)
+	d.spawned[key] = spawn
+	s = s.release(spawn)
+	acquired := copySet(s.acquired)
+	acquired[spawn] = true
+	d.queue = append(d.queue, &goroutine{
+		chain:    append(g.chain[:len(g.chain):len(g.chain)], callee.Name),
+		fn:       callee,
+		env:      calleeEnv,
+		acquired: acquired,
+		repeated: g.repeated || d.repeated[stmt],
+	})
+	return s
+}
+
+// release returns s with the accesses done before a send or close on ch
+// happening before receives on ch.
+func (d *detector) release(ch *object, s *state) *state {
+	if ch == nil || !d.opts.HappensBefore {
+		return s
+	}
+	return s.release(ch)
+}
+
+func (d *detector) access(g *goroutine, fn *migo.Function, mem *object, s *state, path []string, stmt migo.Statement, write bool) *state {
+	locks := s.locks
+	if write {
+		locks = s.wlocks
+	}
+	key := fmt.Sprintf("%p|%p|%d|%s|%s|%s", g, stmt, mem.ID, strings.Join(path, ">"), setKey(locks), setKey(s.acquired))
+	a, ok := d.seen[key]
+	if !ok {
+		a = &Access{
+			Write:     write,
+			Func:      fn.Name,
+			Stmt:      stmt,
+			Goroutine: g.chain,
+			Path:      path,
+			g:         g,
+			locks:     copySet(locks),
+			acquired:  copySet(s.acquired),
+		}
+		for o := range locks {
+			a.Locks = append(a.Locks, o.String())
+		}
+		sort.Strings(a.Locks)
+		d.seen[key] = a
+		d.accesses[mem] = append(d.accesses[mem], a)
+	}
+	if released, ok := s.done[a]; ok && len(released) == 0 {
+		return s
+	}
+	// Only the sends and spawns after the last time a is done order it.
+	s = s.copy()
+	s.done[a] = map[*object]bool{}
+	return s
+}
+
+// happensBefore returns true if access a happens before access b.
+func happensBefore(a, b *Access) bool {
+	for k := range a.released {
+		if b.acquired[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// races returns the pairs of accesses which may race.
+func (d *detector) races() []Race {
+	var mems []*object
+	for mem := range d.accesses {
+		mems = append(mems, mem)
+	}
+	sort.Slice(mems, func(i, j int) bool { return mems[i].ID < mems[j].ID })
+	var races []Race
+	type pair struct{ a, b migo.Statement }
+	found := make(map[pair]bool)
+	for _, mem := range mems {
+		as := d.accesses[mem]
+		for i, a := range as {
+			// Accesses of a goroutine standing for several goroutines
+			// may race with each other, and with themselves.
+			for _, b := range as[i:] {
+				if (a.g == b.g && !a.g.repeated) || (!a.Write && !b.Write) {
+					continue
+				}
+				if commonLock(a, b) || happensBefore(a, b) || happensBefore(b, a) {
+					continue
+				}
+				if found[pair{a.Stmt, b.Stmt}] || found[pair{b.Stmt, a.Stmt}] {
+					continue
+				}
+				found[pair{a.Stmt, b.Stmt}] = true
+				races = append(races, Race{Mem: mem.String(), First: a, Second: b})
+			}
+		}
+	}
+	return races
+}
+
+func commonLock(a, b *Access) bool {
+	for o := range a.locks {
+		if b.locks[o] {
+			return true
+		}
+	}
+	return false
+}
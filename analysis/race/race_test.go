@@ -0,0 +1,256 @@
+package race
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+func TestRace(t *testing.T) {
+	s := `
+def main(): letmem x; spawn w(x); read x;
+def w(y): write y;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err := Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(races); want != got {
+		t.Fatalf("expected %d race but got %d: %v", want, got, races)
+	}
+	if want, got := "main.x", races[0].Mem; want != got {
+		t.Errorf("expected race on %s but got %s", want, got)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	s := `
+def main(): letmem x; spawn r(x); spawn r(x); read x;
+def r(y): read y;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err := Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(races) != 0 {
+		t.Errorf("expected no races between reads but got %v", races)
+	}
+}
+
+func TestLockset(t *testing.T) {
+	s := `
+def main(): letmem x; letsync mu mutex; spawn w(x, mu); spawn w(x, mu); lock mu; read x; unlock mu;
+def w(y, m): lock m; call inc(y); unlock m;
+def inc(z): read z; write z;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err := Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(races) != 0 {
+		t.Errorf("expected no races under a common lock but got %v", races)
+	}
+	s = `
+def main(): letmem x; letsync mu mutex; spawn w(x, mu); lock mu; write x; unlock mu;
+def w(y, m): if lock m; write y; unlock m; else write y; endif;
+`
+	prog, err = parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err = Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(races); want != got {
+		t.Errorf("expected %d race on unlocked branch but got %d: %v", want, got, races)
+	}
+}
+
+func TestRWLock(t *testing.T) {
+	s := `
+def main(): letmem x; letsync mu rwmutex; spawn r(x, mu); spawn r(x, mu); lock mu; write x; unlock mu;
+def r(y, m): rlock m; read y; runlock m;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err := Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(races) != 0 {
+		t.Errorf("expected no races but got %v", races)
+	}
+	s = `
+def main(): letmem x; letsync mu rwmutex; spawn r(x, mu); lock mu; write x; unlock mu;
+def r(y, m): rlock m; write y; runlock m;
+`
+	prog, err = parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err = Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(races); want != got {
+		t.Errorf("expected %d race on write under rlock but got %d: %v", want, got, races)
+	}
+}
+
+func TestHappensBefore(t *testing.T) {
+	s := `
+def main(): letmem x; let ch = newchan ch, 0; write x; spawn w(x, ch); write x; send ch;
+def w(y, c): recv c; write y;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err := Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(races); want != got {
+		t.Errorf("expected %d race without happens-before but got %d", want, got)
+	}
+	races, err = Detect(prog, "main", Options{HappensBefore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(races) != 0 {
+		t.Errorf("expected no races with happens-before but got %v", races)
+	}
+}
+
+func TestRepeatedSpawn(t *testing.T) {
+	// Each iteration spawns a worker, and the workers race.
+	s := `
+def main(): letmem x; call loop(x);
+def loop(x): if spawn w(x); call loop(x); else tau; endif;
+def w(x): write x;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err := Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(races); want != got {
+		t.Fatalf("expected %d race but got %d: %v", want, got, races)
+	}
+	if races[0].First.Stmt != races[0].Second.Stmt {
+		t.Errorf("expected the write to race with itself but got %v", races[0])
+	}
+	s = `
+def main(): letmem x; letsync mu mutex; call loop(x, mu);
+def loop(x, m): if spawn w(x, m); call loop(x, m); else tau; endif;
+def w(x, m): lock m; write x; unlock m;
+`
+	prog, err = parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err = Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(races) != 0 {
+		t.Errorf("expected no races under a common lock but got %v", races)
+	}
+}
+
+func TestSpawnBindings(t *testing.T) {
+	// One spawn statement, reached with different variables.
+	s := `
+def main(): letmem x; letmem y; call s(y); call s(x); read x;
+def s(v): spawn w(v);
+def w(z): write z;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err := Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(races); want != got {
+		t.Fatalf("expected %d race but got %d: %v", want, got, races)
+	}
+	if want, got := "main.x", races[0].Mem; want != got {
+		t.Errorf("expected race on %s but got %s", want, got)
+	}
+}
+
+func TestHappensBeforeBranch(t *testing.T) {
+	// The write is only ordered before w on the path which sends.
+	s := `
+def main(): letmem x; let ch = newchan ch, 0; spawn s(ch); spawn w(x, ch); write x; if send ch; else tau; endif;
+def s(c): send c;
+def w(y, c): recv c; write y;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err := Detect(prog, "main", Options{HappensBefore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(races); want != got {
+		t.Errorf("expected %d race on the path without send but got %d: %v", want, got, races)
+	}
+	s = `
+def main(): letmem x; let ch = newchan ch, 0; spawn s(ch); spawn w(x, ch); write x; if send ch; else send ch; endif;
+def s(c): send c;
+def w(y, c): recv c; write y;
+`
+	prog, err = parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err = Detect(prog, "main", Options{HappensBefore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(races) != 0 {
+		t.Errorf("expected no races with a send on every path but got %v", races)
+	}
+}
+
+func TestBranchBindings(t *testing.T) {
+	// x is bound in either branch, and shared after them.
+	s := `
+def main(): if letmem x; else letmem x; endif; spawn w(x); read x;
+def w(y): write y;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	races, err := Detect(prog, "main", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(races); want != got {
+		t.Errorf("expected %d race but got %d: %v", want, got, races)
+	}
+}
@@ -0,0 +1,402 @@
+// Package topology builds the communication topology of a MiGo program:
+// a graph of which goroutines send to, receive from or close which
+// channels, to review the concurrency architecture of a program at a
+// glance.
+//
+// Goroutines are identified by the function they run, i.e. the entry
+// function or a spawned function, so all goroutines spawned to run the
+// same function are one node. A goroutine acts on a channel if it does so
+// in the function it runs or in a function it calls. Channels are
+// identified by their creation site (a let newchan statement) or their
+// free name, and tracked through the parameters of calls and spawns by
+// the points-to analysis of package pointsto, with contexts of length k.
+//
+// The graph is flow-insensitive: an edge means the goroutine may act on
+// the channel, not that it does in every execution.
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/analysis/pointsto"
+)
+
+// Goroutine is a goroutine, or all goroutines running the same function.
+type Goroutine struct {
+	ID   string // Node identifier, unique in the graph.
+	Func string // Function run by the goroutine.
+}
+
+func (g *Goroutine) String() string {
+	return g.Func
+}
+
+// Channel is a channel of a program, i.e. a creation site or a free name.
+type Channel struct {
+	ID   string                 // Node identifier, unique in the graph.
+	Name string                 // Name the channel is bound to when created.
+	Func string                 // Function creating the channel, empty for free names.
+	Site *migo.NewChanStatement // Statement creating the channel, nil for free names.
+}
+
+// Size returns the buffer size of c, and false if c is a free name so its
+// size is unknown.
+func (c *Channel) Size() (int64, bool) {
+	if c.Site == nil {
+		return 0, false
+	}
+	return c.Site.Size, true
+}
+
+func (c *Channel) String() string {
+	if c.Func == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s.%s", c.Func, c.Name)
+}
+
+// Op is an action of a goroutine on a channel.
+type Op int
+
+// Actions on a channel.
+const (
+	Send Op = iota
+	Recv
+	Close
+)
+
+func (op Op) String() string {
+	switch op {
+	case Send:
+		return "send"
+	case Recv:
+		return "recv"
+	case Close:
+		return "close"
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// Edge is an action of a goroutine on a channel.
+type Edge struct {
+	Goroutine *Goroutine
+	Chan      *Channel
+	Op        Op
+	Select    bool             // The action is a case of a select statement.
+	Stmts     []migo.Statement // Statements taking the action.
+}
+
+// Label returns the label of e, with the action, the buffer size of the
+// channel and whether the action is a select case.
+func (e *Edge) Label() string {
+	label := e.Op.String()
+	if size, ok := e.Chan.Size(); ok && e.Op != Close {
+		if size == 0 {
+			label += ", unbuffered"
+		} else {
+			label += fmt.Sprintf(", buffer %d", size)
+		}
+	}
+	if e.Select {
+		label += ", select"
+	}
+	return label
+}
+
+func (e *Edge) String() string {
+	return fmt.Sprintf("%s → %s (%s)", e.Goroutine, e.Chan, e.Label())
+}
+
+// Spawn is an edge from a goroutine to a goroutine it spawns.
+type Spawn struct {
+	From, To *Goroutine
+}
+
+// Graph is the communication topology of a program.
+type Graph struct {
+	Goroutines []*Goroutine // In order of first spawn, entry first.
+	Chans      []*Channel   // In order of creation or first action.
+	Edges      []*Edge      // In order of first action.
+	Spawns     []Spawn      // In order of first spawn.
+}
+
+// Build builds the communication topology of Program prog from the entry
+// function, with points-to contexts of length k (see pointsto.Analyse).
+//
+// Returns a *migo.ErrFuncNotFound error if entry is not in prog, and a
+// *migo.ErrUnsupportedStatement error if prog contains a statement kind
+// that is unknown and not a migo.ExtStatement.
+func Build(prog *migo.Program, entry string, k int) (*Graph, error) {
+	pts, err := pointsto.Analyse(prog, entry, k)
+	if err != nil {
+		return nil, err
+	}
+	fn, _ := prog.Function(entry)
+	b := &builder{
+		pts:        pts,
+		graph:      &Graph{},
+		goroutines: make(map[string]*Goroutine),
+		chans:      make(map[*pointsto.Site]*Channel),
+		edges:      make(map[edgeKey]*Edge),
+		spawns:     make(map[Spawn]bool),
+		visited:    make(map[string]bool),
+	}
+	b.enter(b.goroutine(fn.Name), fn, pointsto.Context{})
+	for len(b.queue) > 0 {
+		var c context
+		c, b.queue = b.queue[0], b.queue[1:]
+		if err := b.visitStmts(c, c.fn.Stmts); err != nil {
+			return nil, err
+		}
+	}
+	return b.graph, nil
+}
+
+// DotString returns a string representation of the graph in dot format.
+//
+// Goroutines are boxes and channels are ellipses. Sends and closes point
+// from goroutines to channels, receives from channels to goroutines, and
+// spawns, dotted, from goroutines to goroutines.
+func (g *Graph) DotString() string {
+	var sb strings.Builder
+	sb.WriteString("digraph G {\n")
+	for _, gr := range g.Goroutines {
+		sb.WriteString(fmt.Sprintf("%s [label=%q, shape=box];\n", gr.ID, gr.Func))
+	}
+	for _, c := range g.Chans {
+		sb.WriteString(fmt.Sprintf("%s [label=%q, shape=ellipse];\n", c.ID, c.String()))
+	}
+	for _, s := range g.Spawns {
+		sb.WriteString(fmt.Sprintf("%s -> %s [label=\"spawn\", style=dotted];\n", s.From.ID, s.To.ID))
+	}
+	for _, e := range g.Edges {
+		switch e.Op {
+		case Recv:
+			sb.WriteString(fmt.Sprintf("%s -> %s [label=%q];\n", e.Chan.ID, e.Goroutine.ID, e.Label()))
+		case Close:
+			sb.WriteString(fmt.Sprintf("%s -> %s [label=%q, style=dashed];\n", e.Goroutine.ID, e.Chan.ID, e.Label()))
+		default:
+			sb.WriteString(fmt.Sprintf("%s -> %s [label=%q];\n", e.Goroutine.ID, e.Chan.ID, e.Label()))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+type jsonGraph struct {
+	Goroutines []jsonGoroutine `json:"goroutines"`
+	Chans      []jsonChan      `json:"channels"`
+	Edges      []jsonEdge      `json:"edges"`
+	Spawns     []jsonSpawn     `json:"spawns"`
+}
+
+type jsonGoroutine struct {
+	ID   string `json:"id"`
+	Func string `json:"func"`
+}
+
+type jsonChan struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`           // Creating function and name, or free name.
+	Size *int64 `json:"size,omitempty"` // Buffer size, omitted for free names.
+}
+
+type jsonEdge struct {
+	Goroutine string `json:"goroutine"`
+	Chan      string `json:"channel"`
+	Op        string `json:"op"`
+	Select    bool   `json:"select,omitempty"`
+}
+
+type jsonSpawn struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// WriteJSON writes the graph in JSON to w, with nodes referring to each
+// other by ID.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	jg := jsonGraph{
+		Goroutines: []jsonGoroutine{},
+		Chans:      []jsonChan{},
+		Edges:      []jsonEdge{},
+		Spawns:     []jsonSpawn{},
+	}
+	for _, gr := range g.Goroutines {
+		jg.Goroutines = append(jg.Goroutines, jsonGoroutine{ID: gr.ID, Func: gr.Func})
+	}
+	for _, c := range g.Chans {
+		jc := jsonChan{ID: c.ID, Name: c.String()}
+		if size, ok := c.Size(); ok {
+			jc.Size = &size
+		}
+		jg.Chans = append(jg.Chans, jc)
+	}
+	for _, e := range g.Edges {
+		jg.Edges = append(jg.Edges, jsonEdge{Goroutine: e.Goroutine.ID, Chan: e.Chan.ID, Op: e.Op.String(), Select: e.Select})
+	}
+	for _, s := range g.Spawns {
+		jg.Spawns = append(jg.Spawns, jsonSpawn{From: s.From.ID, To: s.To.ID})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jg)
+}
+
+// context is a function run by a goroutine, in a context of the points-to
+// analysis.
+type context struct {
+	g   *Goroutine
+	fn  *migo.Function
+	ctx pointsto.Context
+}
+
+type edgeKey struct {
+	g        *Goroutine
+	c        *Channel
+	op       Op
+	inSelect bool
+}
+
+type builder struct {
+	pts        *pointsto.Result
+	graph      *Graph
+	goroutines map[string]*Goroutine // By function.
+	chans      map[*pointsto.Site]*Channel
+	edges      map[edgeKey]*Edge
+	spawns     map[Spawn]bool
+	visited    map[string]bool // Contexts queued, by goroutine, function and context.
+	queue      []context
+}
+
+func (b *builder) goroutine(fn string) *Goroutine {
+	if _, ok := b.goroutines[fn]; !ok {
+		g := &Goroutine{ID: fmt.Sprintf("g%d", len(b.graph.Goroutines)), Func: fn}
+		b.goroutines[fn] = g
+		b.graph.Goroutines = append(b.graph.Goroutines, g)
+	}
+	return b.goroutines[fn]
+}
+
+// channel returns the node of site, a channel creation site or a free name.
+func (b *builder) channel(site *pointsto.Site) *Channel {
+	if _, ok := b.chans[site]; !ok {
+		c := &Channel{ID: fmt.Sprintf("c%d", len(b.graph.Chans)), Name: site.Name, Func: site.Func}
+		c.Site, _ = site.Stmt.(*migo.NewChanStatement)
+		b.chans[site] = c
+		b.graph.Chans = append(b.graph.Chans, c)
+	}
+	return b.chans[site]
+}
+
+// enter queues fn run by goroutine g in context ctx, if not already.
+func (b *builder) enter(g *Goroutine, fn *migo.Function, ctx pointsto.Context) {
+	key := g.Func + "|" + fn.Name + "|" + ctx.Key()
+	if !b.visited[key] {
+		b.visited[key] = true
+		b.queue = append(b.queue, context{g: g, fn: fn, ctx: ctx})
+	}
+}
+
+// action records the action op of stmt on channel name in c.
+func (b *builder) action(c context, name string, op Op, isCase bool, stmt migo.Statement) {
+	for _, site := range b.pts.PointsTo(c.fn, c.ctx, name) {
+		if site.Kind == pointsto.Chan || site.Kind == pointsto.Free {
+			b.edge(c.g, b.channel(site), op, isCase, stmt)
+		}
+	}
+}
+
+// edge records the action op of stmt by goroutine g on channel ch.
+func (b *builder) edge(g *Goroutine, ch *Channel, op Op, isCase bool, stmt migo.Statement) {
+	key := edgeKey{g: g, c: ch, op: op, inSelect: isCase}
+	e, ok := b.edges[key]
+	if !ok {
+		e = &Edge{Goroutine: g, Chan: ch, Op: op, Select: isCase}
+		b.edges[key] = e
+		b.graph.Edges = append(b.graph.Edges, e)
+	}
+	for _, s := range e.Stmts {
+		if s == stmt {
+			return
+		}
+	}
+	e.Stmts = append(e.Stmts, stmt)
+}
+
+func (b *builder) visitStmts(c context, stmts []migo.Statement) error {
+	for _, stmt := range stmts {
+		if err := b.visitStmt(c, stmt, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visitStmt visits stmt in c, which is the guard of a select case if
+// isCase.
+func (b *builder) visitStmt(c context, stmt migo.Statement, isCase bool) error {
+	switch stmt := stmt.(type) {
+	case *migo.NewChanStatement:
+		if site := b.pts.Site(stmt); site != nil {
+			b.channel(site)
+		}
+	case *migo.SendStatement:
+		b.action(c, stmt.Chan, Send, isCase, stmt)
+	case *migo.RecvStatement:
+		b.action(c, stmt.Chan, Recv, isCase, stmt)
+	case *migo.CloseStatement:
+		b.action(c, stmt.Chan, Close, false, stmt)
+	case *migo.CallStatement:
+		if callee, ctx, ok := b.pts.Callee(c.ctx, stmt); ok {
+			b.enter(c.g, callee, ctx)
+		}
+	case *migo.SpawnStatement:
+		if callee, ctx, ok := b.pts.Callee(c.ctx, stmt); ok {
+			g := b.goroutine(callee.Name)
+			if s := (Spawn{From: c.g, To: g}); !b.spawns[s] {
+				b.spawns[s] = true
+				b.graph.Spawns = append(b.graph.Spawns, s)
+			}
+			b.enter(g, callee, ctx)
+		}
+	case *migo.IfStatement:
+		return b.visitBlocks(c, stmt.Then, stmt.Else)
+	case *migo.IfForStatement:
+		return b.visitBlocks(c, stmt.Then, stmt.Else)
+	case *migo.SelectStatement:
+		for _, cse := range stmt.Cases {
+			if len(cse) == 0 {
+				continue
+			}
+			// The first statement of a case is its guard.
+			if err := b.visitStmt(c, cse[0], true); err != nil {
+				return err
+			}
+			if err := b.visitStmts(c, cse[1:]); err != nil {
+				return err
+			}
+		}
+	case migo.ExtStatement:
+		return b.visitBlocks(c, stmt.Blocks()...)
+	case *migo.TauStatement, *migo.NewMem, *migo.MemRead, *migo.MemWrite,
+		*migo.NewSyncMutex, *migo.SyncMutexLock, *migo.SyncMutexUnlock,
+		*migo.NewSyncRWMutex, *migo.SyncRWMutexRLock, *migo.SyncRWMutexRUnlock:
+	default:
+		return &migo.ErrUnsupportedStatement{Func: c.fn.Name, Stmt: stmt}
+	}
+	return nil
+}
+
+func (b *builder) visitBlocks(c context, blocks ...[]migo.Statement) error {
+	for _, block := range blocks {
+		if err := b.visitStmts(c, block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package topology
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+const pipeline = `
+def main():
+	let jobs = newchan jobs, 4;
+	let done = newchan done, 0;
+	spawn worker(jobs, done);
+	spawn worker(jobs, done);
+	call feed(jobs);
+	close jobs;
+	recv done;
+def feed(out):
+	send out;
+def worker(in, d):
+	select
+		case recv in; call worker(in, d);
+		case send d;
+	endselect;
+	send log;
+`
+
+func TestBuild(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(pipeline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Build(prog, "main", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var edges []string
+	for _, e := range g.Edges {
+		edges = append(edges, e.String())
+	}
+	want := []string{
+		"main → main.jobs (close)",
+		"main → main.done (recv, unbuffered)",
+		"worker → main.jobs (recv, buffer 4, select)",
+		"worker → main.done (send, unbuffered, select)",
+		"worker → log (send)",
+		// feed is visited after the functions main spawns.
+		"main → main.jobs (send, buffer 4)",
+	}
+	if strings.Join(want, "\n") != strings.Join(edges, "\n") {
+		t.Errorf("expected edges\n%s\nbut got\n%s", strings.Join(want, "\n"), strings.Join(edges, "\n"))
+	}
+	if want, got := 2, len(g.Goroutines); want != got {
+		t.Errorf("expected %d goroutines but got %v", want, g.Goroutines)
+	}
+	if len(g.Spawns) != 1 || g.Spawns[0].From.Func != "main" || g.Spawns[0].To.Func != "worker" {
+		t.Errorf("expected main to spawn worker but got %v", g.Spawns)
+	}
+	// The send in feed is by main, which calls it.
+	if send, ok := g.Edges[5].Stmts[0].(*migo.SendStatement); !ok || send.Chan != "out" {
+		t.Errorf("expected send on out but got %s", g.Edges[5].Stmts[0])
+	}
+}
+
+func TestDotString(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(pipeline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Build(prog, "main", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dot := g.DotString()
+	for _, want := range []string{
+		`g0 [label="main", shape=box];`,
+		`c0 [label="main.jobs", shape=ellipse];`,
+		`g0 -> g1 [label="spawn", style=dotted];`,
+		`g0 -> c0 [label="send, buffer 4"];`,
+		`g0 -> c0 [label="close", style=dashed];`,
+		`c0 -> g1 [label="recv, buffer 4, select"];`,
+	} {
+		if !strings.Contains(dot, want+"\n") {
+			t.Errorf("expected dot to contain %s, got:\n%s", want, dot)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(pipeline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err := Build(prog, "main", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := graph.WriteJSON(&sb); err != nil {
+		t.Fatal(err)
+	}
+	var g struct {
+		Channels []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			Size *int64 `json:"size"`
+		} `json:"channels"`
+		Edges []struct {
+			Goroutine string `json:"goroutine"`
+			Channel   string `json:"channel"`
+			Op        string `json:"op"`
+			Select    bool   `json:"select"`
+		} `json:"edges"`
+	}
+	if err := json.Unmarshal([]byte(sb.String()), &g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Channels) != 3 || *g.Channels[0].Size != 4 || g.Channels[2].Name != "log" || g.Channels[2].Size != nil {
+		t.Errorf("unexpected channels in %s", sb.String())
+	}
+	if e := g.Edges[2]; e.Goroutine != "g1" || e.Channel != "c0" || e.Op != "recv" || !e.Select {
+		t.Errorf("unexpected edge %+v", e)
+	}
+}
+
+func TestBuildAliasing(t *testing.T) {
+	// Channels from one site are one node, however they are passed.
+	s := `
+def main():
+	call mk();
+	call mk();
+def mk():
+	let c = newchan c, 1;
+	spawn w(c);
+	recv c;
+def w(x):
+	call f(x);
+def f(y):
+	send y;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Build(prog, "main", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(g.Chans); want != got {
+		t.Fatalf("expected %d channel but got %v", want, g.Chans)
+	}
+	if want, got := "w → mk.c (send, buffer 1)", g.Edges[1].String(); want != got {
+		t.Errorf("expected edge %s but got %s", want, got)
+	}
+	prog, _ = parser.Parse(strings.NewReader(`def main(): tau;`))
+	if _, err := Build(prog, "missing", 1); err == nil {
+		t.Errorf("expected error for missing entry")
+	}
+}
+
+func TestBuildContexts(t *testing.T) {
+	// Contexts tell apart the channels main and w send on through s.
+	prog, err := parser.Parse(strings.NewReader(`
+def main():
+	let a = newchan a, 0;
+	let b = newchan b, 0;
+	spawn w(b);
+	call s(a);
+def w(y):
+	call s(y);
+def s(x):
+	send x;
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		k     int
+		edges string
+	}{
+		{0, "main → main.a (send, unbuffered), main → main.b (send, unbuffered), w → main.a (send, unbuffered), w → main.b (send, unbuffered)"},
+		{1, "main → main.a (send, unbuffered), w → main.b (send, unbuffered)"},
+	} {
+		g, err := Build(prog, "main", test.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var edges []string
+		for _, e := range g.Edges {
+			edges = append(edges, e.String())
+		}
+		if got := strings.Join(edges, ", "); got != test.edges {
+			t.Errorf("k=%d: expected edges %s but got %s", test.k, test.edges, got)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Command migo works with MiGo models in .migo files.
+//
+// Usage:
+//
+//	migo repl [-entry fn] file.migo
+//	migo effects file.migo
+//	migo topology [-entry fn] [-k n] [-json] file.migo
+//
+// The repl command steps through the executions of a model by hand, by the
+// reduction semantics of MiGo (see package semantics). Type help at the
+// prompt for the commands.
+//
+// The effects command prints the effect summary of each function of a
+// model (see package analysis/effects).
+//
+// The topology command prints which goroutines of a model act on which
+// channels (see package analysis/topology), in dot format or in JSON, with
+// points-to contexts of length n.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/analysis/effects"
+	"github.com/JorgeGCoelho/migo/v3/analysis/topology"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migo repl [-entry fn] file.migo")
+	fmt.Fprintln(os.Stderr, "       migo effects file.migo")
+	fmt.Fprintln(os.Stderr, "       migo topology [-entry fn] [-k n] [-json] file.migo")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "repl":
+		fs := flag.NewFlagSet("repl", flag.ExitOnError)
+		entry := fs.String("entry", "main.main", "entry function")
+		fs.Usage = usage
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 1 {
+			usage()
+		}
+		if err := runREPL(fs.Arg(0), *entry, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "migo: %v\n", err)
+			os.Exit(1)
+		}
+	case "effects":
+		if len(os.Args) != 3 {
+			usage()
+		}
+		if err := runEffects(os.Args[2], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "migo: %v\n", err)
+			os.Exit(1)
+		}
+	case "topology":
+		fs := flag.NewFlagSet("topology", flag.ExitOnError)
+		entry := fs.String("entry", "main.main", "entry function")
+		k := fs.Int("k", 1, "length of points-to contexts")
+		asJSON := fs.Bool("json", false, "write JSON instead of dot")
+		fs.Usage = usage
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 1 {
+			usage()
+		}
+		if err := runTopology(fs.Arg(0), *entry, *k, *asJSON, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "migo: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
+
+// runEffects prints the effect summaries of the model in file.
+func runEffects(file string, out io.Writer) error {
+	prog, err := load(file)
+	if err != nil {
+		return err
+	}
+	res, err := effects.Summarise(prog)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, res.String())
+	return err
+}
+
+// runTopology prints the communication topology of the model in file from
+// function entry with points-to contexts of length k, in JSON if asJSON or
+// else in dot format.
+func runTopology(file, entry string, k int, asJSON bool, out io.Writer) error {
+	prog, err := load(file)
+	if err != nil {
+		return err
+	}
+	g, err := topology.Build(prog, entry, k)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return g.WriteJSON(out)
+	}
+	_, err = io.WriteString(out, g.DotString())
+	return err
+}
+
+// load parses the model in file.
+func load(file string) (*migo.Program, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parser.Parse(f)
+}
+
+// runREPL loads the model in file and runs the REPL from function entry.
+func runREPL(file, entry string, in io.Reader, out io.Writer) error {
+	prog, err := load(file)
+	if err != nil {
+		return err
+	}
+	init, err := semantics.Initial(prog, entry)
+	if err != nil {
+		return err
+	}
+	return newREPL(init, out).run(in)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunEffects(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "model.migo")
+	if err := os.WriteFile(file, []byte(model), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := runEffects(file, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "w(c):\n\tc: send, close\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+	}
+	if err := runEffects(filepath.Join(t.TempDir(), "missing.migo"), &out); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
+
+func TestRunTopology(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "model.migo")
+	if err := os.WriteFile(file, []byte(model), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var dot, js strings.Builder
+	if err := runTopology(file, "main.main", 1, false, &dot); err != nil {
+		t.Fatal(err)
+	}
+	if want := `g1 -> c0 [label="send, buffer 1"];`; !strings.Contains(dot.String(), want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, dot.String())
+	}
+	if err := runTopology(file, "main.main", 1, true, &js); err != nil {
+		t.Fatal(err)
+	}
+	if want := `"op": "close"`; !strings.Contains(js.String(), want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, js.String())
+	}
+	if err := runTopology(file, "missing", 1, false, &dot); err == nil {
+		t.Errorf("expected error for missing entry function")
+	}
+}
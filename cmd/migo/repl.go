@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+	"github.com/JorgeGCoelho/migo/v3/verify"
+)
+
+const replHelp = `commands:
+  state, s        show live goroutines, channels and mutexes
+  list, l         list enabled reductions
+  pick N, N       take enabled reduction N
+  undo [N], u     undo the last N reductions (default 1)
+  save NAME       save the current state as NAME
+  jump NAME, j    jump to the state saved as NAME
+  saves           list saved states
+  trace, t        show the reductions taken
+  help, h         show this help
+  quit, q         quit
+`
+
+// snapshot is a point of the execution, i.e. the reductions taken from
+// the initial state.
+type snapshot struct {
+	states []*semantics.State // Initial state, then the state after each step.
+	steps  []semantics.Transition
+}
+
+func (s snapshot) current() *semantics.State {
+	return s.states[len(s.states)-1]
+}
+
+// repl steps through the executions of a model interactively.
+type repl struct {
+	out     io.Writer
+	at      snapshot
+	enabled []semantics.Transition // Successors of the current state.
+	saved   map[string]snapshot
+}
+
+func newREPL(init *semantics.State, out io.Writer) *repl {
+	r := &repl{out: out, saved: make(map[string]snapshot)}
+	r.moveTo(snapshot{states: []*semantics.State{init}})
+	return r
+}
+
+func (r *repl) moveTo(s snapshot) {
+	r.at = s
+	r.enabled = semantics.Successors(s.current())
+}
+
+// run reads commands from in until quit or the end of input.
+func (r *repl) run(in io.Reader) error {
+	sc := bufio.NewScanner(in)
+	r.printState()
+	r.printEnabled()
+	for {
+		fmt.Fprint(r.out, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(r.out)
+			return sc.Err()
+		}
+		if !r.exec(strings.Fields(sc.Text())) {
+			return nil
+		}
+	}
+}
+
+// exec executes a command, and returns false to quit.
+func (r *repl) exec(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	if _, err := strconv.Atoi(args[0]); err == nil {
+		args = append([]string{"pick"}, args...)
+	}
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "state", "s":
+		r.printState()
+	case "list", "l":
+		r.printEnabled()
+	case "pick", "p":
+		if len(r.enabled) == 0 {
+			fmt.Fprintln(r.out, "pick: no reductions enabled")
+			return true
+		}
+		n, err := intArg(args, -1)
+		if err != nil || n < 0 || n >= len(r.enabled) {
+			fmt.Fprintf(r.out, "pick: expected a reduction between 0 and %d\n", len(r.enabled)-1)
+			return true
+		}
+		t := r.enabled[n]
+		r.moveTo(snapshot{
+			states: append(r.at.states[:len(r.at.states):len(r.at.states)], t.Next),
+			steps:  append(r.at.steps[:len(r.at.steps):len(r.at.steps)], t),
+		})
+		r.printStep(len(r.at.steps), t)
+		r.printState()
+		r.printEnabled()
+	case "undo", "u":
+		n, err := intArg(args, 1)
+		if err != nil || n < 1 {
+			fmt.Fprintln(r.out, "undo: expected a positive number of reductions")
+			return true
+		}
+		if n > len(r.at.steps) {
+			n = len(r.at.steps)
+		}
+		k := len(r.at.steps) - n
+		r.moveTo(snapshot{states: r.at.states[:k+1], steps: r.at.steps[:k]})
+		fmt.Fprintf(r.out, "undid %d reductions\n", n)
+		r.printState()
+		r.printEnabled()
+	case "save":
+		if len(args) != 1 {
+			fmt.Fprintln(r.out, "save: expected a name")
+			return true
+		}
+		r.saved[args[0]] = r.at
+		fmt.Fprintf(r.out, "saved %s at step %d\n", args[0], len(r.at.steps))
+	case "jump", "j":
+		if len(args) != 1 {
+			fmt.Fprintln(r.out, "jump: expected a name")
+			return true
+		}
+		s, ok := r.saved[args[0]]
+		if !ok {
+			fmt.Fprintf(r.out, "jump: no state saved as %s\n", args[0])
+			return true
+		}
+		r.moveTo(s)
+		fmt.Fprintf(r.out, "jumped to %s at step %d\n", args[0], len(s.steps))
+		r.printState()
+		r.printEnabled()
+	case "saves":
+		names := make([]string, 0, len(r.saved))
+		for name := range r.saved {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(r.out, "%s: step %d\n", name, len(r.saved[name].steps))
+		}
+	case "trace", "t":
+		for i, t := range r.at.steps {
+			r.printStep(i+1, t)
+		}
+	case "help", "h", "?":
+		fmt.Fprint(r.out, replHelp)
+	case "quit", "q", "exit":
+		return false
+	default:
+		fmt.Fprintf(r.out, "unknown command %s, type help for the commands\n", cmd)
+	}
+	return true
+}
+
+// intArg returns the single integer argument, or def if there is none.
+func intArg(args []string, def int) (int, error) {
+	switch len(args) {
+	case 0:
+		return def, nil
+	case 1:
+		return strconv.Atoi(args[0])
+	}
+	return 0, fmt.Errorf("too many arguments")
+}
+
+// printState prints the live goroutines with their next statement, and
+// the channels and mutexes of the current state.
+func (r *repl) printState() {
+	s := r.at.current()
+	fmt.Fprintf(r.out, "step %d\n", len(r.at.steps))
+	fmt.Fprintln(r.out, "goroutines:")
+	live := verify.NewTrace(nil, s).Blocked
+	for _, a := range live {
+		fmt.Fprintf(r.out, "    %s\n", a)
+	}
+	if len(live) == 0 {
+		fmt.Fprintln(r.out, "    all terminated")
+	}
+	var chans, mutexes []string
+	for _, o := range s.Objects {
+		switch o.Kind {
+		case semantics.Chan:
+			chans = append(chans, o.String())
+		case semantics.Mutex, semantics.RWMutex:
+			mutexes = append(mutexes, o.String())
+		}
+	}
+	printList(r.out, "channels", chans)
+	printList(r.out, "mutexes", mutexes)
+}
+
+func printList(w io.Writer, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, item := range items {
+		fmt.Fprintf(w, "    %s\n", item)
+	}
+}
+
+// printEnabled prints the enabled reductions of the current state.
+func (r *repl) printEnabled() {
+	s := r.at.current()
+	if len(r.enabled) == 0 {
+		if faults := semantics.Faults(s); len(faults) > 0 {
+			fmt.Fprintln(r.out, "no reductions: runtime fault")
+			for _, l := range faults {
+				fmt.Fprintf(r.out, "    %s\n", l)
+			}
+			return
+		}
+		switch {
+		case s.Goroutines[0].Terminated():
+			fmt.Fprintln(r.out, "no reductions: entry terminated")
+		default:
+			fmt.Fprintln(r.out, "no reductions: deadlock")
+		}
+		return
+	}
+	fmt.Fprintln(r.out, "enabled:")
+	for i, t := range r.enabled {
+		fmt.Fprintf(r.out, "  [%d] %s\n", i, describeStep(t))
+	}
+}
+
+func (r *repl) printStep(n int, t semantics.Transition) {
+	fmt.Fprintf(r.out, "%d. %s\n", n, describeStep(t))
+}
+
+// describeStep describes reduction t with the goroutines reducing and
+// their Go source positions.
+func describeStep(t semantics.Transition) string {
+	step := verify.NewTrace([]semantics.Transition{t}, nil).Steps[0]
+	actors := make([]string, len(step.Actors))
+	for i, a := range step.Actors {
+		actors[i] = a.String()
+	}
+	return strings.Join(actors, " ⇄ ")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+const model = `
+def main.main():
+	let ch = newchan ch, 1;
+	spawn w(ch);
+	recv ch;
+	recv ch;
+def w(c):
+	send c;
+	close c;
+`
+
+func TestREPL(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(model))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range prog.Funcs {
+		if send, ok := fn.Stmts[0].(*migo.SendStatement); ok {
+			send.Pos = token.Position{Filename: "w.go", Line: 7, Column: 2}
+		}
+	}
+	init, err := semantics.Initial(prog, "main.main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	script := "0\n0\nsave spawned\n0\nstate\nundo\n1\nbogus\njump spawned\n0\n0\n0\n0\ntrace\nq\n"
+	if err := newREPL(init, &out).run(strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"  [0] g0 [main.main] let ch = newchan ch, 1",
+		"saved spawned at step 2",
+		// w sends to the buffer.
+		"3. g1 [main.main > w] send c at w.go:7:2",
+		"    chan ch [1/1]",
+		"undid 1 reductions",
+		"pick: expected a reduction between 0 and 0",
+		"unknown command bogus",
+		"jumped to spawned at step 2",
+		"    g0 [main.main] recv ch\n",
+		"    chan ch [0/1, closed]",
+		"no reductions: entry terminated",
+		"4. g1 [main.main > w] close c\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestREPLFault(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(`
+def main.main():
+	let ch = newchan ch, 0;
+	close ch;
+	close ch;
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	init, err := semantics.Initial(prog, "main.main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := newREPL(init, &out).run(strings.NewReader("0\n0\nq\n")); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	if want := "no reductions: runtime fault\n    g0: close ch"; !strings.Contains(got, want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, got)
+	}
+	if strings.Contains(got, "deadlock") {
+		t.Errorf("expected no deadlock, got:\n%s", got)
+	}
+}
+
+func TestRunREPL(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "model.migo")
+	if err := os.WriteFile(file, []byte(model), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := runREPL(file, "main.main", strings.NewReader("l\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "enabled:\n  [0]") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+	if err := runREPL(file, "missing", strings.NewReader(""), &out); err == nil {
+		t.Errorf("expected error for missing entry function")
+	}
+}
@@ -0,0 +1,156 @@
+// Package equiv checks behavioural equivalence of MiGo programs, by
+// comparing the transition systems given by the semantics package.
+//
+// Transitions are labelled by their action without goroutine identifiers,
+// e.g. "send ch", "comm ch" or "tau" (see semantics.Label.Action), so
+// programs are compared by the channels, memory and mutexes they act on,
+// named as they are bound when created. States where the entry goroutine
+// terminates have an additional "exit" transition, so a program which
+// exits is distinguished from one which deadlocks.
+//
+// # Usage
+//
+// To check that a transformation preserves behaviour up to τ:
+//
+//	res, err := equiv.Check(prog, simplified, "main.main", equiv.WeakBisimulation)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	if !res.Equivalent {
+//		fmt.Println(res)
+//	}
+package equiv
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/explore"
+)
+
+// DefaultMaxStates is the limit of states explored for each program used
+// if none is given in Config.
+const DefaultMaxStates = 100000
+
+// Mode is an equivalence between programs.
+type Mode int
+
+// Equivalences checked.
+const (
+	// StrongBisimulation relates programs which match each other's
+	// actions, including τ, step by step.
+	StrongBisimulation Mode = iota
+	// WeakBisimulation is StrongBisimulation where τ actions are internal,
+	// i.e. an action may be matched by the same action preceded and
+	// followed by any number of τ actions.
+	WeakBisimulation
+	// TraceInclusion relates a program to another if every sequence of
+	// actions of the first, ignoring τ, is a sequence of the second.
+	TraceInclusion
+)
+
+func (m Mode) String() string {
+	switch m {
+	case StrongBisimulation:
+		return "strong bisimulation"
+	case WeakBisimulation:
+		return "weak bisimulation"
+	case TraceInclusion:
+		return "trace inclusion"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Step is an action of a distinguishing trace.
+type Step struct {
+	Prog   int    // Program taking the action, 1 or 2.
+	Action string // Action, e.g. "send ch".
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("p%d: %s", s.Prog, s.Action)
+}
+
+// Result is the result of an equivalence check.
+type Result struct {
+	Mode Mode
+	// Equivalent is true if the programs are equivalent, or for
+	// TraceInclusion if the traces of the first program are traces of the
+	// second.
+	Equivalent bool
+	// Trace distinguishes the programs if they are not equivalent: each
+	// action is taken by a program and matched by the other, except the
+	// last one which the other program cannot match.
+	//
+	// For bisimulations, the trace is a play of the bisimulation game, so
+	// the program taking an action may change, and the matching action is
+	// the one which keeps the programs alike the longest.
+	Trace    []Step
+	States   [2]int // Number of states explored of each program.
+	Complete bool   // All reachable states of both programs are explored.
+}
+
+func (r *Result) String() string {
+	if r.Equivalent {
+		return fmt.Sprintf("equivalent by %s", r.Mode)
+	}
+	steps := make([]string, len(r.Trace))
+	for i, s := range r.Trace {
+		steps[i] = s.String()
+	}
+	last := r.Trace[len(r.Trace)-1]
+	return fmt.Sprintf("not equivalent by %s: %s, not matched by p%d", r.Mode, strings.Join(steps, "; "), 3-last.Prog)
+}
+
+// Config is the configuration of equivalence checks.
+type Config struct {
+	MaxStates int           // Maximum number of states explored of each program, DefaultMaxStates if not positive.
+	Timeout   time.Duration // Maximum duration of exploration of each program, 0 for no limit.
+}
+
+// Check checks whether Programs p1 and p2 from function entry are related
+// by mode, exploring at most DefaultMaxStates states of each program.
+func Check(p1, p2 *migo.Program, entry string, mode Mode) (*Result, error) {
+	return Config{}.Check(p1, p2, entry, mode)
+}
+
+// Check checks whether Programs p1 and p2 from function entry are related
+// by mode.
+//
+// Bisimulations are checked by partition refinement over the disjoint
+// union of the transition systems, and TraceInclusion by the subset
+// construction. If the exploration is not Complete, states not explored
+// have no transitions, so the programs may be reported to differ.
+func (c Config) Check(p1, p2 *migo.Program, entry string, mode Mode) (*Result, error) {
+	opts := explore.Options{MaxStates: c.MaxStates, Timeout: c.Timeout}
+	if opts.MaxStates <= 0 {
+		opts.MaxStates = DefaultMaxStates
+	}
+	l1, err := newLTS(p1, entry, opts)
+	if err != nil {
+		return nil, err
+	}
+	l2, err := newLTS(p2, entry, opts)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{
+		Mode:     mode,
+		States:   [2]int{len(l1.edges), len(l2.edges)},
+		Complete: l1.complete && l2.complete,
+	}
+	switch mode {
+	case StrongBisimulation:
+		res.Trace = bisimulation(l1, l2)
+	case WeakBisimulation:
+		res.Trace = bisimulation(l1.saturate(), l2.saturate())
+	case TraceInclusion:
+		res.Trace = traceInclusion(l1, l2)
+	default:
+		return nil, fmt.Errorf("equiv: unknown mode %s", mode)
+	}
+	res.Equivalent = res.Trace == nil
+	return res, nil
+}
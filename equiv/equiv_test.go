@@ -0,0 +1,156 @@
+package equiv_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/equiv"
+	"github.com/JorgeGCoelho/migo/v3/migoutil"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+// choice returns a program which sends on a, then either on b or c, with
+// the choice made before sending on a if early.
+func choice(early bool) string {
+	if early {
+		return `
+def main.main():
+	let a = newchan a, 1;
+	let b = newchan b, 1;
+	let c = newchan c, 1;
+	if send a; send b; else send a; send c; endif;
+`
+	}
+	return `
+def main.main():
+	let a = newchan a, 1;
+	let b = newchan b, 1;
+	let c = newchan c, 1;
+	send a;
+	select
+		case send b;
+		case send c;
+	endselect;
+`
+}
+
+func TestCheck(t *testing.T) {
+	tauPrefix := `
+def main.main():
+	let a = newchan a, 1;
+	tau;
+	call f(a);
+def f(x):
+	send x;
+`
+	plain := `
+def main.main():
+	let a = newchan a, 1;
+	send a;
+`
+	deadlock := `
+def main.main():
+	let a = newchan a, 0;
+	send a;
+`
+	tests := []struct {
+		name   string
+		p1, p2 string
+		mode   equiv.Mode
+		want   bool
+		trace  string
+	}{
+		{"same strong", plain, plain, equiv.StrongBisimulation, true, ""},
+		{"tau strong", tauPrefix, plain, equiv.StrongBisimulation, false, "p1: tau, not matched by p2"},
+		{"tau weak", tauPrefix, plain, equiv.WeakBisimulation, true, ""},
+		{"tau traces", tauPrefix, plain, equiv.TraceInclusion, true, ""},
+		{"choice weak", choice(true), choice(false), equiv.WeakBisimulation, false, ""},
+		{"choice traces", choice(true), choice(false), equiv.TraceInclusion, true, ""},
+		{"choice traces reverse", choice(false), choice(true), equiv.TraceInclusion, true, ""},
+		{"deadlock", plain, deadlock, equiv.TraceInclusion, false, "trace inclusion: p1: send a, not matched by p2"},
+	}
+	for _, test := range tests {
+		p1, err := parser.Parse(strings.NewReader(test.p1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p2, err := parser.Parse(strings.NewReader(test.p2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := equiv.Check(p1, p2, "main.main", test.mode)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !res.Complete {
+			t.Errorf("%s: expected complete exploration", test.name)
+		}
+		if res.Equivalent != test.want {
+			t.Errorf("%s: expected equivalent %t but got %s", test.name, test.want, res)
+			continue
+		}
+		if !test.want && len(res.Trace) == 0 {
+			t.Errorf("%s: expected distinguishing trace", test.name)
+		}
+		if test.trace != "" && !strings.HasSuffix(res.String(), test.trace) {
+			t.Errorf("%s: expected trace %q but got %s", test.name, test.trace, res)
+		}
+	}
+}
+
+func TestCheckGame(t *testing.T) {
+	// After send a, p2 commits to b or c, which p1 does not.
+	p1, err := parser.Parse(strings.NewReader(choice(false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := parser.Parse(strings.NewReader(choice(true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := equiv.Check(p1, p2, "main.main", equiv.WeakBisimulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Equivalent {
+		t.Fatal("expected programs to differ")
+	}
+	var actions []string
+	for _, s := range res.Trace {
+		actions = append(actions, s.Action)
+	}
+	got := strings.Join(actions, ", ")
+	if !strings.Contains(got, "send a") || !(strings.HasSuffix(got, "send b") || strings.HasSuffix(got, "send c")) {
+		t.Errorf("unexpected distinguishing trace %s", res)
+	}
+}
+
+func TestCheckSimplify(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan ch, 0;
+	spawn worker(ch);
+	call nothing();
+	recv ch;
+def worker(c):
+	call nothing();
+	send c;
+def nothing():
+	tau;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	simplified, err := migoutil.SimplifyProgram(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := equiv.Check(prog, simplified, "main.main", equiv.WeakBisimulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Equivalent {
+		t.Errorf("expected simplification to preserve behaviour, got %s", res)
+	}
+}
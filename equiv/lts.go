@@ -0,0 +1,158 @@
+package equiv
+
+import (
+	"sort"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/explore"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// Actions of the transition system besides those of reductions.
+const (
+	tau  = "tau"  // Internal action.
+	exit = "exit" // The entry goroutine terminates, so the program exits.
+)
+
+// edge is a transition of an lts.
+type edge struct {
+	action string
+	to     int
+}
+
+// lts is a labelled transition system, where state 0 is initial.
+type lts struct {
+	edges    [][]edge
+	complete bool
+}
+
+// newLTS explores the transition system of Program prog from function
+// entry, labelled by the actions of reductions (see semantics.Label.Action).
+//
+// States where the entry goroutine is terminated have a single exit
+// transition, to a state without transitions.
+func newLTS(prog *migo.Program, entry string, opts explore.Options) (*lts, error) {
+	init, err := semantics.Initial(prog, entry)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]int)
+	id := func(s *semantics.State) int {
+		key := s.Key()
+		if i, ok := ids[key]; ok {
+			return i
+		}
+		ids[key] = len(ids)
+		return ids[key]
+	}
+	id(init)
+	l := &lts{}
+	var exited []int
+	res := explore.BFS(init, opts, func(n *explore.Node, succs []semantics.Transition) bool {
+		from := id(n.State)
+		if n.State.Goroutines[0].Terminated() {
+			exited = append(exited, from)
+			return true
+		}
+		for _, t := range succs {
+			l.add(from, t.Label.Action(), id(t.Next))
+		}
+		return true
+	})
+	l.complete = res.Complete
+	if len(exited) > 0 {
+		final := len(ids)
+		for _, from := range exited {
+			l.add(from, exit, final)
+		}
+		l.grow(final)
+	}
+	l.grow(len(ids) - 1)
+	return l, nil
+}
+
+func (l *lts) grow(n int) {
+	for len(l.edges) <= n {
+		l.edges = append(l.edges, nil)
+	}
+}
+
+func (l *lts) add(from int, action string, to int) {
+	l.grow(from)
+	l.grow(to)
+	for _, e := range l.edges[from] {
+		if e.action == action && e.to == to {
+			return
+		}
+	}
+	l.edges[from] = append(l.edges[from], edge{action: action, to: to})
+}
+
+// union returns the disjoint union of l and m, where the states of m are
+// offset by the number of states of l.
+func union(l, m *lts) *lts {
+	u := &lts{edges: make([][]edge, 0, len(l.edges)+len(m.edges))}
+	u.edges = append(u.edges, l.edges...)
+	for _, es := range m.edges {
+		shifted := make([]edge, len(es))
+		for i, e := range es {
+			shifted[i] = edge{action: e.action, to: e.to + len(l.edges)}
+		}
+		u.edges = append(u.edges, shifted)
+	}
+	return u
+}
+
+// closure returns the states reachable from the states by tau transitions,
+// including the states, in increasing order.
+func (l *lts) closure(states ...int) []int {
+	seen := make(map[int]bool)
+	stack := append([]int(nil), states...)
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		for _, e := range l.edges[s] {
+			if e.action == tau {
+				stack = append(stack, e.to)
+			}
+		}
+	}
+	closed := make([]int, 0, len(seen))
+	for s := range seen {
+		closed = append(closed, s)
+	}
+	sort.Ints(closed)
+	return closed
+}
+
+// saturate returns the weak transition system of l, where s has a
+// transition to t by a visible action a if s reaches t by tau transitions,
+// a and tau transitions, and s has a tau transition to each state it
+// reaches by tau transitions, including itself.
+func (l *lts) saturate() *lts {
+	w := &lts{edges: make([][]edge, len(l.edges)), complete: l.complete}
+	closures := make([][]int, len(l.edges))
+	for s := range l.edges {
+		closures[s] = l.closure(s)
+	}
+	for s := range l.edges {
+		for _, t := range closures[s] {
+			w.add(s, tau, t)
+		}
+		for _, u := range closures[s] {
+			for _, e := range l.edges[u] {
+				if e.action == tau {
+					continue
+				}
+				for _, t := range closures[e.to] {
+					w.add(s, e.action, t)
+				}
+			}
+		}
+	}
+	return w
+}
@@ -0,0 +1,185 @@
+package equiv
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// refine partitions the states of l by bisimilarity, by refining the
+// partition with a single block until it is stable. It returns the blocks
+// of the states after each round, where states are in the same block after
+// round k if they cannot be distinguished in k steps.
+func refine(l *lts) [][]int {
+	blocks := make([]int, len(l.edges))
+	rounds := [][]int{blocks}
+	count := 1
+	for {
+		next := make([]int, len(l.edges))
+		ids := make(map[string]int)
+		for s, es := range l.edges {
+			sig := make([]string, 0, len(es)+1)
+			for _, e := range es {
+				sig = append(sig, e.action+" "+strconv.Itoa(blocks[e.to]))
+			}
+			sort.Strings(sig)
+			sig = append(sig, strconv.Itoa(blocks[s]))
+			key := strings.Join(dedup(sig), ",")
+			if _, ok := ids[key]; !ok {
+				ids[key] = len(ids)
+			}
+			next[s] = ids[key]
+		}
+		if len(ids) == count {
+			return rounds
+		}
+		blocks, count = next, len(ids)
+		rounds = append(rounds, blocks)
+	}
+}
+
+func dedup(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// bisimulation returns a play of the bisimulation game distinguishing the
+// initial states of l1 and l2, or nil if they are bisimilar.
+func bisimulation(l1, l2 *lts) []Step {
+	u := union(l1, l2)
+	rounds := refine(u)
+	// separated returns the first round s and t are in different blocks,
+	// or 0 if they are bisimilar.
+	separated := func(s, t int) int {
+		for k, blocks := range rounds {
+			if blocks[s] != blocks[t] {
+				return k
+			}
+		}
+		return 0
+	}
+	prog := func(s int) int {
+		if s < len(l1.edges) {
+			return 1
+		}
+		return 2
+	}
+	var trace []Step
+	s, t := 0, len(l1.edges)
+	for k := separated(s, t); k > 0; {
+		// A transition of s (or t) to a block after round k-1 which t (or
+		// s) cannot match exists, as they are in the same block after
+		// round k-1 but not after round k.
+		attacker, defender, e := s, t, attack(u, rounds[k-1], s, t)
+		if e == nil {
+			attacker, defender, e = t, s, attack(u, rounds[k-1], t, s)
+		}
+		trace = append(trace, Step{Prog: prog(attacker), Action: e.action})
+		// The defender matches the transition to the state separated last.
+		best, bestK := -1, -1
+		for _, d := range u.edges[defender] {
+			if d.action == e.action {
+				if dk := separated(e.to, d.to); dk > bestK {
+					best, bestK = d.to, dk
+				}
+			}
+		}
+		if best < 0 {
+			break
+		}
+		s, t, k = e.to, best, bestK
+	}
+	return trace
+}
+
+// attack returns a transition of s to a block which no transition of t
+// with the same action reaches, or nil if there is none.
+func attack(l *lts, blocks []int, s, t int) *edge {
+	for i, e := range l.edges[s] {
+		matched := false
+		for _, d := range l.edges[t] {
+			matched = matched || (d.action == e.action && blocks[d.to] == blocks[e.to])
+		}
+		if !matched {
+			return &l.edges[s][i]
+		}
+	}
+	return nil
+}
+
+// traceInclusion returns a shortest sequence of visible actions of l1
+// which is not a sequence of l2, or nil if there is none.
+func traceInclusion(l1, l2 *lts) []Step {
+	type pair struct {
+		s1, s2 []int // Sets of states reached.
+		prev   int   // Index of the previous pair, -1 if initial.
+		action string
+	}
+	key := func(s1, s2 []int) string {
+		return fmtInts(s1) + "|" + fmtInts(s2)
+	}
+	pairs := []pair{{s1: l1.closure(0), s2: l2.closure(0), prev: -1}}
+	seen := map[string]bool{key(pairs[0].s1, pairs[0].s2): true}
+	for i := 0; i < len(pairs); i++ {
+		p := pairs[i]
+		for _, a := range actions(l1, p.s1) {
+			s1, s2 := l1.closure(post(l1, p.s1, a)...), l2.closure(post(l2, p.s2, a)...)
+			if len(s2) == 0 {
+				trace := []Step{{Prog: 1, Action: a}}
+				for j := i; pairs[j].prev >= 0; j = pairs[j].prev {
+					trace = append([]Step{{Prog: 1, Action: pairs[j].action}}, trace...)
+				}
+				return trace
+			}
+			if k := key(s1, s2); !seen[k] {
+				seen[k] = true
+				pairs = append(pairs, pair{s1: s1, s2: s2, prev: i, action: a})
+			}
+		}
+	}
+	return nil
+}
+
+func fmtInts(xs []int) string {
+	strs := make([]string, len(xs))
+	for i, x := range xs {
+		strs[i] = strconv.Itoa(x)
+	}
+	return strings.Join(strs, " ")
+}
+
+// actions returns the visible actions of the states, in sorted order.
+func actions(l *lts, states []int) []string {
+	set := make(map[string]bool)
+	for _, s := range states {
+		for _, e := range l.edges[s] {
+			if e.action != tau {
+				set[e.action] = true
+			}
+		}
+	}
+	as := make([]string, 0, len(set))
+	for a := range set {
+		as = append(as, a)
+	}
+	sort.Strings(as)
+	return as
+}
+
+// post returns the states reached from the states by action a.
+func post(l *lts, states []int, a string) []int {
+	var next []int
+	for _, s := range states {
+		for _, e := range l.edges[s] {
+			if e.action == a {
+				next = append(next, e.to)
+			}
+		}
+	}
+	return next
+}
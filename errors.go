@@ -0,0 +1,23 @@
+package migo
+
+import "fmt"
+
+// ErrFuncNotFound is the error if a Function is not found in a Program.
+type ErrFuncNotFound struct {
+	Name string // Name of the Function.
+}
+
+func (e *ErrFuncNotFound) Error() string {
+	return fmt.Sprintf("function %s not found", e.Name)
+}
+
+// ErrUnsupportedStatement is the error if a Statement cannot be handled,
+// e.g. a user-defined Statement that does not implement ExtStatement.
+type ErrUnsupportedStatement struct {
+	Func string    // Name of the Function containing the Statement.
+	Stmt Statement // The unsupported Statement.
+}
+
+func (e *ErrUnsupportedStatement) Error() string {
+	return fmt.Sprintf("unsupported statement kind %T in function %s: %s", e.Stmt, e.Func, e.Stmt)
+}
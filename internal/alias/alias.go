@@ -0,0 +1,155 @@
+// Package alias tracks the local names that refer to a given set of
+// variables (channels, memory or mutexes) across function boundaries.
+//
+// The analysis is flow- and context-insensitive: a parameter of a function
+// is an alias if any call or spawn of the function passes an alias in its
+// place. Names that are not bound by a parameter or a let-like statement
+// of a function are global, and are aliases everywhere.
+//
+// Binder instead binds the names of each function to the objects they
+// denote along each path of calls and spawns, for the analyses which visit
+// functions once per binding of their parameters.
+package alias
+
+import "github.com/JorgeGCoelho/migo/v3"
+
+// Names is a set of local names per function.
+type Names map[*migo.Function]map[string]bool
+
+// Has returns true if name in function fn is an alias.
+func (n Names) Has(fn *migo.Function, name string) bool {
+	return n[fn][name]
+}
+
+// Track returns the names in each function of Program prog reachable from
+// entry that may refer to the variables roots of entry.
+//
+// A root that is not bound in entry is a global variable.
+func Track(prog *migo.Program, entry *migo.Function, roots ...string) Names {
+	t := tracker{prog: prog, names: make(Names), globals: make(map[string]bool)}
+	bound := Bound(entry)
+	for _, root := range roots {
+		if !bound[root] {
+			t.globals[root] = true
+		}
+	}
+	t.add(entry, roots...)
+	for len(t.queue) > 0 {
+		var fn *migo.Function
+		fn, t.queue = t.queue[0], t.queue[1:]
+		t.visitStmts(fn, fn.Stmts)
+	}
+	return t.names
+}
+
+type tracker struct {
+	prog    *migo.Program
+	names   Names
+	globals map[string]bool
+	queue   []*migo.Function
+}
+
+// add marks names as aliases in fn, and queues fn for visiting if
+// anything changed.
+func (t *tracker) add(fn *migo.Function, names ...string) {
+	changed := false
+	if _, visited := t.names[fn]; !visited {
+		t.names[fn] = make(map[string]bool)
+		// Free names in fn refer to the global variables.
+		bound := Bound(fn)
+		for global := range t.globals {
+			if !bound[global] {
+				t.names[fn][global] = true
+			}
+		}
+		changed = true
+	}
+	for _, name := range names {
+		if !t.names[fn][name] {
+			t.names[fn][name] = true
+			changed = true
+		}
+	}
+	if changed {
+		t.queue = append(t.queue, fn)
+	}
+}
+
+func (t *tracker) visitStmts(fn *migo.Function, stmts []migo.Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *migo.CallStatement:
+			t.visitCall(fn, stmt.Name, stmt.Params)
+		case *migo.SpawnStatement:
+			t.visitCall(fn, stmt.Name, stmt.Params)
+		case *migo.IfStatement:
+			t.visitStmts(fn, stmt.Then)
+			t.visitStmts(fn, stmt.Else)
+		case *migo.IfForStatement:
+			t.visitStmts(fn, stmt.Then)
+			t.visitStmts(fn, stmt.Else)
+		case *migo.SelectStatement:
+			for _, c := range stmt.Cases {
+				t.visitStmts(fn, c)
+			}
+		case migo.ExtStatement:
+			for _, b := range stmt.Blocks() {
+				t.visitStmts(fn, b)
+			}
+		}
+	}
+}
+
+func (t *tracker) visitCall(caller *migo.Function, name string, params []*migo.Parameter) {
+	callee, found := t.prog.Function(name)
+	if !found {
+		return
+	}
+	var names []string
+	for i, p := range params {
+		if i < len(callee.Params) && t.names[caller][p.Caller.Name()] {
+			names = append(names, callee.Params[i].Callee.Name())
+		}
+	}
+	t.add(callee, names...)
+}
+
+// Bound returns the names bound in function fn,
+// i.e. its parameters and the names introduced by newchan, letmem or letsync.
+func Bound(fn *migo.Function) map[string]bool {
+	bound := make(map[string]bool)
+	for _, p := range fn.Params {
+		bound[p.Callee.Name()] = true
+	}
+	bindStmts(fn.Stmts, bound)
+	return bound
+}
+
+func bindStmts(stmts []migo.Statement, bound map[string]bool) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *migo.NewChanStatement:
+			bound[stmt.Name.Name()] = true
+		case *migo.NewMem:
+			bound[stmt.Name.Name()] = true
+		case *migo.NewSyncMutex:
+			bound[stmt.Name.Name()] = true
+		case *migo.NewSyncRWMutex:
+			bound[stmt.Name.Name()] = true
+		case *migo.IfStatement:
+			bindStmts(stmt.Then, bound)
+			bindStmts(stmt.Else, bound)
+		case *migo.IfForStatement:
+			bindStmts(stmt.Then, bound)
+			bindStmts(stmt.Else, bound)
+		case *migo.SelectStatement:
+			for _, c := range stmt.Cases {
+				bindStmts(c, bound)
+			}
+		case migo.ExtStatement:
+			for _, b := range stmt.Blocks() {
+				bindStmts(b, bound)
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package alias
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+)
+
+// Scope caches the names bound in functions (see Bound).
+type Scope map[*migo.Function]map[string]bool
+
+// Bound returns true if name is bound in function fn.
+func (s Scope) Bound(fn *migo.Function, name string) bool {
+	if _, ok := s[fn]; !ok {
+		s[fn] = Bound(fn)
+	}
+	return s[fn][name]
+}
+
+// Object is a channel, memory or mutex denoted by names, i.e. the
+// statement creating it, or a free name.
+type Object struct {
+	Name string         // Name bound by Stmt, or the free name.
+	Func string         // Function of Stmt, empty for free names.
+	Stmt migo.Statement // Statement creating the object, nil for free names.
+	ID   int            // Identifier, in order of creation.
+}
+
+func (o *Object) String() string {
+	if o.Func == "" {
+		return o.Name
+	}
+	return fmt.Sprintf("%s.%s", o.Func, o.Name)
+}
+
+// Env maps the names of a function to the objects they denote.
+type Env map[string]*Object
+
+// With returns a copy of e with name bound to o.
+func (e Env) With(name string, o *Object) Env {
+	e2 := make(Env, len(e)+1)
+	for n, obj := range e {
+		e2[n] = obj
+	}
+	e2[name] = o
+	return e2
+}
+
+// Key returns a string identifying the bindings of e.
+func (e Env) Key() string {
+	kv := make([]string, 0, len(e))
+	for name, o := range e {
+		kv = append(kv, fmt.Sprintf("%s=%d", name, o.ID))
+	}
+	sort.Strings(kv)
+	return strings.Join(kv, ",")
+}
+
+// Binder binds the names of functions to objects, along the parameters of
+// calls and spawns. Each statement creating an object, and each free name,
+// is a single object.
+type Binder struct {
+	scope   Scope
+	objects map[interface{}]*Object // By statement or free name.
+}
+
+// NewBinder returns a Binder with no objects.
+func NewBinder() *Binder {
+	return &Binder{scope: make(Scope), objects: make(map[interface{}]*Object)}
+}
+
+// Object returns the object created by stmt in function fn, bound to name.
+func (b *Binder) Object(fn *migo.Function, name string, stmt migo.Statement) *Object {
+	return b.object(stmt, name, fn.Name, stmt)
+}
+
+// New returns a new object of function fn, which no name denotes, e.g. to
+// stand for an event.
+func (b *Binder) New(name, fn string) *Object {
+	o := &Object{Name: name, Func: fn, ID: len(b.objects)}
+	b.objects[o] = o
+	return o
+}
+
+func (b *Binder) object(key interface{}, name, fn string, stmt migo.Statement) *Object {
+	if _, ok := b.objects[key]; !ok {
+		b.objects[key] = &Object{Name: name, Func: fn, Stmt: stmt, ID: len(b.objects)}
+	}
+	return b.objects[key]
+}
+
+// Let returns e with the name bound by stmt in function fn, if stmt is a
+// newchan, letmem or letsync statement, or else e.
+func (b *Binder) Let(fn *migo.Function, e Env, stmt migo.Statement) Env {
+	var name migo.NamedVar
+	switch stmt := stmt.(type) {
+	case *migo.NewChanStatement:
+		name = stmt.Name
+	case *migo.NewMem:
+		name = stmt.Name
+	case *migo.NewSyncMutex:
+		name = stmt.Name
+	case *migo.NewSyncRWMutex:
+		name = stmt.Name
+	default:
+		return e
+	}
+	return e.With(name.Name(), b.Object(fn, name.Name(), stmt))
+}
+
+// Lookup returns the object name denotes in function fn with Env e, or nil
+// if name is bound in fn but not in e.
+func (b *Binder) Lookup(fn *migo.Function, e Env, name string) *Object {
+	if o, ok := e[name]; ok {
+		return o
+	}
+	if b.scope.Bound(fn, name) {
+		return nil
+	}
+	return b.object(name, name, "", nil)
+}
+
+// Bind returns the Env of callee, called or spawned with params from
+// function caller with Env e.
+func (b *Binder) Bind(caller *migo.Function, e Env, callee *migo.Function, params []*migo.Parameter) Env {
+	calleeEnv := make(Env)
+	for i, p := range params {
+		if i >= len(callee.Params) {
+			break
+		}
+		if o := b.Lookup(caller, e, p.Caller.Name()); o != nil {
+			calleeEnv[callee.Params[i].Callee.Name()] = o
+		}
+	}
+	return calleeEnv
+}
@@ -0,0 +1,101 @@
+// Package clone makes deep copies of MiGo statements, so that passes can
+// rewrite a copy of a program without modifying statements shared with the
+// original.
+package clone
+
+import (
+	"go/token"
+
+	"github.com/JorgeGCoelho/migo/v3"
+)
+
+// Stmts returns a deep copy of stmts.
+func Stmts(stmts []migo.Statement) []migo.Statement {
+	ss := make([]migo.Statement, len(stmts))
+	for i, stmt := range stmts {
+		ss[i] = Stmt(stmt)
+	}
+	return ss
+}
+
+// Stmt returns a deep copy of stmt.
+//
+// A migo.ExtStatement is copied with WithBlocks, with copies of its
+// blocks. Statements of unknown kinds are not copied.
+func Stmt(stmt migo.Statement) migo.Statement {
+	switch stmt := stmt.(type) {
+	case *migo.TauStatement:
+		s := *stmt
+		return &s
+	case *migo.SendStatement:
+		s := *stmt
+		return &s
+	case *migo.RecvStatement:
+		s := *stmt
+		s.Sends = append([]token.Position(nil), stmt.Sends...)
+		return &s
+	case *migo.CloseStatement:
+		s := *stmt
+		return &s
+	case *migo.NewChanStatement:
+		s := *stmt
+		return &s
+	case *migo.CallStatement:
+		return &migo.CallStatement{Name: stmt.Name, Params: Params(stmt.Params)}
+	case *migo.SpawnStatement:
+		return &migo.SpawnStatement{Name: stmt.Name, Params: Params(stmt.Params)}
+	case *migo.IfStatement:
+		return &migo.IfStatement{Then: Stmts(stmt.Then), Else: Stmts(stmt.Else)}
+	case *migo.IfForStatement:
+		return &migo.IfForStatement{ForCond: stmt.ForCond, Then: Stmts(stmt.Then), Else: Stmts(stmt.Else)}
+	case *migo.SelectStatement:
+		cases := make([][]migo.Statement, len(stmt.Cases))
+		for i, c := range stmt.Cases {
+			cases[i] = Stmts(c)
+		}
+		return &migo.SelectStatement{Cases: cases}
+	case *migo.NewMem:
+		s := *stmt
+		return &s
+	case *migo.MemRead:
+		s := *stmt
+		return &s
+	case *migo.MemWrite:
+		s := *stmt
+		return &s
+	case *migo.NewSyncMutex:
+		s := *stmt
+		return &s
+	case *migo.SyncMutexLock:
+		s := *stmt
+		return &s
+	case *migo.SyncMutexUnlock:
+		s := *stmt
+		return &s
+	case *migo.NewSyncRWMutex:
+		s := *stmt
+		return &s
+	case *migo.SyncRWMutexRLock:
+		s := *stmt
+		return &s
+	case *migo.SyncRWMutexRUnlock:
+		s := *stmt
+		return &s
+	case migo.ExtStatement:
+		var blocks [][]migo.Statement
+		for _, b := range stmt.Blocks() {
+			blocks = append(blocks, Stmts(b))
+		}
+		return stmt.WithBlocks(blocks)
+	}
+	return stmt
+}
+
+// Params returns a copy of params.
+func Params(params []*migo.Parameter) []*migo.Parameter {
+	ps := make([]*migo.Parameter, len(params))
+	for i, p := range params {
+		ps[i] = &migo.Parameter{Caller: p.Caller, Callee: p.Callee}
+	}
+	return ps
+}
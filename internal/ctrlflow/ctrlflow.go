@@ -5,7 +5,6 @@ package ctrlflow
 import (
 	"fmt"
 	"github.com/JorgeGCoelho/migo/v3"
-	"log"
 	"strings"
 )
 
@@ -117,15 +116,20 @@ func (n *Node) String() string {
 }
 
 // NewGraph returns a new CFG given MiGo program prog.
-func NewGraph(prog *migo.Program) *Graph {
+//
+// Returns a *migo.ErrUnsupportedStatement error if prog contains
+// a statement kind that is unknown and not a migo.ExtStatement.
+func NewGraph(prog *migo.Program) (*Graph, error) {
 	b := builder{
 		graph:   &Graph{prog: prog},
 		visited: make(map[*migo.Function]bool),
 	}
 	for _, f := range b.graph.prog.Funcs {
-		b.visit(f)
+		if err := b.visit(f); err != nil {
+			return nil, err
+		}
 	}
-	return b.graph
+	return b.graph, nil
 }
 
 // builder is a data structure to build a CFG.
@@ -137,10 +141,10 @@ type builder struct {
 	visited map[*migo.Function]bool
 }
 
-func (b *builder) visit(fn *migo.Function) {
+func (b *builder) visit(fn *migo.Function) error {
 	if completed, started := b.visited[fn]; started { // key exists, visit started
 		_ = completed
-		return
+		return nil
 	}
 	b.visited[fn] = false // visit started
 	if b.nodes == nil {
@@ -149,11 +153,14 @@ func (b *builder) visit(fn *migo.Function) {
 	n := &Node{fn: fn}
 	b.nodes[fn] = n
 	b.graph.addNode(n)
-	b.visitStmts(fn, fn.Stmts) // visit body
-	b.visited[fn] = true       // visit complete
+	if err := b.visitStmts(fn, fn.Stmts); err != nil { // visit body
+		return err
+	}
+	b.visited[fn] = true // visit complete
+	return nil
 }
 
-func (b *builder) visitStmts(parent *migo.Function, stmts []migo.Statement) {
+func (b *builder) visitStmts(parent *migo.Function, stmts []migo.Statement) error {
 	for _, stmt := range stmts {
 		switch stmt := stmt.(type) {
 		case *migo.NewChanStatement, *migo.CloseStatement:
@@ -166,27 +173,33 @@ func (b *builder) visitStmts(parent *migo.Function, stmts []migo.Statement) {
 			// no-op
 
 		case *migo.SelectStatement:
-			for _, stmts := range stmt.Cases {
-				b.visitStmts(parent, stmts)
+			if err := b.visitBlocks(parent, stmt.Cases...); err != nil {
+				return err
 			}
 
 		case *migo.IfStatement:
-			b.visitStmts(parent, stmt.Then)
-			b.visitStmts(parent, stmt.Else)
+			if err := b.visitBlocks(parent, stmt.Then, stmt.Else); err != nil {
+				return err
+			}
 
 		case *migo.IfForStatement:
-			b.visitStmts(parent, stmt.Then)
-			b.visitStmts(parent, stmt.Else)
+			if err := b.visitBlocks(parent, stmt.Then, stmt.Else); err != nil {
+				return err
+			}
 
 		case *migo.CallStatement:
 			if fn, found := b.graph.prog.Function(stmt.Name); found {
-				b.visit(fn)
+				if err := b.visit(fn); err != nil {
+					return err
+				}
 				b.graph.addEdge(b.nodes[parent], b.nodes[fn])
 			}
 
 		case *migo.SpawnStatement:
 			if fn, found := b.graph.prog.Function(stmt.Name); found {
-				b.visit(fn)
+				if err := b.visit(fn); err != nil {
+					return err
+				}
 				b.graph.addEdge(b.nodes[parent], b.nodes[fn])
 			}
 
@@ -199,8 +212,23 @@ func (b *builder) visitStmts(parent *migo.Function, stmts []migo.Statement) {
 		case *migo.NewSyncRWMutex, *migo.SyncRWMutexRLock, *migo.SyncRWMutexRUnlock:
 			// no-op
 
+		case migo.ExtStatement:
+			if err := b.visitBlocks(parent, stmt.Blocks()...); err != nil {
+				return err
+			}
+
 		default:
-			log.Fatal(fmt.Errorf("ctrlflow: statement kind not found: %T", stmt))
+			return &migo.ErrUnsupportedStatement{Func: parent.Name, Stmt: stmt}
+		}
+	}
+	return nil
+}
+
+func (b *builder) visitBlocks(parent *migo.Function, blocks ...[]migo.Statement) error {
+	for _, stmts := range blocks {
+		if err := b.visitStmts(parent, stmts); err != nil {
+			return err
 		}
 	}
+	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/JorgeGCoelho/migo/v3"
 	"github.com/JorgeGCoelho/migo/v3/internal/ctrlflow"
 	"github.com/JorgeGCoelho/migo/v3/parser"
 )
@@ -15,7 +16,11 @@ func TestEmpty(t *testing.T) {
 		t.Error(err)
 		t.FailNow()
 	}
-	g := ctrlflow.NewGraph(prog)
+	g, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
 	if want, got := 1, len(g.Nodes); want != got {
 		t.Errorf("expected %d node but got %d", want, got)
 	}
@@ -41,7 +46,11 @@ def e(): tau;
 		t.Error(err)
 		t.FailNow()
 	}
-	g := ctrlflow.NewGraph(prog)
+	g, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
 	if want, got := 6, len(g.Nodes); want != got {
 		t.Errorf("expected %d nodes but got %d", want, got)
 	}
@@ -89,7 +98,11 @@ def h():
 		t.Error(err)
 		t.FailNow()
 	}
-	g := ctrlflow.NewGraph(prog)
+	g, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
 	// Order:
 	//   main       .
 	//   sel           .
@@ -134,7 +147,11 @@ def mutual_recursive_b():
 		t.Error(err)
 		t.FailNow()
 	}
-	g := ctrlflow.NewGraph(prog)
+	g, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
 	if want, got := 7, len(g.Nodes); want != got {
 		t.Errorf("expected %d node but got %d", want, got)
 	}
@@ -157,3 +174,149 @@ def mutual_recursive_b():
 		}
 	}
 }
+
+func TestSCCs(t *testing.T) {
+	s := `
+def main():
+	call a();
+	call c();
+def a(): call b();
+def b(): call a(); call c();
+def c(): call c();
+def d(): tau;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	g, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	sccs := g.SCCs()
+	if want, got := 4, len(sccs); want != got {
+		t.Errorf("expected %d SCCs but got %d", want, got)
+	}
+	recursive := make(map[string]int)
+	for _, scc := range sccs {
+		if ctrlflow.IsRecursive(scc) {
+			for _, n := range scc {
+				recursive[n.Func().Name] = len(scc)
+			}
+		}
+	}
+	if want, got := 3, len(recursive); want != got {
+		t.Errorf("expected %d recursive functions but got %d: %v", want, got, recursive)
+	}
+	if want, got := 2, recursive["a"]; want != got {
+		t.Errorf("expected a in SCC of size %d but got %d", want, got)
+	}
+	if want, got := 1, recursive["c"]; want != got {
+		t.Errorf("expected c in SCC of size %d but got %d", want, got)
+	}
+	// c is called by b, so it must be listed first.
+	pos := make(map[string]int)
+	for i, scc := range sccs {
+		for _, n := range scc {
+			pos[n.Func().Name] = i
+		}
+	}
+	if pos["c"] > pos["a"] || pos["a"] > pos["main"] {
+		t.Errorf("expected SCCs in reverse topological order but got %v", pos)
+	}
+}
+
+func TestRepeated(t *testing.T) {
+	s := `
+def main():
+	spawn once();
+	call loop();
+	call start();
+def loop(): if spawn rec(); call start(); call loop(); else tau; endif;
+def start(): spawn inner();
+def inner(): spawn nested();
+def once(): tau;
+def rec(): tau;
+def body(): tau;
+def after(): tau;
+def nested(): tau;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The parser does not support ifFor loops.
+	main, _ := prog.Function("main")
+	main.AddStmts(&migo.IfForStatement{
+		ForCond: "i",
+		Then:    []migo.Statement{&migo.SpawnStatement{Name: "body"}},
+		Else:    []migo.Statement{&migo.SpawnStatement{Name: "after"}},
+	})
+	repeated, err := ctrlflow.Repeated(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for stmt := range repeated {
+		got[stmt.Name] = true
+	}
+	for name, want := range map[string]bool{
+		"once": false, "rec": true, "inner": true, "nested": true, "body": true, "after": false,
+	} {
+		if got[name] != want {
+			t.Errorf("expected spawn of %s repeated to be %v", name, want)
+		}
+	}
+}
+
+// unknownStmt is a Statement which is not known to the CFG builder.
+type unknownStmt struct{}
+
+func (unknownStmt) String() string { return "unknown" }
+
+// extStmt is a user-defined Statement which calls f in its only block.
+type extStmt struct{}
+
+func (extStmt) String() string { return "ext" }
+func (extStmt) IsTau() bool    { return true }
+func (extStmt) Blocks() [][]migo.Statement {
+	return [][]migo.Statement{{&migo.CallStatement{Name: "f"}}}
+}
+func (extStmt) WithBlocks([][]migo.Statement) migo.ExtStatement { return extStmt{} }
+
+func TestUnsupportedStatement(t *testing.T) {
+	prog := migo.NewProgram()
+	fn := migo.NewFunction("main")
+	fn.AddStmts(unknownStmt{})
+	prog.AddFunction(fn)
+	_, err := ctrlflow.NewGraph(prog)
+	if err == nil {
+		t.Errorf("expected error for unsupported statement")
+		t.FailNow()
+	}
+	if e, ok := err.(*migo.ErrUnsupportedStatement); !ok {
+		t.Errorf("expected *migo.ErrUnsupportedStatement but got %T", err)
+	} else if e.Func != "main" {
+		t.Errorf("expected error in function main but got %s", e.Func)
+	}
+}
+
+func TestExtStatement(t *testing.T) {
+	prog := migo.NewProgram()
+	mainFn := migo.NewFunction("main")
+	mainFn.AddStmts(extStmt{})
+	prog.AddFunction(mainFn)
+	f := migo.NewFunction("f")
+	f.AddStmts(&migo.TauStatement{})
+	prog.AddFunction(f)
+	g, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if want, got := 1, len(g.Nodes[0].Succs); want != got {
+		t.Errorf("expected %d successors but got %d", want, got)
+	}
+}
@@ -0,0 +1,87 @@
+package ctrlflow
+
+import "github.com/JorgeGCoelho/migo/v3"
+
+// Repeated returns the spawn statements of Program prog which may run more
+// than once in a loop, so the goroutines they start may run concurrently
+// with each other. These are the spawns in the body of an ifFor loop, and
+// the spawns in a function which may run more than once, i.e. a function
+// of a recursive strongly connected component, or a function called or
+// spawned from the body of an ifFor loop or from such a function.
+//
+// Returns a *migo.ErrUnsupportedStatement error if prog contains
+// a statement kind that is unknown and not a migo.ExtStatement.
+func Repeated(prog *migo.Program) (map[*migo.SpawnStatement]bool, error) {
+	g, err := NewGraph(prog)
+	if err != nil {
+		return nil, err
+	}
+	r := repeater{
+		prog:     prog,
+		many:     make(map[*migo.Function]bool),
+		repeated: make(map[*migo.SpawnStatement]bool),
+	}
+	for _, scc := range g.SCCs() {
+		if IsRecursive(scc) {
+			for _, n := range scc {
+				r.many[n.fn] = true
+			}
+		}
+	}
+	for r.changed = true; r.changed; {
+		r.changed = false
+		for _, fn := range prog.Funcs {
+			r.visitStmts(fn, fn.Stmts, false)
+		}
+	}
+	return r.repeated, nil
+}
+
+// repeater is the temporary data for computing repeated spawns.
+type repeater struct {
+	prog     *migo.Program
+	many     map[*migo.Function]bool // Functions which may run more than once.
+	changed  bool
+	repeated map[*migo.SpawnStatement]bool
+}
+
+// visitStmts visits stmts of function fn, which are in the body of an
+// ifFor loop if loop.
+func (r *repeater) visitStmts(fn *migo.Function, stmts []migo.Statement, loop bool) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *migo.CallStatement:
+			r.visitCall(fn, stmt.Name, loop)
+		case *migo.SpawnStatement:
+			if r.many[fn] || loop {
+				r.repeated[stmt] = true
+			}
+			r.visitCall(fn, stmt.Name, loop)
+		case *migo.IfStatement:
+			r.visitStmts(fn, stmt.Then, loop)
+			r.visitStmts(fn, stmt.Else, loop)
+		case *migo.IfForStatement:
+			r.visitStmts(fn, stmt.Then, true)
+			r.visitStmts(fn, stmt.Else, loop)
+		case *migo.SelectStatement:
+			for _, c := range stmt.Cases {
+				r.visitStmts(fn, c, loop)
+			}
+		case migo.ExtStatement:
+			for _, b := range stmt.Blocks() {
+				r.visitStmts(fn, b, loop)
+			}
+		}
+	}
+}
+
+func (r *repeater) visitCall(caller *migo.Function, name string, loop bool) {
+	callee, found := r.prog.Function(name)
+	if !found {
+		return
+	}
+	if (r.many[caller] || loop) && !r.many[callee] {
+		r.many[callee] = true
+		r.changed = true
+	}
+}
@@ -0,0 +1,27 @@
+package ctrlflow
+
+import "github.com/JorgeGCoelho/migo/v3/internal/tarjan"
+
+// SCCs returns the strongly connected components of the graph.
+//
+// Components are returned in reverse topological order, i.e. a component
+// is listed before any component which calls or spawns it.
+func (g *Graph) SCCs() [][]*Node {
+	return tarjan.SCCs(g.Nodes, func(n *Node) []*Node { return n.Succs })
+}
+
+// IsRecursive returns true if the strongly connected component scc
+// contains a cycle, i.e. it has more than one node or a node calling itself.
+func IsRecursive(scc []*Node) bool {
+	if len(scc) > 1 {
+		return true
+	}
+	for _, n := range scc {
+		for _, s := range n.Succs {
+			if s == n {
+				return true
+			}
+		}
+	}
+	return false
+}
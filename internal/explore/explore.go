@@ -0,0 +1,197 @@
+// Package explore explores the state space of MiGo programs,
+// as given by the semantics package.
+//
+// Exploration is breadth-first, so the path to every state visited is
+// a shortest path from the initial state.
+//
+// The state space can be reduced by partial-order reduction and symmetry
+// reduction (see Options), which preserve the reachability of deadlocked
+// states, i.e. states without successors, but not of every state.
+package explore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// Options are the limits of an exploration.
+type Options struct {
+	MaxStates int           // Maximum number of states visited, 0 for no limit.
+	MaxDepth  int           // Maximum depth of states visited, 0 for no limit.
+	Timeout   time.Duration // Maximum duration of exploration, 0 for no limit.
+
+	Workers int // Number of goroutines expanding states, 1 if not positive.
+
+	PartialOrder bool // Expand ample sets instead of all successors.
+	Symmetry     bool // Identify states up to permutation of goroutines.
+}
+
+// key returns the key identifying s in the visited set.
+func (o Options) key(s *semantics.State) string {
+	if o.Symmetry {
+		return s.SymmetricKey()
+	}
+	return s.Key()
+}
+
+// Node is a visited state in the state space.
+type Node struct {
+	State  *semantics.State
+	Parent *Node           // Node the state is reached from, nil for initial.
+	Label  semantics.Label // Label of the reduction from Parent.
+	Depth  int             // Length of the path from the initial state.
+}
+
+// Path returns the reductions from the initial state to the node.
+func (n *Node) Path() []semantics.Transition {
+	path := make([]semantics.Transition, n.Depth)
+	for m := n; m.Parent != nil; m = m.Parent {
+		path[m.Depth-1] = semantics.Transition{Label: m.Label, Next: m.State}
+	}
+	return path
+}
+
+// Result is the summary of an exploration.
+type Result struct {
+	States    int  // Number of states visited.
+	Complete  bool // All reachable states are visited.
+	Stopped   bool // Exploration was stopped by the visitor.
+	Cancelled bool // Exploration was stopped by the context.
+}
+
+// Visitor is called on each new state visited, with its successors.
+// The exploration stops if Visitor returns false.
+type Visitor func(n *Node, succs []semantics.Transition) bool
+
+// BFS visits all states reachable from init in breadth-first order.
+func BFS(init *semantics.State, opts Options, visit Visitor) Result {
+	return BFSContext(context.Background(), init, opts, visit)
+}
+
+// BFSContext visits all states reachable from init in breadth-first order,
+// until ctx is cancelled.
+//
+// Each level of the state space is expanded by opts.Workers goroutines,
+// sharing the frontier and the visited set. The visitor is called from a
+// single goroutine, in the same order, and with the same paths, for any
+// number of workers.
+func BFSContext(ctx context.Context, init *semantics.State, opts Options, visit Visitor) Result {
+	var deadline time.Time
+	if opts.Timeout > 0 {
+		deadline = time.Now().Add(opts.Timeout)
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	visited := newVisitedSet(workers)
+	visited.claim(opts.key(init), 0, order{})
+	res := Result{States: 1, Complete: true}
+	frontier := []*Node{{State: init}}
+	for level := 1; len(frontier) > 0; level++ {
+		// Compute the successors of the frontier, and claim the states not
+		// visited in earlier levels for the first node and successor, in
+		// breadth-first order, reaching them.
+		exps := make([]expansion, len(frontier))
+		parallel(ctx, workers, len(frontier), func(i int) {
+			exps[i] = expand(frontier[i], opts, visited)
+		})
+		parallel(ctx, workers, len(frontier), func(i int) {
+			for j, key := range exps[i].keys {
+				visited.claim(key, level, order{i, j})
+			}
+		})
+		var next []*Node
+		for i, n := range frontier {
+			if ctx.Err() != nil {
+				res.Cancelled, res.Complete = true, false
+				return res
+			}
+			if !visit(n, exps[i].succs) {
+				res.Stopped, res.Complete = true, false
+				return res
+			}
+			if opts.MaxDepth > 0 && n.Depth >= opts.MaxDepth {
+				if len(exps[i].succs) > 0 {
+					res.Complete = false
+				}
+				continue
+			}
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				res.Complete = false
+				return res
+			}
+			for j, t := range exps[i].expand {
+				if !visited.claimedBy(exps[i].keys[j], level, order{i, j}) {
+					continue
+				}
+				if opts.MaxStates > 0 && res.States >= opts.MaxStates {
+					res.Complete = false
+					break
+				}
+				res.States++
+				next = append(next, &Node{State: t.Next, Parent: n, Label: t.Label, Depth: n.Depth + 1})
+			}
+		}
+		frontier = next
+	}
+	return res
+}
+
+// expansion is the successors of a node, and the successors to explore
+// with their keys.
+type expansion struct {
+	succs  []semantics.Transition
+	expand []semantics.Transition
+	keys   []string
+}
+
+func expand(n *Node, opts Options, visited *visitedSet) expansion {
+	succs := semantics.Successors(n.State)
+	exp := expansion{succs: succs, expand: succs}
+	if opts.MaxDepth > 0 && n.Depth >= opts.MaxDepth {
+		exp.expand = nil
+		return exp
+	}
+	if opts.PartialOrder {
+		exp.expand = ample(n.State, succs, func(s *semantics.State) bool { return visited.has(opts.key(s)) })
+	}
+	exp.keys = make([]string, len(exp.expand))
+	for i, t := range exp.expand {
+		exp.keys[i] = opts.key(t.Next)
+	}
+	return exp
+}
+
+// parallel calls fn(i) for i in [0, n) from up to workers goroutines.
+func parallel(ctx context.Context, workers, n int, fn func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n && ctx.Err() == nil; i++ {
+			fn(i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	next := int64(-1)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,241 @@
+package explore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+func TestBFS(t *testing.T) {
+	s := `
+def main():
+	let ch = newchan T, 0;
+	spawn f(ch);
+	recv ch;
+def f(c):
+	if send c; else tau; send c; endif;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	init, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	var final *Node
+	res := BFS(init, Options{}, func(n *Node, succs []semantics.Transition) bool {
+		if len(succs) == 0 && n.State.Terminated() && final == nil {
+			final = n
+		}
+		return true
+	})
+	if !res.Complete {
+		t.Errorf("expected complete exploration")
+	}
+	if final == nil {
+		t.Fatalf("expected terminated state to be reachable")
+	}
+	// newchan, spawn, choice (then), comm
+	if want, got := 4, len(final.Path()); want != got {
+		t.Errorf("expected shortest path of length %d but got %d", want, got)
+	}
+	for i, tr := range final.Path() {
+		if tr.Label.Kind == semantics.Tau {
+			t.Errorf("step %d: expected shortest path to take then branch but got %s", i, tr.Label)
+		}
+	}
+	res = BFS(init, Options{}, func(n *Node, succs []semantics.Transition) bool {
+		return n.Depth < 2
+	})
+	if !res.Stopped {
+		t.Errorf("expected exploration to be stopped by visitor")
+	}
+}
+
+// workers is a program with n identical workers, each doing local work
+// before sending on a shared channel, where main receives n-1 times so
+// one worker is stuck.
+func workers(n int) string {
+	var sb strings.Builder
+	sb.WriteString("def main():\n\tlet ch = newchan T, 0;\n")
+	for i := 0; i < n; i++ {
+		sb.WriteString("\tspawn w(ch);\n")
+	}
+	for i := 0; i < n-1; i++ {
+		sb.WriteString("\trecv ch;\n")
+	}
+	sb.WriteString("\tlet done = newchan T, 0;\n\trecv done;\n")
+	sb.WriteString("def w(c):\n\tletmem x;\n\twrite x;\n\tread x;\n\ttau;\n\tsend c;\n")
+	return sb.String()
+}
+
+func explore(t testing.TB, s string, opts Options) (Result, int) {
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	init, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadlocks := 0
+	res := BFS(init, opts, func(n *Node, succs []semantics.Transition) bool {
+		if len(succs) == 0 && !n.State.Goroutines[0].Terminated() {
+			deadlocks++
+		}
+		return true
+	})
+	return res, deadlocks
+}
+
+func TestReduction(t *testing.T) {
+	s := workers(3)
+	plain, deadlocks := explore(t, s, Options{})
+	if deadlocks == 0 {
+		t.Fatalf("expected deadlock to be reachable")
+	}
+	for _, opts := range []Options{
+		{PartialOrder: true},
+		{Symmetry: true},
+		{PartialOrder: true, Symmetry: true},
+	} {
+		res, deadlocks := explore(t, s, opts)
+		if !res.Complete {
+			t.Errorf("%+v: expected complete exploration", opts)
+		}
+		if deadlocks == 0 {
+			t.Errorf("%+v: expected deadlock to be reachable with reduction", opts)
+		}
+		if res.States >= plain.States {
+			t.Errorf("%+v: expected fewer than %d states but got %d", opts, plain.States, res.States)
+		}
+	}
+}
+
+func TestPartialOrderCycle(t *testing.T) {
+	// The loop of f is local, but main must still be explored.
+	s := `
+def main():
+	let ch = newchan T, 0;
+	spawn f();
+	send ch;
+def f():
+	tau;
+	call f();
+`
+	_, deadlocks := explore(t, s, Options{PartialOrder: true})
+	if deadlocks != 0 {
+		t.Errorf("expected no deadlocked states (f never blocks) but got %d", deadlocks)
+	}
+	res, _ := explore(t, s, Options{PartialOrder: true})
+	if !res.Complete {
+		t.Errorf("expected complete exploration")
+	}
+}
+
+func BenchmarkReduction(b *testing.B) {
+	for _, n := range []int{2, 3, 4} {
+		s := workers(n)
+		for _, bc := range []struct {
+			name string
+			opts Options
+		}{
+			{"plain", Options{}},
+			{"por", Options{PartialOrder: true}},
+			{"symmetry", Options{Symmetry: true}},
+			{"both", Options{PartialOrder: true, Symmetry: true}},
+		} {
+			b.Run(fmt.Sprintf("workers=%d/%s", n, bc.name), func(b *testing.B) {
+				var res Result
+				for i := 0; i < b.N; i++ {
+					res, _ = explore(b, s, bc.opts)
+				}
+				b.ReportMetric(float64(res.States), "states")
+			})
+		}
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(workers(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	init, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// trace returns the states visited in order, with the labels of their paths.
+	trace := func(opts Options) ([]string, Result) {
+		var visits []string
+		res := BFS(init, opts, func(n *Node, succs []semantics.Transition) bool {
+			var sb strings.Builder
+			sb.WriteString(n.State.Key())
+			for _, tr := range n.Path() {
+				sb.WriteString(" " + tr.Label.String())
+			}
+			visits = append(visits, sb.String())
+			return true
+		})
+		return visits, res
+	}
+	for _, opts := range []Options{{}, {MaxStates: 100}, {PartialOrder: true, Symmetry: true}} {
+		want, wantRes := trace(opts)
+		for _, workers := range []int{2, 4, 8} {
+			opts.Workers = workers
+			got, gotRes := trace(opts)
+			if wantRes != gotRes {
+				t.Errorf("workers=%d: expected result %+v but got %+v", workers, wantRes, gotRes)
+			}
+			if len(want) != len(got) {
+				t.Errorf("workers=%d: expected %d visits but got %d", workers, len(want), len(got))
+				continue
+			}
+			for i := range want {
+				if want[i] != got[i] {
+					t.Errorf("workers=%d: visit %d differs:\nwant %s\ngot  %s", workers, i, want[i], got[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(workers(4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	init, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	res := BFSContext(ctx, init, Options{Workers: 4}, func(n *Node, succs []semantics.Transition) bool {
+		if n.Depth == 3 {
+			cancel()
+		}
+		return true
+	})
+	if !res.Cancelled || res.Complete {
+		t.Errorf("expected cancelled incomplete exploration but got %+v", res)
+	}
+}
+
+func BenchmarkWorkers(b *testing.B) {
+	s := workers(4)
+	for _, w := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", w), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				explore(b, s, Options{Workers: w})
+			}
+		})
+	}
+}
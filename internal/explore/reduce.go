@@ -0,0 +1,101 @@
+package explore
+
+import (
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// ample returns an ample subset of succs, the successors of s, i.e. the
+// transitions of a single goroutine which are independent of the
+// transitions of all other goroutines, in s and in any state reachable
+// from s without the goroutine reducing. Exploring ample sets only
+// preserves the reachable deadlocked states.
+//
+// The transitions of a goroutine are independent if its next statement is
+// not a select, and each transition is local to the goroutine: a τ-action,
+// a choice, a call or spawn, the creation of an object, or an action on an
+// object no other goroutine can refer to. If an ample set would only lead
+// to visited states, all successors are returned, so that transitions are
+// not ignored forever on a cycle.
+func ample(s *semantics.State, succs []semantics.Transition, visited func(*semantics.State) bool) []semantics.Transition {
+	shared := sharedObjects(s)
+	for _, g := range s.Goroutines {
+		if g.Terminated() {
+			continue
+		}
+		if _, ok := g.Next().(*migo.SelectStatement); ok {
+			continue
+		}
+		var ts []semantics.Transition
+		independent := true
+		for _, t := range succs {
+			if t.Label.G != g.ID && t.Label.Peer != g.ID {
+				continue
+			}
+			if !isLocal(t.Label, g.ID, shared) {
+				independent = false
+				break
+			}
+			ts = append(ts, t)
+		}
+		if !independent || len(ts) == 0 || len(ts) == len(succs) {
+			continue
+		}
+		allVisited := true
+		for _, t := range ts {
+			if !visited(t.Next) {
+				allVisited = false
+				break
+			}
+		}
+		if !allVisited {
+			return ts
+		}
+	}
+	return succs
+}
+
+// isLocal returns true if the reduction l by goroutine g only affects g.
+func isLocal(l semantics.Label, g int, shared map[int]map[int]bool) bool {
+	switch l.Kind {
+	case semantics.Tau, semantics.Choice, semantics.Call, semantics.Spawn,
+		semantics.NewChan, semantics.NewMem, semantics.NewMutex:
+		return true
+	case semantics.Comm:
+		return false
+	}
+	if l.Obj < 0 {
+		return false
+	}
+	for other := range shared[l.Obj] {
+		if other != g {
+			return false
+		}
+	}
+	return true
+}
+
+// sharedObjects returns the goroutines referring to each object of s.
+// Objects of free names are referred to by all goroutines (-1).
+func sharedObjects(s *semantics.State) map[int]map[int]bool {
+	shared := make(map[int]map[int]bool)
+	add := func(obj, g int) {
+		if shared[obj] == nil {
+			shared[obj] = make(map[int]bool)
+		}
+		shared[obj][g] = true
+	}
+	for obj, o := range s.Objects {
+		if o.Site == nil {
+			add(obj, -1)
+		}
+	}
+	for _, g := range s.Goroutines {
+		for _, f := range g.Frames {
+			for _, obj := range f.Env {
+				add(obj, g.ID)
+			}
+		}
+	}
+	return shared
+}
@@ -0,0 +1,76 @@
+package explore
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// order is the position of a successor in a level of breadth-first
+// exploration: successor j of the i-th node of the frontier.
+type order [2]int
+
+func (o order) less(p order) bool {
+	return o[0] < p[0] || (o[0] == p[0] && o[1] < p[1])
+}
+
+// claim is the level a state is first reached at, and the first successor
+// reaching it in the level.
+type claim struct {
+	level int
+	by    order
+}
+
+// visitedSet is a concurrency-safe set of state keys, sharded by hash.
+type visitedSet struct {
+	shards []shard
+}
+
+type shard struct {
+	sync.Mutex
+	claims map[string]claim
+}
+
+// newVisitedSet returns an empty visitedSet for workers goroutines.
+func newVisitedSet(workers int) *visitedSet {
+	v := &visitedSet{shards: make([]shard, 4*workers)}
+	for i := range v.shards {
+		v.shards[i].claims = make(map[string]claim)
+	}
+	return v
+}
+
+func (v *visitedSet) shard(key string) *shard {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return &v.shards[h.Sum64()%uint64(len(v.shards))]
+}
+
+// has returns true if key is reached.
+func (v *visitedSet) has(key string) bool {
+	s := v.shard(key)
+	s.Lock()
+	defer s.Unlock()
+	_, ok := s.claims[key]
+	return ok
+}
+
+// claim marks key as reached at level by successor by, unless it is
+// reached at an earlier level, or earlier in the same level.
+func (v *visitedSet) claim(key string, level int, by order) {
+	s := v.shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if c, ok := s.claims[key]; ok && (c.level < level || !by.less(c.by)) {
+		return
+	}
+	s.claims[key] = claim{level: level, by: by}
+}
+
+// claimedBy returns true if key is first reached at level by successor by.
+func (v *visitedSet) claimedBy(key string, level int, by order) bool {
+	s := v.shard(key)
+	s.Lock()
+	defer s.Unlock()
+	c := s.claims[key]
+	return c.level == level && c.by == by
+}
@@ -6,24 +6,42 @@ package deadcall
 import "github.com/JorgeGCoelho/migo/v3"
 
 // Remove removes undefined function calls and spawns.
-func Remove(prog *migo.Program) {
-	rmvr := undefRemover{prog: prog}
+//
+// Returns a *migo.ErrUnsupportedStatement error if prog contains
+// a statement kind that is unknown and not a migo.ExtStatement.
+func Remove(prog *migo.Program) error {
 	for i := range prog.Funcs {
-		rmvr.traverse(&prog.Funcs[i].Stmts)
+		rmvr := undefRemover{prog: prog, fn: prog.Funcs[i]}
+		if err := rmvr.traverse(&prog.Funcs[i].Stmts); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 type undefRemover struct {
 	prog *migo.Program
+	fn   *migo.Function // Function being traversed.
+}
+
+// traverseBlocks traverses each of the blocks in place.
+func (r undefRemover) traverseBlocks(blocks ...*[]migo.Statement) error {
+	for _, stmts := range blocks {
+		if err := r.traverse(stmts); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r undefRemover) traverse(stmts *[]migo.Statement) {
+func (r undefRemover) traverse(stmts *[]migo.Statement) error {
 	ss := *stmts
 	for i := 0; i < len(ss); i++ {
 		switch stmt := (ss)[i].(type) {
 		case *migo.IfForStatement:
-			r.traverse(&stmt.Then)
-			r.traverse(&stmt.Else)
+			if err := r.traverseBlocks(&stmt.Then, &stmt.Else); err != nil {
+				return err
+			}
 			isThenTau, isElseTau := false, false
 			if len(stmt.Then) == 1 {
 				_, isThenTau = stmt.Then[0].(*migo.TauStatement)
@@ -39,8 +57,9 @@ func (r undefRemover) traverse(stmts *[]migo.Statement) {
 				ss[i] = stmt
 			}
 		case *migo.IfStatement:
-			r.traverse(&stmt.Then)
-			r.traverse(&stmt.Else)
+			if err := r.traverseBlocks(&stmt.Then, &stmt.Else); err != nil {
+				return err
+			}
 			isThenTau, isElseTau := false, false
 			if len(stmt.Then) == 1 {
 				_, isThenTau = stmt.Then[0].(*migo.TauStatement)
@@ -56,8 +75,10 @@ func (r undefRemover) traverse(stmts *[]migo.Statement) {
 				ss[i] = stmt
 			}
 		case *migo.SelectStatement:
-			for i, _ := range stmt.Cases {
-				r.traverse(&stmt.Cases[i])
+			for i := range stmt.Cases {
+				if err := r.traverse(&stmt.Cases[i]); err != nil {
+					return err
+				}
 			}
 			tau, tauCase, tauOne := true, false, false
 			for i, _ := range stmt.Cases {
@@ -90,10 +111,28 @@ func (r undefRemover) traverse(stmts *[]migo.Statement) {
 				ss = append(ss[:i], ss[i+1:]...)
 				i--
 			}
+		case migo.ExtStatement:
+			var blocks [][]migo.Statement
+			for _, b := range stmt.Blocks() {
+				b = append([]migo.Statement(nil), b...)
+				if err := r.traverse(&b); err != nil {
+					return err
+				}
+				blocks = append(blocks, b)
+			}
+			ss[i] = stmt.WithBlocks(blocks)
+		case *migo.NewChanStatement, *migo.CloseStatement, *migo.SendStatement, *migo.RecvStatement,
+			*migo.TauStatement, *migo.NewMem, *migo.MemRead, *migo.MemWrite,
+			*migo.NewSyncMutex, *migo.SyncMutexLock, *migo.SyncMutexUnlock,
+			*migo.NewSyncRWMutex, *migo.SyncRWMutexRLock, *migo.SyncRWMutexRUnlock:
+			// no-op
+		default:
+			return &migo.ErrUnsupportedStatement{Func: r.fn.Name, Stmt: stmt}
 		}
 	}
 	if len(ss) == 0 {
 		ss = []migo.Statement{&migo.TauStatement{}}
 	}
 	*stmts = ss
+	return nil
 }
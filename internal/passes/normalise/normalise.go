@@ -0,0 +1,264 @@
+// Package normalise defines a transformation pass to rewrite control-flow
+// statements (if and select) of a MiGo program into a canonical form.
+//
+// The transformation applies the following rewrites to every block of
+// statements, innermost blocks first:
+//
+//	Drop τ from sequences with more than one statement
+//	Replace if with identical branches by the body of the branch
+//	Hoist common prefixes and suffixes of if branches out of the if
+//	Merge duplicate select cases
+//	Replace select with a single case by its prefix and continuation
+//
+// Statements are compared structurally, ignoring Go source positions, so
+// programs extracted from equivalent sources normalise to the same form.
+//
+// # Usage
+//
+// To normalise all functions in a program:
+//
+//	normalise.Rewrite(prog)
+package normalise
+
+import (
+	"reflect"
+
+	"github.com/JorgeGCoelho/migo/v3"
+)
+
+// Rewrite normalises the body of every function in Program prog in place.
+// The statements of prog are not modified, the rewritten bodies are made of
+// new and unchanged statements.
+func Rewrite(prog *migo.Program) {
+	for _, fn := range prog.Funcs {
+		fn.Stmts = Block(fn.Stmts)
+	}
+}
+
+// Block returns the normalised form of the statements stmts.
+//
+// The result is never empty, an empty block is normalised to τ.
+func Block(stmts []migo.Statement) []migo.Statement {
+	ss := make([]migo.Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		ss = append(ss, rewrite(stmt)...)
+	}
+	ss = dropTau(ss)
+	if len(ss) == 0 {
+		ss = []migo.Statement{&migo.TauStatement{}}
+	}
+	return ss
+}
+
+// rewrite returns the normalised form of stmt, which may be
+// more than one statement if stmt is flattened into its parent block.
+func rewrite(stmt migo.Statement) []migo.Statement {
+	switch stmt := stmt.(type) {
+	case *migo.IfStatement:
+		return rewriteIf(Block(stmt.Then), Block(stmt.Else))
+
+	case *migo.IfForStatement:
+		return []migo.Statement{&migo.IfForStatement{
+			ForCond: stmt.ForCond,
+			Then:    Block(stmt.Then),
+			Else:    Block(stmt.Else),
+		}}
+
+	case *migo.SelectStatement:
+		return rewriteSelect(stmt)
+
+	case migo.ExtStatement:
+		var blocks [][]migo.Statement
+		for _, b := range stmt.Blocks() {
+			if len(b) > 0 {
+				b = Block(b)
+			}
+			blocks = append(blocks, b)
+		}
+		return []migo.Statement{stmt.WithBlocks(blocks)}
+	}
+	return []migo.Statement{stmt}
+}
+
+// rewriteIf hoists common prefixes and suffixes out of the branches then
+// and els, and removes the conditional if nothing is left to choose from.
+func rewriteIf(then, els []migo.Statement) []migo.Statement {
+	then, els = dropTau(then), dropTau(els)
+	pre := 0
+	for pre < len(then) && pre < len(els) && Equal(then[pre], els[pre]) {
+		pre++
+	}
+	suf := 0
+	for suf < len(then)-pre && suf < len(els)-pre &&
+		Equal(then[len(then)-1-suf], els[len(els)-1-suf]) {
+		suf++
+	}
+	var ss []migo.Statement
+	ss = append(ss, then[:pre]...)
+	thenRest, elseRest := then[pre:len(then)-suf], els[pre:len(els)-suf]
+	if len(thenRest) > 0 || len(elseRest) > 0 {
+		ss = append(ss, &migo.IfStatement{Then: tauIfEmpty(thenRest), Else: tauIfEmpty(elseRest)})
+	}
+	ss = append(ss, then[len(then)-suf:]...)
+	return ss
+}
+
+// rewriteSelect normalises the cases of stmt, merges duplicated cases and
+// inlines the select if only one case is left.
+func rewriteSelect(stmt *migo.SelectStatement) []migo.Statement {
+	var cases [][]migo.Statement
+	for _, c := range stmt.Cases {
+		if len(c) == 0 {
+			c = []migo.Statement{&migo.TauStatement{}}
+		}
+		// The prefix of a case is kept as is, even if it is τ.
+		body := []migo.Statement{c[0]}
+		if rest := Block(c[1:]); !isTau(rest) {
+			body = append(body, rest...)
+		}
+		dup := false
+		for _, existing := range cases {
+			if equalStmts(existing, body) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			cases = append(cases, body)
+		}
+	}
+	if len(cases) == 1 {
+		return dropTau(cases[0])
+	}
+	return []migo.Statement{&migo.SelectStatement{Cases: cases}}
+}
+
+// dropTau removes τ from stmts if there is more than one statement.
+func dropTau(stmts []migo.Statement) []migo.Statement {
+	ss := make([]migo.Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		if _, isTau := stmt.(*migo.TauStatement); !isTau {
+			ss = append(ss, stmt)
+		}
+	}
+	if len(ss) == 0 && len(stmts) > 0 {
+		ss = append(ss, stmts[0])
+	}
+	return ss
+}
+
+// isTau returns true if stmts is a single τ.
+func isTau(stmts []migo.Statement) bool {
+	if len(stmts) != 1 {
+		return false
+	}
+	_, ok := stmts[0].(*migo.TauStatement)
+	return ok
+}
+
+func tauIfEmpty(stmts []migo.Statement) []migo.Statement {
+	if len(stmts) == 0 {
+		return []migo.Statement{&migo.TauStatement{}}
+	}
+	return stmts
+}
+
+// Equal returns true if Statements a and b are structurally equal.
+//
+// Go source positions of statements are not compared. Statements of
+// unknown kinds, including migo.ExtStatement, are compared deeply.
+func Equal(a, b migo.Statement) bool {
+	switch a := a.(type) {
+	case *migo.TauStatement:
+		_, ok := b.(*migo.TauStatement)
+		return ok
+	case *migo.SendStatement:
+		b, ok := b.(*migo.SendStatement)
+		return ok && a.Chan == b.Chan
+	case *migo.RecvStatement:
+		b, ok := b.(*migo.RecvStatement)
+		return ok && a.Chan == b.Chan
+	case *migo.CloseStatement:
+		b, ok := b.(*migo.CloseStatement)
+		return ok && a.Chan == b.Chan
+	case *migo.NewChanStatement:
+		b, ok := b.(*migo.NewChanStatement)
+		return ok && a.Name.Name() == b.Name.Name() && a.Chan == b.Chan && a.Size == b.Size
+	case *migo.CallStatement:
+		b, ok := b.(*migo.CallStatement)
+		return ok && a.Name == b.Name && equalParams(a.Params, b.Params)
+	case *migo.SpawnStatement:
+		b, ok := b.(*migo.SpawnStatement)
+		return ok && a.Name == b.Name && equalParams(a.Params, b.Params)
+	case *migo.IfStatement:
+		b, ok := b.(*migo.IfStatement)
+		return ok && equalStmts(a.Then, b.Then) && equalStmts(a.Else, b.Else)
+	case *migo.IfForStatement:
+		b, ok := b.(*migo.IfForStatement)
+		return ok && a.ForCond == b.ForCond && equalStmts(a.Then, b.Then) && equalStmts(a.Else, b.Else)
+	case *migo.SelectStatement:
+		b, ok := b.(*migo.SelectStatement)
+		if !ok || len(a.Cases) != len(b.Cases) {
+			return false
+		}
+		for i := range a.Cases {
+			if !equalStmts(a.Cases[i], b.Cases[i]) {
+				return false
+			}
+		}
+		return true
+	case *migo.NewMem:
+		b, ok := b.(*migo.NewMem)
+		return ok && a.Name.Name() == b.Name.Name()
+	case *migo.MemRead:
+		b, ok := b.(*migo.MemRead)
+		return ok && a.Name == b.Name
+	case *migo.MemWrite:
+		b, ok := b.(*migo.MemWrite)
+		return ok && a.Name == b.Name
+	case *migo.NewSyncMutex:
+		b, ok := b.(*migo.NewSyncMutex)
+		return ok && a.Name.Name() == b.Name.Name()
+	case *migo.SyncMutexLock:
+		b, ok := b.(*migo.SyncMutexLock)
+		return ok && a.Name == b.Name
+	case *migo.SyncMutexUnlock:
+		b, ok := b.(*migo.SyncMutexUnlock)
+		return ok && a.Name == b.Name
+	case *migo.NewSyncRWMutex:
+		b, ok := b.(*migo.NewSyncRWMutex)
+		return ok && a.Name.Name() == b.Name.Name()
+	case *migo.SyncRWMutexRLock:
+		b, ok := b.(*migo.SyncRWMutexRLock)
+		return ok && a.Name == b.Name
+	case *migo.SyncRWMutexRUnlock:
+		b, ok := b.(*migo.SyncRWMutexRUnlock)
+		return ok && a.Name == b.Name
+	}
+	// Unknown statements may not be comparable with ==.
+	return reflect.DeepEqual(a, b)
+}
+
+func equalStmts(a, b []migo.Statement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalParams(a, b []*migo.Parameter) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Caller.Name() != b[i].Caller.Name() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package normalise
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+// Tests that normalising s gives the same program as parsing expect.
+func testRewrite(t *testing.T, s, expect string) {
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	want, err := parser.Parse(strings.NewReader(expect))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	Rewrite(prog)
+	if len(prog.Funcs) != len(want.Funcs) {
+		t.Errorf("expecting %d functions but got %d", len(want.Funcs), len(prog.Funcs))
+		t.FailNow()
+	}
+	for i := range prog.Funcs {
+		if !equalStmts(want.Funcs[i].Stmts, prog.Funcs[i].Stmts) {
+			t.Errorf("normalised function mismatch, want:\n%sgot:\n%s", want.Funcs[i], prog.Funcs[i])
+		}
+	}
+}
+
+func TestDropTau(t *testing.T) {
+	testRewrite(t, `def main(): tau; send x; tau; recv y; tau;`,
+		`def main(): send x; recv y;`)
+	testRewrite(t, `def main(): tau; tau;`,
+		`def main(): tau;`)
+}
+
+func TestIfIdenticalBranches(t *testing.T) {
+	testRewrite(t, `def main(): if if send x; else send x; endif; else send x; tau; endif;`,
+		`def main(): send x;`)
+	testRewrite(t, `def main(): if tau; else tau; endif; recv x;`,
+		`def main(): recv x;`)
+}
+
+func TestIfHoist(t *testing.T) {
+	testRewrite(t, `def main(): if send x; recv y; close z; else send x; close z; endif;`,
+		`def main(): send x; if recv y; else tau; endif; close z;`)
+	testRewrite(t, `def main(): if send x; recv y; else send x; recv z; endif;`,
+		`def main(): send x; if recv y; else recv z; endif;`)
+}
+
+func TestSelectMerge(t *testing.T) {
+	testRewrite(t, `def main(): select case send x; tau; case send x; case recv y; endselect;`,
+		`def main(): select case send x; case recv y; endselect;`)
+	testRewrite(t, `def main(): select case tau; case tau; case recv y; call f(y); endselect;`,
+		`def main(): select case tau; case recv y; call f(y); endselect;`)
+}
+
+func TestSelectSingleCase(t *testing.T) {
+	testRewrite(t, `def main(): select case recv x; send y; endselect; close x;`,
+		`def main(): recv x; send y; close x;`)
+	testRewrite(t, `def main(): select case tau; send y; case tau; send y; endselect;`,
+		`def main(): send y;`)
+}
+
+// Tests that equivalent programs normalise to the same program.
+func TestCanonical(t *testing.T) {
+	a := `def main(): if tau; send x; else send x; endif; select case recv y; case recv y; tau; endselect;`
+	b := `def main(): send x; recv y;`
+	testRewrite(t, a, b)
+	testRewrite(t, b, b)
+}
+
+// extStmt is a user-defined Statement, which is not comparable with ==.
+type extStmt struct{ blocks [][]migo.Statement }
+
+func (s extStmt) String() string             { return "ext" }
+func (s extStmt) IsTau() bool                { return false }
+func (s extStmt) Blocks() [][]migo.Statement { return s.blocks }
+func (s extStmt) WithBlocks(blocks [][]migo.Statement) migo.ExtStatement {
+	return extStmt{blocks: blocks}
+}
+
+// Tests that blocks of user-defined statements are normalised, and that
+// user-defined statements are compared without panicking.
+func TestExtStatement(t *testing.T) {
+	ext := extStmt{blocks: [][]migo.Statement{{&migo.TauStatement{}, &migo.SendStatement{Chan: "x"}}}}
+	got := Block([]migo.Statement{ext})
+	if want := []migo.Statement{&migo.SendStatement{Chan: "x"}}; !equalStmts(want, got[0].(extStmt).blocks[0]) {
+		t.Errorf("expects normalised block %v but got %v", want, got[0].(extStmt).blocks[0])
+	}
+	if want := 2; len(ext.blocks[0]) != want {
+		t.Errorf("expects original block to be unchanged but got %v", ext.blocks[0])
+	}
+	if !Equal(ext, ext) {
+		t.Errorf("expects statement to equal itself")
+	}
+	other := extStmt{blocks: [][]migo.Statement{{&migo.RecvStatement{Chan: "x"}}}}
+	if Equal(ext, other) {
+		t.Errorf("expects statements with different blocks to differ")
+	}
+	// Identical if branches of user-defined statements are merged.
+	branches := Block([]migo.Statement{&migo.IfStatement{Then: []migo.Statement{ext}, Else: []migo.Statement{ext}}})
+	if len(branches) != 1 || !Equal(branches[0], got[0]) {
+		t.Errorf("expects if to be merged but got %v", branches)
+	}
+}
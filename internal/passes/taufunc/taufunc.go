@@ -24,9 +24,7 @@ package taufunc
 // removes empty (i.e. no communication) migo Functions from migo Programs.
 
 import (
-	"fmt"
 	"github.com/JorgeGCoelho/migo/v3"
-	"log"
 
 	"github.com/JorgeGCoelho/migo/v3/internal/ctrlflow"
 )
@@ -38,15 +36,24 @@ import (
 // the return value indicates if fn should be removed.
 //
 // If fn is marked remove, prog will be updated accordingly.
-func Find(prog *migo.Program, visitTauFn func(fn *migo.Function) bool) {
+//
+// Returns a *migo.ErrUnsupportedStatement error if prog contains
+// a statement kind that is unknown and not a migo.ExtStatement.
+func Find(prog *migo.Program, visitTauFn func(fn *migo.Function) bool) error {
+	graph, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		return err
+	}
 	tff := &tauFuncFinder{
-		graph: ctrlflow.NewGraph(prog),
+		graph: graph,
 		istau: make(map[*ctrlflow.Node]bool),
 	}
 	func2node := make(map[*migo.Function]*ctrlflow.Node)
 	for _, node := range tff.graph.Nodes {
 		func2node[node.Func()] = node
-		tff.taintTau(node)
+		if err := tff.taintTau(node); err != nil {
+			return err
+		}
 	}
 	tff.propagate()
 	for i := 0; i < len(prog.Funcs); i++ {
@@ -60,6 +67,7 @@ func Find(prog *migo.Program, visitTauFn func(fn *migo.Function) bool) {
 			}
 		}
 	}
+	return nil
 }
 
 // Remove marks taufn to be removed from its parent Program.
@@ -80,13 +88,15 @@ type tauFuncFinder struct {
 	istau map[*ctrlflow.Node]bool
 }
 
-func (t *tauFuncFinder) taintTau(n *ctrlflow.Node) {
-	t.istau[n] = t.isTau(n, n.Func().Stmts)
+func (t *tauFuncFinder) taintTau(n *ctrlflow.Node) error {
+	istau, err := t.isTau(n, n.Func().Stmts)
+	t.istau[n] = istau
+	return err
 }
 
 // isTau inspects Statements stmts and
 // returns true if all statements can be reduced to tau.
-func (t *tauFuncFinder) isTau(n *ctrlflow.Node, stmts []migo.Statement) bool {
+func (t *tauFuncFinder) isTau(n *ctrlflow.Node, stmts []migo.Statement) (bool, error) {
 	var istainted bool
 	for _, stmt := range stmts {
 		switch stmt := stmt.(type) {
@@ -103,12 +113,18 @@ func (t *tauFuncFinder) isTau(n *ctrlflow.Node, stmts []migo.Statement) bool {
 		case *migo.TauStatement:
 
 		case *migo.IfStatement:
-			istainted = istainted || !t.isTau(n, stmt.Then)
-			istainted = istainted || !t.isTau(n, stmt.Else)
+			istau, err := t.isTauBlocks(n, stmt.Then, stmt.Else)
+			if err != nil {
+				return false, err
+			}
+			istainted = istainted || !istau
 
 		case *migo.IfForStatement:
-			istainted = istainted || !t.isTau(n, stmt.Then)
-			istainted = istainted || !t.isTau(n, stmt.Else)
+			istau, err := t.isTauBlocks(n, stmt.Then, stmt.Else)
+			if err != nil {
+				return false, err
+			}
+			istainted = istainted || !istau
 
 		case *migo.CallStatement, *migo.SpawnStatement:
 			// skip for now
@@ -122,11 +138,29 @@ func (t *tauFuncFinder) isTau(n *ctrlflow.Node, stmts []migo.Statement) bool {
 		case *migo.NewSyncRWMutex, *migo.SyncRWMutexRLock, *migo.SyncRWMutexRUnlock:
 			istainted = true
 
+		case migo.ExtStatement:
+			istau, err := t.isTauBlocks(n, stmt.Blocks()...)
+			if err != nil {
+				return false, err
+			}
+			istainted = istainted || !istau || !stmt.IsTau()
+
 		default:
-			log.Fatal(fmt.Errorf("passes/taufunc: statement kind not found: %T", stmt))
+			return false, &migo.ErrUnsupportedStatement{Func: n.Func().Name, Stmt: stmt}
+		}
+	}
+	return !istainted, nil
+}
+
+// isTauBlocks returns true if all statements in blocks can be reduced to tau.
+func (t *tauFuncFinder) isTauBlocks(n *ctrlflow.Node, blocks ...[]migo.Statement) (bool, error) {
+	for _, stmts := range blocks {
+		istau, err := t.isTau(n, stmts)
+		if err != nil || !istau {
+			return false, err
 		}
 	}
-	return !istainted
+	return true, nil
 }
 
 // progpagate taints caller (CFG parent) of non-tau functions to be
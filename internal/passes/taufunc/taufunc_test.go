@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/JorgeGCoelho/migo/v3"
 	"github.com/JorgeGCoelho/migo/v3/parser"
 )
 
@@ -133,3 +134,44 @@ def e(ch): send ch;`
 		}
 	}
 }
+
+// extStmt is a user-defined Statement.
+type extStmt struct{ tau bool }
+
+func (s extStmt) String() string                                  { return "ext" }
+func (s extStmt) IsTau() bool                                     { return s.tau }
+func (s extStmt) Blocks() [][]migo.Statement                      { return nil }
+func (s extStmt) WithBlocks([][]migo.Statement) migo.ExtStatement { return s }
+
+// Tests that user-defined statements are treated as declared by IsTau.
+func TestExtStatement(t *testing.T) {
+	prog := migo.NewProgram()
+	tauFn := migo.NewFunction("tau")
+	tauFn.AddStmts(extStmt{tau: true})
+	nonTauFn := migo.NewFunction("nontau")
+	nonTauFn.AddStmts(extStmt{tau: false})
+	prog.AddFunction(tauFn)
+	prog.AddFunction(nonTauFn)
+	if err := Find(prog, Remove); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if want, got := 1, len(prog.Funcs); want != got {
+		t.Errorf("expects %d functions but got %d", want, got)
+	}
+	if _, found := prog.Function("nontau"); !found {
+		t.Errorf("expects nontau function to remain")
+	}
+}
+
+// Tests that unknown statements are reported as errors.
+func TestUnsupportedStatement(t *testing.T) {
+	prog := migo.NewProgram()
+	fn := migo.NewFunction("main")
+	fn.AddStmts(&migo.TauStatement{}, struct{ migo.Statement }{&migo.TauStatement{}})
+	prog.AddFunction(fn)
+	err := Find(prog, Remove)
+	if _, ok := err.(*migo.ErrUnsupportedStatement); !ok {
+		t.Errorf("expects *migo.ErrUnsupportedStatement but got %v", err)
+	}
+}
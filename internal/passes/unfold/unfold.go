@@ -0,0 +1,211 @@
+// Package unfold defines a transformation pass to unfold recursion and
+// expand loops to a fixed bound, giving loop-free programs.
+//
+// Recursive functions are found as the strongly connected components of the
+// control flow graph. The transformation algorithm is as follows:
+//
+//	Foreach function f in a recursive SCC, and level i in 1..depth:
+//		Create f#u<i> as a copy of f
+//	Foreach for-loop conditional in f, in iteration j in 1..loopBound:
+//		Replace the back edge call of f in its body by the body of f,
+//		in iteration j+1
+//		Replace the conditional by a plain conditional
+//	Foreach for-loop conditional in f, in iteration loopBound+1:
+//		Replace the conditional by a plain conditional, with body stub
+//	Foreach other call or spawn of g in f#u<i>:
+//		If g is in the same SCC as f:
+//			Replace with call or spawn of g#u<i+1>, or stub if i = depth
+//
+// The back edge of a for-loop conditional in f is a call of f in the body
+// of the loop passing the parameters of f unchanged. The original function
+// f is the level 0 copy, so callers outside the SCC are unchanged. Each
+// stub inserted is a fresh copy of the stub statements.
+package unfold
+
+import (
+	"fmt"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/clone"
+	"github.com/JorgeGCoelho/migo/v3/internal/ctrlflow"
+)
+
+// Unfold unfolds recursive functions of Program prog depth times, and
+// expands for-loops to at most loopBound iterations. Recursive calls and
+// spawns past depth, and iterations past loopBound, are replaced by the
+// statements stub, or τ if stub is empty.
+//
+// Returns a *migo.ErrUnsupportedStatement error if prog contains
+// a statement kind that is unknown and not a migo.ExtStatement.
+func Unfold(prog *migo.Program, depth, loopBound int, stub []migo.Statement) error {
+	graph, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		return err
+	}
+	u := unfolder{
+		prog:   prog,
+		scc:    make(map[*migo.Function]int),
+		body:   make(map[*migo.Function][]migo.Statement),
+		copies: make(map[*migo.Function][]*migo.Function),
+		stub:   stub,
+
+		depth:     depth,
+		loopBound: loopBound,
+	}
+	if len(u.stub) == 0 {
+		u.stub = []migo.Statement{&migo.TauStatement{}}
+	}
+	for i, scc := range graph.SCCs() {
+		if !ctrlflow.IsRecursive(scc) {
+			continue
+		}
+		for _, n := range scc {
+			u.scc[n.Func()] = i
+		}
+	}
+	for _, fn := range prog.Funcs {
+		u.body[fn] = fn.Stmts
+	}
+	for _, fn := range prog.Funcs {
+		if _, recursive := u.scc[fn]; recursive {
+			u.copies[fn] = []*migo.Function{fn}
+		}
+	}
+	funcs := append([]*migo.Function{}, prog.Funcs...)
+	for _, fn := range funcs {
+		fn.Stmts = u.stmts(at{fn: fn}, u.body[fn])
+	}
+	return nil
+}
+
+type unfolder struct {
+	prog   *migo.Program
+	scc    map[*migo.Function]int              // SCC index of recursive functions.
+	body   map[*migo.Function][]migo.Statement // Original function bodies.
+	copies map[*migo.Function][]*migo.Function // Unfolded copies by level.
+	stub   []migo.Statement
+
+	depth     int // Levels of recursive functions unfolded.
+	loopBound int // Iterations of for-loops expanded in place.
+}
+
+// copyAt returns the unfolded copy of recursive function fn at level.
+func (u *unfolder) copyAt(fn *migo.Function, level int) *migo.Function {
+	for len(u.copies[fn]) <= level {
+		l := len(u.copies[fn])
+		c := migo.NewFunction(fmt.Sprintf("%s#u%d", fn.Name, l))
+		c.Params = fn.Params
+		c.HasComm = fn.HasComm
+		u.copies[fn] = append(u.copies[fn], c)
+		u.prog.AddFunction(c)
+		c.Stmts = u.stmts(at{fn: fn, level: l}, u.body[fn])
+	}
+	return u.copies[fn][level]
+}
+
+// callee returns the name of the function a call to name from pos should
+// be redirected to, or false if the call should be replaced by stub.
+func (u *unfolder) callee(pos at, name string) (string, bool) {
+	fn, level := pos.fn, pos.level
+	callee, found := u.prog.Function(name)
+	if !found {
+		return name, true
+	}
+	calleeSCC, recursive := u.scc[callee]
+	if fnSCC, ok := u.scc[fn]; !recursive || !ok || fnSCC != calleeSCC {
+		return name, true
+	}
+	if level >= u.depth {
+		return "", false
+	}
+	return u.copyAt(callee, level+1).Name, true
+}
+
+// at is a position in the unfolded body of a function.
+type at struct {
+	fn    *migo.Function
+	level int  // Level of the copy of fn.
+	iter  int  // Iterations of the enclosing for-loops before this one.
+	loop  bool // In the body of a for-loop conditional.
+}
+
+// stmts returns a copy of stmts as the body of fn at the given position.
+func (u *unfolder) stmts(pos at, stmts []migo.Statement) []migo.Statement {
+	ss := make([]migo.Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *migo.CallStatement:
+			if pos.loop && isBackEdge(pos.fn, stmt) {
+				// Expand the next iteration of the loop in place.
+				ss = append(ss, u.stmts(at{fn: pos.fn, level: pos.level, iter: pos.iter + 1}, u.body[pos.fn])...)
+			} else if name, ok := u.callee(pos, stmt.Name); ok {
+				ss = append(ss, &migo.CallStatement{Name: name, Params: stmt.Params})
+			} else {
+				ss = append(ss, u.stubCopy()...)
+			}
+
+		case *migo.SpawnStatement:
+			if name, ok := u.callee(pos, stmt.Name); ok {
+				ss = append(ss, &migo.SpawnStatement{Name: name, Params: stmt.Params})
+			} else {
+				ss = append(ss, u.stubCopy()...)
+			}
+
+		case *migo.IfStatement:
+			ss = append(ss, &migo.IfStatement{
+				Then: u.stmts(pos, stmt.Then),
+				Else: u.stmts(pos, stmt.Else),
+			})
+
+		case *migo.IfForStatement:
+			then := u.stubCopy()
+			if pos.iter < u.loopBound {
+				body := pos
+				body.loop = true
+				then = u.stmts(body, stmt.Then)
+			}
+			ss = append(ss, &migo.IfStatement{
+				Then: then,
+				Else: u.stmts(pos, stmt.Else),
+			})
+
+		case *migo.SelectStatement:
+			cases := make([][]migo.Statement, len(stmt.Cases))
+			for i, c := range stmt.Cases {
+				cases[i] = u.stmts(pos, c)
+			}
+			ss = append(ss, &migo.SelectStatement{Cases: cases})
+
+		case migo.ExtStatement:
+			var blocks [][]migo.Statement
+			for _, b := range stmt.Blocks() {
+				blocks = append(blocks, u.stmts(pos, b))
+			}
+			ss = append(ss, stmt.WithBlocks(blocks))
+
+		default:
+			ss = append(ss, stmt)
+		}
+	}
+	return ss
+}
+
+// isBackEdge returns true if call is a call to fn passing its parameters
+// unchanged, i.e. the back edge of a for-loop in fn.
+func isBackEdge(fn *migo.Function, call *migo.CallStatement) bool {
+	if call.Name != fn.Name || len(call.Params) != len(fn.Params) {
+		return false
+	}
+	for i, p := range call.Params {
+		if p.Caller.Name() != fn.Params[i].Callee.Name() {
+			return false
+		}
+	}
+	return true
+}
+
+// stubCopy returns a copy of the stub statements, so that the stubs
+// inserted at different places do not share statements.
+func (u *unfolder) stubCopy() []migo.Statement {
+	return clone.Stmts(u.stub)
+}
@@ -0,0 +1,263 @@
+package unfold
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/ctrlflow"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+// isLoopFree returns true if the program has no recursion or for-loops.
+func isLoopFree(prog *migo.Program) bool {
+	graph, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		return false
+	}
+	for _, scc := range graph.SCCs() {
+		if ctrlflow.IsRecursive(scc) {
+			return false
+		}
+	}
+	for _, fn := range prog.Funcs {
+		if hasLoop(fn.Stmts) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasLoop returns true if stmts contains a for-loop conditional.
+func hasLoop(stmts []migo.Statement) bool {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *migo.IfForStatement:
+			return true
+		case *migo.IfStatement:
+			if hasLoop(stmt.Then) || hasLoop(stmt.Else) {
+				return true
+			}
+		case *migo.SelectStatement:
+			for _, c := range stmt.Cases {
+				if hasLoop(c) {
+					return true
+				}
+			}
+		case migo.ExtStatement:
+			for _, b := range stmt.Blocks() {
+				if hasLoop(b) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func TestUnfoldSelfRecursion(t *testing.T) {
+	s := `
+def main(): call f(x);
+def f(x): send x; call f(x);
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := Unfold(prog, 2, 0, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if want, got := 4, len(prog.Funcs); want != got {
+		t.Errorf("expecting %d functions but got %d:\n%s", want, got, prog)
+	}
+	if !isLoopFree(prog) {
+		t.Errorf("expecting loop-free program but got:\n%s", prog)
+	}
+	f2, found := prog.Function("f#u2")
+	if !found {
+		t.Errorf("expecting f#u2 in program but got:\n%s", prog)
+		t.FailNow()
+	}
+	if _, ok := f2.Stmts[1].(*migo.TauStatement); !ok {
+		t.Errorf("expecting recursion at bound to be replaced by tau but got:\n%s", f2)
+	}
+	f1, _ := prog.Function("f#u1")
+	if call, ok := f1.Stmts[1].(*migo.CallStatement); !ok || call.Name != "f#u2" {
+		t.Errorf("expecting f#u1 to call f#u2 but got:\n%s", f1)
+	}
+}
+
+func TestUnfoldMutualRecursion(t *testing.T) {
+	s := `
+def main(): call a(); spawn b();
+def a(): recv x; spawn b();
+def b(): send x; call a(); call c();
+def c(): tau;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	stub := []migo.Statement{&migo.CallStatement{Name: "c"}}
+	if err := Unfold(prog, 1, 0, stub); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// a, b, a#u1, b#u1 (c and main are not recursive)
+	if want, got := 6, len(prog.Funcs); want != got {
+		t.Errorf("expecting %d functions but got %d:\n%s", want, got, prog)
+	}
+	if !isLoopFree(prog) {
+		t.Errorf("expecting loop-free program but got:\n%s", prog)
+	}
+	b1, found := prog.Function("b#u1")
+	if !found {
+		t.Errorf("expecting b#u1 in program but got:\n%s", prog)
+		t.FailNow()
+	}
+	if call, ok := b1.Stmts[1].(*migo.CallStatement); !ok || call.Name != "c" {
+		t.Errorf("expecting recursion at bound to be replaced by stub but got:\n%s", b1)
+	}
+}
+
+func TestUnfoldLoop(t *testing.T) {
+	prog := migo.NewProgram()
+	loop := migo.NewFunction("loop")
+	loop.AddStmts(&migo.IfForStatement{
+		ForCond: "i",
+		Then:    []migo.Statement{&migo.SendStatement{Chan: "x"}, &migo.CallStatement{Name: "loop"}},
+		Else:    []migo.Statement{&migo.TauStatement{}},
+	})
+	prog.AddFunction(loop)
+	stub := []migo.Statement{&migo.RecvStatement{Chan: "done"}}
+	if err := Unfold(prog, 5, 3, stub); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// The loop is expanded in place, so no copies of loop are needed.
+	if want, got := 1, len(prog.Funcs); want != got {
+		t.Errorf("expecting %d functions but got %d:\n%s", want, got, prog)
+	}
+	if !isLoopFree(prog) {
+		t.Errorf("expecting loop-free program but got:\n%s", prog)
+	}
+	// The body runs at most 3 times, then the loop conditional has stub.
+	iters, stmts := 0, loop.Stmts
+	for {
+		ifStmt, ok := stmts[0].(*migo.IfStatement)
+		if !ok {
+			break
+		}
+		if _, ok := ifStmt.Else[0].(*migo.TauStatement); !ok {
+			t.Errorf("expecting loop exit to be kept but got:\n%s", loop)
+		}
+		if _, ok := ifStmt.Then[0].(*migo.SendStatement); !ok {
+			stmts = ifStmt.Then
+			break
+		}
+		iters++
+		stmts = ifStmt.Then[1:]
+	}
+	if want, got := 3, iters; want != got {
+		t.Errorf("expecting %d iterations but got %d:\n%s", want, got, loop)
+	}
+	if recv, ok := stmts[0].(*migo.RecvStatement); !ok || recv == stub[0] {
+		t.Errorf("expecting iteration past the bound to be replaced by a copy of stub but got:\n%s", loop)
+	}
+}
+
+// Tests that recursion through a function with a for-loop is unfolded
+// depth times, not loopBound times.
+func TestUnfoldLoopRecursion(t *testing.T) {
+	prog := migo.NewProgram()
+	f := migo.NewFunction("f")
+	f.AddStmts(&migo.IfForStatement{
+		ForCond: "i",
+		Then:    []migo.Statement{&migo.SendStatement{Chan: "x"}, &migo.CallStatement{Name: "f"}},
+		Else:    []migo.Statement{&migo.CallStatement{Name: "g"}},
+	})
+	g := migo.NewFunction("g")
+	g.AddStmts(&migo.CallStatement{Name: "f"})
+	prog.AddFunction(f)
+	prog.AddFunction(g)
+	if err := Unfold(prog, 1, 3, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// f, g, f#u1, g#u1
+	if want, got := 4, len(prog.Funcs); want != got {
+		t.Errorf("expecting %d functions but got %d:\n%s", want, got, prog)
+	}
+	if !isLoopFree(prog) {
+		t.Errorf("expecting loop-free program but got:\n%s", prog)
+	}
+}
+
+func TestUnfoldStubCopies(t *testing.T) {
+	s := `
+def main(): call f(x);
+def f(x): if call f(x); else send x; call f(x); endif;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	stubProg, err := parser.Parse(strings.NewReader(`def stub(x): call g(x);`))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	stub := stubProg.Funcs[0].Stmts
+	if err := Unfold(prog, 0, 0, stub); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	f, _ := prog.Function("f")
+	ifStmt := f.Stmts[0].(*migo.IfStatement)
+	a, b := ifStmt.Then[0].(*migo.CallStatement), ifStmt.Else[1].(*migo.CallStatement)
+	if a == b || a == stub[0] || a.Params[0] == b.Params[0] {
+		t.Errorf("expecting each stub to be a distinct copy but got:\n%s", f)
+	}
+	if a.Name != "g" || a.Params[0].Caller.Name() != "x" {
+		t.Errorf("expecting stub call g(x) but got %s", a)
+	}
+}
+
+// extStmt is a user-defined Statement with blocks.
+type extStmt struct{ blocks [][]migo.Statement }
+
+func (s extStmt) String() string             { return "ext" }
+func (s extStmt) IsTau() bool                { return false }
+func (s extStmt) Blocks() [][]migo.Statement { return s.blocks }
+func (s extStmt) WithBlocks(blocks [][]migo.Statement) migo.ExtStatement {
+	return extStmt{blocks: blocks}
+}
+
+func TestUnfoldExtStatement(t *testing.T) {
+	prog := migo.NewProgram()
+	f := migo.NewFunction("f")
+	ext := extStmt{blocks: [][]migo.Statement{{&migo.SendStatement{Chan: "x"}, &migo.CallStatement{Name: "f"}}}}
+	f.AddStmts(ext)
+	prog.AddFunction(f)
+	if err := Unfold(prog, 1, 0, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if !isLoopFree(prog) {
+		t.Errorf("expecting loop-free program but got:\n%s", prog)
+	}
+	if call, ok := f.Stmts[0].(extStmt).blocks[0][1].(*migo.CallStatement); !ok || call.Name != "f#u1" {
+		t.Errorf("expecting recursion in ext block to call f#u1 but got:\n%s", prog)
+	}
+	f1, _ := prog.Function("f#u1")
+	if _, ok := f1.Stmts[0].(extStmt).blocks[0][1].(*migo.TauStatement); !ok {
+		t.Errorf("expecting recursion at bound in ext block to be replaced by stub but got:\n%s", prog)
+	}
+	if call, ok := ext.blocks[0][1].(*migo.CallStatement); !ok || call.Name != "f" {
+		t.Errorf("expecting original ext statement to be unchanged but got %v", ext.blocks[0])
+	}
+}
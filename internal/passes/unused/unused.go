@@ -1,8 +1,10 @@
 // Package unused defines a transformation pass to remove unused functions.
 //
-// The transformation works by recursively removing control flow graph
-// nodes that have no predecessor (caller), until all nodes in the graph
-// are called. A caveat is cycles in the graph are not removed.
+// The transformation is a reachability analysis over the control flow graph:
+// starting from the entry functions, all functions that can be called or
+// spawned (directly or indirectly) are marked used, and all other functions
+// are removed. Unreachable cycles of functions (e.g. mutually recursive
+// helpers) are removed as well.
 package unused
 
 import (
@@ -10,46 +12,49 @@ import (
 	"github.com/JorgeGCoelho/migo/v3/internal/ctrlflow"
 )
 
-// Remove removes all unused functions from Program prog except entry.
-func Remove(prog *migo.Program, entry *migo.Function) {
-	removeQ := findUnusedToplevel(prog)
-	var n *ctrlflow.Node
-	for len(removeQ) > 0 {
-		n, removeQ = removeQ[0], removeQ[1:]
-		if n.Func() == entry { // skip
-			continue
-		}
-		// remove this node from the successors
-		for _, s := range n.Succs {
-			for i, p := range s.Preds {
-				if p.Func() == n.Func() {
-					// remove n from s.Preds
-					s.Preds = append(s.Preds[:i], s.Preds[i+1:]...)
-				}
-			}
-			// If n is the only predecessor of successor s, remove s too
-			if len(s.Preds) == 0 {
-				removeQ = append(removeQ, s)
-			}
-		}
-		// remove function
-		for i := range prog.Funcs {
-			if prog.Funcs[i] == n.Func() {
-				prog.Funcs = append(prog.Funcs[:i], prog.Funcs[i+1:]...)
-				break
-			}
+// Remove removes all functions from Program prog that are not reachable
+// from any of the functions entries, and returns the removed functions.
+//
+// Returns a *migo.ErrUnsupportedStatement error if prog contains
+// a statement kind that is unknown and not a migo.ExtStatement.
+func Remove(prog *migo.Program, entries ...*migo.Function) ([]*migo.Function, error) {
+	graph, err := ctrlflow.NewGraph(prog)
+	if err != nil {
+		return nil, err
+	}
+	used := findReachable(graph, entries)
+	var removed []*migo.Function
+	for i := 0; i < len(prog.Funcs); i++ {
+		if !used[prog.Funcs[i]] {
+			removed = append(removed, prog.Funcs[i])
+			prog.Funcs = append(prog.Funcs[:i], prog.Funcs[i+1:]...)
+			i--
 		}
 	}
+	return removed, nil
 }
 
-// findUnusedToplevel finds all unused toplevel functions.
-func findUnusedToplevel(prog *migo.Program) []*ctrlflow.Node {
-	var emptyNodes []*ctrlflow.Node
-	graph := ctrlflow.NewGraph(prog)
+// findReachable finds all functions reachable from entries in graph.
+func findReachable(graph *ctrlflow.Graph, entries []*migo.Function) map[*migo.Function]bool {
+	used := make(map[*migo.Function]bool)
+	var queue []*ctrlflow.Node
 	for _, node := range graph.Nodes {
-		if len(node.Preds) == 0 {
-			emptyNodes = append(emptyNodes, node)
+		for _, entry := range entries {
+			if node.Func() == entry && !used[entry] {
+				used[entry] = true
+				queue = append(queue, node)
+			}
+		}
+	}
+	var n *ctrlflow.Node
+	for len(queue) > 0 {
+		n, queue = queue[0], queue[1:]
+		for _, s := range n.Succs {
+			if !used[s.Func()] {
+				used[s.Func()] = true
+				queue = append(queue, s)
+			}
 		}
 	}
-	return emptyNodes
+	return used
 }
@@ -0,0 +1,100 @@
+package unused
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+// names returns the names of functions fns.
+func names(fns []*migo.Function) map[string]bool {
+	m := make(map[string]bool)
+	for _, fn := range fns {
+		m[fn.Name] = true
+	}
+	return m
+}
+
+func testRemove(t *testing.T, s string, entries []string, remain, removed []string) {
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	var entryFns []*migo.Function
+	for _, entry := range entries {
+		fn, found := prog.Function(entry)
+		if !found {
+			t.Errorf("entry function %s not found", entry)
+			t.FailNow()
+		}
+		entryFns = append(entryFns, fn)
+	}
+	removedFns, err := Remove(prog, entryFns...)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	got := names(removedFns)
+	if want, got := len(removed), len(got); want != got {
+		t.Errorf("expecting %d functions removed but got %d", want, got)
+	}
+	for _, name := range removed {
+		if !got[name] {
+			t.Errorf("expecting %s to be removed", name)
+		}
+		if _, found := prog.Function(name); found {
+			t.Errorf("expecting %s not in program:\n%s", name, prog)
+		}
+	}
+	for _, name := range remain {
+		if _, found := prog.Function(name); !found {
+			t.Errorf("expecting %s in program:\n%s", name, prog)
+		}
+	}
+}
+
+func TestRemoveUnreachable(t *testing.T) {
+	s := `
+def main(): call a(); spawn b();
+def a(): send x;
+def b(): call d();
+def c(): call d();
+def d(): recv x;
+`
+	testRemove(t, s, []string{"main"}, []string{"main", "a", "b", "d"}, []string{"c"})
+}
+
+func TestRemoveSelfLoop(t *testing.T) {
+	s := `
+def main(): call a();
+def a(): send x;
+def loop(): recv x; call loop();
+`
+	testRemove(t, s, []string{"main"}, []string{"main", "a"}, []string{"loop"})
+}
+
+func TestRemoveCycle(t *testing.T) {
+	s := `
+def main(): call a();
+def a(): send x; call a();
+def b(): call c();
+def c(): spawn d();
+def d(): recv x; call b();
+def e(): call b();
+`
+	testRemove(t, s, []string{"main"}, []string{"main", "a"}, []string{"b", "c", "d", "e"})
+}
+
+func TestRemoveMultipleEntries(t *testing.T) {
+	s := `
+def main(): call a();
+def a(): send x;
+def b(): call c();
+def c(): call b();
+def d(): tau;
+`
+	testRemove(t, s, []string{"main", "b"}, []string{"main", "a", "b", "c"}, []string{"d"})
+}
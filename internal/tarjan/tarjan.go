@@ -0,0 +1,68 @@
+// Package tarjan computes the strongly connected components of directed
+// graphs with Tarjan's algorithm.
+package tarjan
+
+// SCCs returns the strongly connected components of the graph with
+// successors succs, which are reachable from the nodes roots.
+//
+// Components are returned in reverse topological order, i.e. a component
+// is listed before any component with an edge to it. The nodes of a
+// component are in the reverse order of their discovery from roots.
+func SCCs[N comparable](roots []N, succs func(N) []N) [][]N {
+	t := state[N]{
+		index:   make(map[N]int),
+		lowlink: make(map[N]int),
+		onStack: make(map[N]bool),
+		succs:   succs,
+	}
+	for _, n := range roots {
+		if _, visited := t.index[n]; !visited {
+			t.visit(n)
+		}
+	}
+	return t.sccs
+}
+
+// state is the temporary data of the algorithm.
+type state[N comparable] struct {
+	next    int
+	index   map[N]int
+	lowlink map[N]int
+	onStack map[N]bool
+	stack   []N
+	sccs    [][]N
+	succs   func(N) []N
+}
+
+func (t *state[N]) visit(n N) {
+	t.index[n] = t.next
+	t.lowlink[n] = t.next
+	t.next++
+	t.stack = append(t.stack, n)
+	t.onStack[n] = true
+
+	for _, s := range t.succs(n) {
+		if _, visited := t.index[s]; !visited {
+			t.visit(s)
+			if t.lowlink[s] < t.lowlink[n] {
+				t.lowlink[n] = t.lowlink[s]
+			}
+		} else if t.onStack[s] && t.index[s] < t.lowlink[n] {
+			t.lowlink[n] = t.index[s]
+		}
+	}
+
+	if t.lowlink[n] == t.index[n] { // n is root of a component
+		var scc []N
+		for {
+			top := t.stack[len(t.stack)-1]
+			t.stack = t.stack[:len(t.stack)-1]
+			t.onStack[top] = false
+			scc = append(scc, top)
+			if top == n {
+				break
+			}
+		}
+		t.sccs = append(t.sccs, scc)
+	}
+}
@@ -0,0 +1,114 @@
+// Package usage computes how channels, memory and mutexes are used by the
+// functions of a MiGo program.
+//
+// The uses of a name in a function include the uses in the functions it
+// calls or spawns with the name as a parameter, computed as a fixpoint
+// over the program.
+package usage
+
+import "github.com/JorgeGCoelho/migo/v3"
+
+// Use is a set of operations on a name.
+type Use uint16
+
+// Operations on a name.
+const (
+	Send Use = 1 << iota
+	Recv
+	Close
+	Read
+	Write
+	Lock
+	Unlock
+	RLock
+	RUnlock
+)
+
+// Has returns true if u includes all operations of v.
+func (u Use) Has(v Use) bool {
+	return u&v == v
+}
+
+// Uses is the uses of each name in each function.
+type Uses map[*migo.Function]map[string]Use
+
+// Compute returns the uses of names in each function of Program prog.
+func Compute(prog *migo.Program) Uses {
+	uses := make(Uses)
+	for _, fn := range prog.Funcs {
+		uses[fn] = make(map[string]Use)
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range prog.Funcs {
+			if visitStmts(prog, uses, fn, fn.Stmts) {
+				changed = true
+			}
+		}
+	}
+	return uses
+}
+
+// visitStmts adds the uses in stmts to fn, and returns true if the
+// uses of fn changed.
+func visitStmts(prog *migo.Program, uses Uses, fn *migo.Function, stmts []migo.Statement) bool {
+	changed := false
+	add := func(name string, u Use) {
+		if !uses[fn][name].Has(u) {
+			uses[fn][name] |= u
+			changed = true
+		}
+	}
+	addCall := func(name string, params []*migo.Parameter) {
+		callee, found := prog.Function(name)
+		if !found {
+			return
+		}
+		for i, p := range params {
+			if i < len(callee.Params) {
+				add(p.Caller.Name(), uses[callee][callee.Params[i].Callee.Name()])
+			}
+		}
+	}
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *migo.SendStatement:
+			add(stmt.Chan, Send)
+		case *migo.RecvStatement:
+			add(stmt.Chan, Recv)
+		case *migo.CloseStatement:
+			add(stmt.Chan, Close)
+		case *migo.MemRead:
+			add(stmt.Name, Read)
+		case *migo.MemWrite:
+			add(stmt.Name, Write)
+		case *migo.SyncMutexLock:
+			add(stmt.Name, Lock)
+		case *migo.SyncMutexUnlock:
+			add(stmt.Name, Unlock)
+		case *migo.SyncRWMutexRLock:
+			add(stmt.Name, RLock)
+		case *migo.SyncRWMutexRUnlock:
+			add(stmt.Name, RUnlock)
+		case *migo.CallStatement:
+			addCall(stmt.Name, stmt.Params)
+		case *migo.SpawnStatement:
+			addCall(stmt.Name, stmt.Params)
+		case *migo.IfStatement:
+			changed = visitStmts(prog, uses, fn, stmt.Then) || changed
+			changed = visitStmts(prog, uses, fn, stmt.Else) || changed
+		case *migo.IfForStatement:
+			changed = visitStmts(prog, uses, fn, stmt.Then) || changed
+			changed = visitStmts(prog, uses, fn, stmt.Else) || changed
+		case *migo.SelectStatement:
+			for _, c := range stmt.Cases {
+				changed = visitStmts(prog, uses, fn, c) || changed
+			}
+		case migo.ExtStatement:
+			for _, b := range stmt.Blocks() {
+				changed = visitStmts(prog, uses, fn, b) || changed
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,55 @@
+package usage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/parser"
+)
+
+func TestCompute(t *testing.T) {
+	s := `
+def main(): let ch = newchan T, 0; spawn f(ch); call g(ch); close ch;
+def f(a): select case recv a; case tau; endselect;
+def g(b): if send b; else call f(b); endif;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	uses := Compute(prog)
+	for _, tc := range []struct {
+		fn, name string
+		want     Use
+	}{
+		{"main", "ch", Send | Recv | Close},
+		{"f", "a", Recv},
+		{"g", "b", Send | Recv},
+	} {
+		fn, _ := prog.Function(tc.fn)
+		if got := uses[fn][tc.name]; got != tc.want {
+			t.Errorf("%s: expected uses of %s to be %b but got %b", tc.fn, tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestComputeMutex(t *testing.T) {
+	s := `
+def main(): letsync mu rwmutex; letmem m; call f(mu, m);
+def f(a, b): lock a; rlock a; read b; write b;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	uses := Compute(prog)
+	main, _ := prog.Function("main")
+	if want, got := Lock|RLock, uses[main]["mu"]; want != got {
+		t.Errorf("expected uses of mu to be %b but got %b", want, got)
+	}
+	if want, got := Read|Write, uses[main]["m"]; want != got {
+		t.Errorf("expected uses of m to be %b but got %b", want, got)
+	}
+}
@@ -0,0 +1,279 @@
+package ltl
+
+import (
+	"sort"
+	"strings"
+)
+
+// Automaton is a generalised Büchi automaton over the positions of
+// executions, where each state is labelled by the atomic propositions
+// which hold at the position.
+//
+// A run is a sequence of states starting with an initial state, where
+// each state is followed by one of its successors. A run accepts an
+// execution if the labels of its states hold at the respective positions
+// of the execution, and it visits a state of each accepting set infinitely
+// often.
+type Automaton struct {
+	States    []*State
+	Accepting [][]int // Accepting sets, as indices in States.
+}
+
+// State is a state of an Automaton.
+type State struct {
+	ID      int     // Index of the state in the Automaton.
+	Initial bool    // A run may start in the state.
+	Pos     []*Atom // Propositions which hold at the position.
+	Neg     []*Atom // Propositions which do not hold at the position.
+	Succs   []int   // Successor states, as indices in the Automaton.
+}
+
+// Holds returns true if the label of the state holds at a position, given
+// eval which returns whether an atomic proposition holds at the position.
+func (s *State) Holds(eval func(*Atom) bool) bool {
+	for _, a := range s.Pos {
+		if !eval(a) {
+			return false
+		}
+	}
+	for _, a := range s.Neg {
+		if eval(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewAutomaton returns an Automaton accepting exactly the executions which
+// satisfy f, by the tableau construction of Gerth, Peled, Vardi and Wolper.
+func NewAutomaton(f Formula) *Automaton {
+	b := &tableau{index: make(map[string]*node)}
+	init := &node{incoming: map[int]bool{initial: true}, new: formulaSet{}, old: formulaSet{}, next: formulaSet{}}
+	init.new.add(nnf(f, false))
+	b.expand(init)
+
+	a := &Automaton{}
+	for i, n := range b.nodes {
+		s := &State{ID: i, Initial: n.incoming[initial]}
+		for _, key := range n.old.keys() {
+			switch f := n.old[key].(type) {
+			case *Atom:
+				s.Pos = append(s.Pos, f)
+			case *Not:
+				s.Neg = append(s.Neg, f.F.(*Atom))
+			}
+		}
+		a.States = append(a.States, s)
+	}
+	for i, n := range b.nodes {
+		for pred := range n.incoming {
+			if pred != initial {
+				a.States[pred].Succs = append(a.States[pred].Succs, i)
+			}
+		}
+	}
+	for _, s := range a.States {
+		sort.Ints(s.Succs)
+	}
+	// One accepting set for each until subformula.
+	untils := make(formulaSet)
+	for _, n := range b.nodes {
+		for _, f := range n.old {
+			if _, ok := f.(*Until); ok {
+				untils.add(f)
+			}
+		}
+	}
+	for _, key := range untils.keys() {
+		u := untils[key].(*Until)
+		var set []int
+		for i, n := range b.nodes {
+			if !n.old.has(u) || n.old.has(u.R) {
+				set = append(set, i)
+			}
+		}
+		a.Accepting = append(a.Accepting, set)
+	}
+	if len(a.Accepting) == 0 {
+		all := make([]int, len(a.States))
+		for i := range all {
+			all[i] = i
+		}
+		a.Accepting = [][]int{all}
+	}
+	return a
+}
+
+// nnf returns f, or its negation if neg, in negation normal form, i.e.
+// with negations on atomic propositions only, and using only true, false,
+// &&, ||, X, U and R.
+func nnf(f Formula, neg bool) Formula {
+	switch f := f.(type) {
+	case True:
+		if neg {
+			return False{}
+		}
+		return f
+	case False:
+		if neg {
+			return True{}
+		}
+		return f
+	case *Atom:
+		if neg {
+			return &Not{F: f}
+		}
+		return f
+	case *Not:
+		return nnf(f.F, !neg)
+	case *And:
+		if neg {
+			return &Or{L: nnf(f.L, true), R: nnf(f.R, true)}
+		}
+		return &And{L: nnf(f.L, false), R: nnf(f.R, false)}
+	case *Or:
+		if neg {
+			return &And{L: nnf(f.L, true), R: nnf(f.R, true)}
+		}
+		return &Or{L: nnf(f.L, false), R: nnf(f.R, false)}
+	case *Implies:
+		return nnf(&Or{L: &Not{F: f.L}, R: f.R}, neg)
+	case *Next:
+		// Executions are infinite, so X is self-dual.
+		return &Next{F: nnf(f.F, neg)}
+	case *Eventually:
+		return nnf(&Until{L: True{}, R: f.F}, neg)
+	case *Always:
+		return nnf(&Release{L: False{}, R: f.F}, neg)
+	case *Until:
+		if neg {
+			return &Release{L: nnf(f.L, true), R: nnf(f.R, true)}
+		}
+		return &Until{L: nnf(f.L, false), R: nnf(f.R, false)}
+	case *Release:
+		if neg {
+			return &Until{L: nnf(f.L, true), R: nnf(f.R, true)}
+		}
+		return &Release{L: nnf(f.L, false), R: nnf(f.R, false)}
+	}
+	// Formula cannot be implemented outside of the package.
+	panic("ltl: unknown formula " + f.String())
+}
+
+// formulaSet is a set of formulas, keyed by their string representation.
+type formulaSet map[string]Formula
+
+func (s formulaSet) add(f Formula)      { s[f.String()] = f }
+func (s formulaSet) has(f Formula) bool { _, ok := s[f.String()]; return ok }
+
+func (s formulaSet) copy() formulaSet {
+	c := make(formulaSet, len(s))
+	for k, f := range s {
+		c[k] = f
+	}
+	return c
+}
+
+func (s formulaSet) keys() []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// initial is the incoming edge of initial nodes.
+const initial = -1
+
+// node is a node of the tableau, where old holds at the position, new
+// remains to be processed, and next holds at the next position.
+type node struct {
+	incoming       map[int]bool
+	new, old, next formulaSet
+}
+
+func (n *node) split() *node {
+	incoming := make(map[int]bool, len(n.incoming))
+	for i := range n.incoming {
+		incoming[i] = true
+	}
+	return &node{incoming: incoming, new: n.new.copy(), old: n.old.copy(), next: n.next.copy()}
+}
+
+// key identifies the node by its old and next formulas.
+func (n *node) key() string {
+	return strings.Join(n.old.keys(), ",") + "|" + strings.Join(n.next.keys(), ",")
+}
+
+type tableau struct {
+	nodes []*node
+	index map[string]*node // Fully expanded nodes by key.
+}
+
+func (b *tableau) expand(n *node) {
+	for len(n.new) > 0 {
+		key := n.new.keys()[0]
+		f := n.new[key]
+		delete(n.new, key)
+		if n.old.has(f) {
+			continue
+		}
+		switch f := f.(type) {
+		case False:
+			return
+		case True:
+		case *Atom:
+			if n.old.has(&Not{F: f}) {
+				return
+			}
+		case *Not:
+			if n.old.has(f.F) {
+				return
+			}
+		case *And:
+			b.addNew(n, f.L)
+			b.addNew(n, f.R)
+		case *Or:
+			m := n.split()
+			m.old.add(f)
+			b.addNew(m, f.R)
+			b.expand(m)
+			b.addNew(n, f.L)
+		case *Next:
+			n.next.add(f.F)
+		case *Until:
+			m := n.split()
+			m.old.add(f)
+			b.addNew(m, f.R)
+			b.expand(m)
+			b.addNew(n, f.L)
+			n.next.add(f)
+		case *Release:
+			m := n.split()
+			m.old.add(f)
+			b.addNew(m, f.L)
+			b.addNew(m, f.R)
+			b.expand(m)
+			b.addNew(n, f.R)
+			n.next.add(f)
+		}
+		n.old.add(f)
+	}
+	if m, ok := b.index[n.key()]; ok {
+		for i := range n.incoming {
+			m.incoming[i] = true
+		}
+		return
+	}
+	id := len(b.nodes)
+	b.nodes = append(b.nodes, n)
+	b.index[n.key()] = n
+	b.expand(&node{incoming: map[int]bool{id: true}, new: n.next.copy(), old: formulaSet{}, next: formulaSet{}})
+}
+
+func (b *tableau) addNew(n *node, f Formula) {
+	if !n.old.has(f) {
+		n.new.add(f)
+	}
+}
@@ -0,0 +1,155 @@
+// Package ltl is a linear temporal logic (LTL) for properties of MiGo
+// programs.
+//
+// Formulas are evaluated over the executions of a program, i.e. sequences
+// of states and reductions, where executions which terminate or block
+// stutter forever without further reductions. The atomic propositions are:
+//
+//	send(ch)     the reduction sends on channel ch
+//	recv(ch)     the reduction receives on channel ch
+//	close(ch)    the reduction closes channel ch
+//	lock(m)      the reduction locks (or read-locks) mutex m
+//	unlock(m)    the reduction unlocks (or read-unlocks) mutex m
+//	held(m)      mutex m is locked (or read-locked) in the state
+//	terminated   the entry goroutine is terminated in the state
+//	blocked(fn)  a goroutine running function fn is blocked in the state
+//
+// Channels and mutexes are named by the name they are bound to when they
+// are created, e.g. ch for let ch = newchan T, 0, or by their free name.
+//
+// The operators are, by increasing precedence:
+//
+//	a -> b               implication (right associative)
+//	a || b               disjunction
+//	a && b               conjunction
+//	a U b, a R b         until and release (right associative)
+//	!a, X a, F a, G a    negation, next, eventually and always
+//
+// For example, "done is eventually closed" is F close(done), and "mu is
+// never held while sending on out" is G !(held(mu) && send(out)).
+package ltl
+
+import (
+	"fmt"
+)
+
+// Formula is an LTL formula, built from the formula types of this package.
+type Formula interface {
+	String() string
+	formula()
+}
+
+// AtomKind is the kind of an atomic proposition.
+type AtomKind int
+
+// Kinds of atomic propositions.
+const (
+	Send AtomKind = iota
+	Recv
+	Close
+	Lock
+	Unlock
+	Held
+	Terminated
+	Blocked
+)
+
+var atomNames = [...]string{
+	Send:       "send",
+	Recv:       "recv",
+	Close:      "close",
+	Lock:       "lock",
+	Unlock:     "unlock",
+	Held:       "held",
+	Terminated: "terminated",
+	Blocked:    "blocked",
+}
+
+func (k AtomKind) String() string {
+	if int(k) < len(atomNames) {
+		return atomNames[k]
+	}
+	return fmt.Sprintf("AtomKind(%d)", int(k))
+}
+
+// Atom is an atomic proposition.
+type Atom struct {
+	Kind AtomKind
+	Arg  string // Channel, mutex or function name, empty for terminated.
+}
+
+func (a *Atom) String() string {
+	if a.Kind == Terminated {
+		return a.Kind.String()
+	}
+	return fmt.Sprintf("%s(%s)", a.Kind, a.Arg)
+}
+
+// True is the formula which always holds.
+type True struct{}
+
+func (True) String() string { return "true" }
+
+// False is the formula which never holds.
+type False struct{}
+
+func (False) String() string { return "false" }
+
+// Not is the negation of a formula.
+type Not struct{ F Formula }
+
+func (n *Not) String() string { return "!" + n.F.String() }
+
+// And is the conjunction of two formulas.
+type And struct{ L, R Formula }
+
+func (a *And) String() string { return fmt.Sprintf("(%s && %s)", a.L, a.R) }
+
+// Or is the disjunction of two formulas.
+type Or struct{ L, R Formula }
+
+func (o *Or) String() string { return fmt.Sprintf("(%s || %s)", o.L, o.R) }
+
+// Implies is the implication of two formulas.
+type Implies struct{ L, R Formula }
+
+func (i *Implies) String() string { return fmt.Sprintf("(%s -> %s)", i.L, i.R) }
+
+// Next holds if F holds at the next position.
+type Next struct{ F Formula }
+
+func (n *Next) String() string { return "X " + n.F.String() }
+
+// Eventually holds if F holds at some position.
+type Eventually struct{ F Formula }
+
+func (e *Eventually) String() string { return "F " + e.F.String() }
+
+// Always holds if F holds at all positions.
+type Always struct{ F Formula }
+
+func (a *Always) String() string { return "G " + a.F.String() }
+
+// Until holds if R holds at some position, and L holds until then.
+type Until struct{ L, R Formula }
+
+func (u *Until) String() string { return fmt.Sprintf("(%s U %s)", u.L, u.R) }
+
+// Release holds if R holds until and including a position where L holds,
+// or forever.
+type Release struct{ L, R Formula }
+
+func (r *Release) String() string { return fmt.Sprintf("(%s R %s)", r.L, r.R) }
+
+func (*Atom) formula()       {}
+func (True) formula()        {}
+func (False) formula()       {}
+func (*Not) formula()        {}
+func (*And) formula()        {}
+func (*Or) formula()         {}
+func (*Implies) formula()    {}
+func (*Next) formula()       {}
+func (*Eventually) formula() {}
+func (*Always) formula()     {}
+func (*Until) formula()      {}
+func (*Release) formula()    {}
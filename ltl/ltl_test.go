@@ -0,0 +1,181 @@
+package ltl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/ltl"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"F close(done)", "F close(done)"},
+		{"G !(held(mu) && send(out))", "G !(held(mu) && send(out))"},
+		{"a_1(x)", ""}, // Unknown proposition.
+		{"send(ch) U recv(ch) && terminated", "((send(ch) U recv(ch)) && terminated)"},
+		{"lock(m) -> F unlock(m) -> true", "(lock(m) -> (F unlock(m) -> true))"},
+		{"send(a) || send(b) && recv(c)", "(send(a) || (send(b) && recv(c)))"},
+		{"G (lock(m) -> X held(m))", "G (lock(m) -> X held(m))"},
+		{"blocked(main.worker) R false", "(blocked(main.worker) R false)"},
+		{"send(a) U recv(b) U close(c)", "(send(a) U (recv(b) U close(c)))"},
+		{"!!terminated", "!!terminated"},
+	}
+	for _, test := range tests {
+		f, err := ltl.Parse(test.input)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%q: expected error but parsed %s", test.input, f)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.input, err)
+			continue
+		}
+		if got := f.String(); got != test.want {
+			t.Errorf("%q: expected %s but got %s", test.input, test.want, got)
+		}
+		// String representations parse to the same formula.
+		g, err := ltl.Parse(f.String())
+		if err != nil || g.String() != f.String() {
+			t.Errorf("%q: %s does not round-trip: %v", test.input, f, err)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		input, msg string
+	}{
+		{"", "ltl: 0: unexpected end of formula"},
+		{"F (send(a)", "expected )"},
+		{"send a", "expected ( after send"},
+		{"send(a) recv(b)", `unexpected "recv"`},
+		{"send(a) & recv(b)", `unexpected "&"`},
+	}
+	for _, test := range tests {
+		_, err := ltl.Parse(test.input)
+		if _, ok := err.(*ltl.ParseError); !ok {
+			t.Errorf("%q: expected ParseError but got %v", test.input, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("%q: expected error %q but got %q", test.input, test.msg, err)
+		}
+	}
+}
+
+// word is a lasso-shaped execution, each position being the set of
+// propositions which hold.
+type word struct {
+	stem, cycle []string
+}
+
+func (w word) at(i int) map[string]bool {
+	pos := append(append([]string{}, w.stem...), w.cycle...)[i]
+	m := make(map[string]bool)
+	for _, p := range strings.Fields(pos) {
+		m[p] = true
+	}
+	return m
+}
+
+// accepts returns true if automaton a accepts word w.
+func accepts(a *ltl.Automaton, w word) bool {
+	n := len(w.stem) + len(w.cycle)
+	k := len(a.Accepting)
+	type node struct{ q, i, c int }
+	holds := func(q, i int) bool {
+		pos := w.at(i)
+		return a.States[q].Holds(func(atom *ltl.Atom) bool { return pos[atom.String()] })
+	}
+	inSet := func(q, c int) bool {
+		for _, s := range a.Accepting[c] {
+			if s == q {
+				return true
+			}
+		}
+		return false
+	}
+	succs := func(x node) []node {
+		i := x.i + 1
+		if i == n {
+			i = len(w.stem)
+		}
+		c := x.c
+		if inSet(x.q, c) {
+			c = (c + 1) % k
+		}
+		var ns []node
+		for _, q := range a.States[x.q].Succs {
+			if holds(q, i) {
+				ns = append(ns, node{q, i, c})
+			}
+		}
+		return ns
+	}
+	reach := func(from []node) map[node]bool {
+		seen := make(map[node]bool)
+		for len(from) > 0 {
+			x := from[0]
+			from = from[1:]
+			if seen[x] {
+				continue
+			}
+			seen[x] = true
+			from = append(from, succs(x)...)
+		}
+		return seen
+	}
+	var init []node
+	for _, s := range a.States {
+		if s.Initial && holds(s.ID, 0) {
+			init = append(init, node{s.ID, 0, 0})
+		}
+	}
+	for x := range reach(init) {
+		if x.c == 0 && inSet(x.q, 0) && reach(succs(x))[x] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAutomaton(t *testing.T) {
+	tests := []struct {
+		formula string
+		w       word
+		want    bool
+	}{
+		{"F close(done)", word{[]string{"", "", "close(done)"}, []string{""}}, true},
+		{"F close(done)", word{[]string{"send(a)"}, []string{""}}, false},
+		{"G F send(a)", word{nil, []string{"send(a)", ""}}, true},
+		{"G F send(a)", word{[]string{"send(a)"}, []string{""}}, false},
+		{"F G terminated", word{[]string{""}, []string{"terminated"}}, true},
+		{"F G terminated", word{nil, []string{"terminated", ""}}, false},
+		{"G !(held(mu) && send(out))", word{[]string{"held(mu)", "send(out)"}, []string{""}}, true},
+		{"G !(held(mu) && send(out))", word{[]string{"held(mu)", "held(mu) send(out)"}, []string{""}}, false},
+		{"send(a) U recv(b)", word{[]string{"send(a)", "send(a)", "recv(b)"}, []string{""}}, true},
+		{"send(a) U recv(b)", word{[]string{"send(a)", ""}, []string{"recv(b)"}}, false},
+		{"send(a) U recv(b)", word{nil, []string{"send(a)"}}, false},
+		{"recv(b) R send(a)", word{nil, []string{"send(a)"}}, true},
+		{"X lock(m)", word{[]string{"", "lock(m)"}, []string{""}}, true},
+		{"X lock(m)", word{[]string{"lock(m)"}, []string{""}}, false},
+		{"G (lock(m) -> F unlock(m))", word{nil, []string{"lock(m)", "", "unlock(m)"}}, true},
+		{"G (lock(m) -> F unlock(m))", word{[]string{"lock(m)"}, []string{""}}, false},
+		{"true", word{nil, []string{""}}, true},
+		{"false", word{nil, []string{""}}, false},
+	}
+	for _, test := range tests {
+		f := ltl.MustParse(test.formula)
+		if got := accepts(ltl.NewAutomaton(f), test.w); got != test.want {
+			t.Errorf("%s on %v: expected %t but got %t", f, test.w, test.want, got)
+		}
+		// The automaton of the negation accepts exactly the other words.
+		if got := accepts(ltl.NewAutomaton(&ltl.Not{F: f}), test.w); got == test.want {
+			t.Errorf("!%s on %v: expected %t but got %t", f, test.w, !test.want, got)
+		}
+	}
+}
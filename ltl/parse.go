@@ -0,0 +1,265 @@
+package ltl
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// ParseError is an error in the syntax of a formula.
+type ParseError struct {
+	Pos int // Byte offset in the input.
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("ltl: %d: %s", e.Pos, e.Msg)
+}
+
+// Parse parses an LTL formula.
+//
+// Returns a *ParseError if s is not a valid formula.
+func Parse(s string) (Formula, error) {
+	p := &parser{toks: lex(s), input: s}
+	f, err := p.implies()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	return f, nil
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(s string) Formula {
+	f, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokOp // ! && || -> ( )
+	tokBad
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func isIdent(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '#' || r == '/'
+}
+
+func lex(s string) []token {
+	var toks []token
+	rs := []rune(s)
+	pos := func(i int) int { return len(string(rs[:i])) }
+	for i := 0; i < len(rs); {
+		switch r := rs[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case isIdent(r):
+			j := i
+			for j < len(rs) && isIdent(rs[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(rs[i:j]), pos: pos(i)})
+			i = j
+		case r == '!' || r == '(' || r == ')':
+			toks = append(toks, token{kind: tokOp, text: string(r), pos: pos(i)})
+			i++
+		case i+1 < len(rs) && (string(rs[i:i+2]) == "&&" || string(rs[i:i+2]) == "||" || string(rs[i:i+2]) == "->"):
+			toks = append(toks, token{kind: tokOp, text: string(rs[i : i+2]), pos: pos(i)})
+			i += 2
+		default:
+			toks = append(toks, token{kind: tokBad, text: string(r), pos: pos(i)})
+			i++
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(s)})
+}
+
+type parser struct {
+	toks  []token
+	input string
+}
+
+func (p *parser) peek() token { return p.toks[0] }
+
+func (p *parser) next() token {
+	t := p.toks[0]
+	if t.kind != tokEOF {
+		p.toks = p.toks[1:]
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &ParseError{Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) is(kind tokKind, text string) bool {
+	t := p.peek()
+	return t.kind == kind && t.text == text
+}
+
+// implies := or ['->' implies]
+func (p *parser) implies() (Formula, error) {
+	l, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.is(tokOp, "->") {
+		p.next()
+		r, err := p.implies()
+		if err != nil {
+			return nil, err
+		}
+		return &Implies{L: l, R: r}, nil
+	}
+	return l, nil
+}
+
+// or := and {'||' and}
+func (p *parser) or() (Formula, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokOp, "||") {
+		p.next()
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = &Or{L: l, R: r}
+	}
+	return l, nil
+}
+
+// and := until {'&&' until}
+func (p *parser) and() (Formula, error) {
+	l, err := p.until()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokOp, "&&") {
+		p.next()
+		r, err := p.until()
+		if err != nil {
+			return nil, err
+		}
+		l = &And{L: l, R: r}
+	}
+	return l, nil
+}
+
+// until := unary [('U' | 'R') until]
+func (p *parser) until() (Formula, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.is(tokIdent, "U"):
+		p.next()
+		r, err := p.until()
+		if err != nil {
+			return nil, err
+		}
+		return &Until{L: l, R: r}, nil
+	case p.is(tokIdent, "R"):
+		p.next()
+		r, err := p.until()
+		if err != nil {
+			return nil, err
+		}
+		return &Release{L: l, R: r}, nil
+	}
+	return l, nil
+}
+
+// unary := ('!' | 'X' | 'F' | 'G') unary | primary
+func (p *parser) unary() (Formula, error) {
+	t := p.peek()
+	if (t.kind == tokOp && t.text == "!") || (t.kind == tokIdent && (t.text == "X" || t.text == "F" || t.text == "G")) {
+		p.next()
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		switch t.text {
+		case "!":
+			return &Not{F: f}, nil
+		case "X":
+			return &Next{F: f}, nil
+		case "F":
+			return &Eventually{F: f}, nil
+		}
+		return &Always{F: f}, nil
+	}
+	return p.primary()
+}
+
+// primary := '(' implies ')' | 'true' | 'false' | atom
+// atom := 'terminated' | name '(' ident ')'
+func (p *parser) primary() (Formula, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokOp && t.text == "(":
+		f, err := p.implies()
+		if err != nil {
+			return nil, err
+		}
+		if !p.is(tokOp, ")") {
+			return nil, p.errorf(p.peek(), "expected )")
+		}
+		p.next()
+		return f, nil
+	case t.kind != tokIdent:
+		if t.kind == tokEOF {
+			return nil, p.errorf(t, "unexpected end of formula")
+		}
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	case t.text == "true":
+		return True{}, nil
+	case t.text == "false":
+		return False{}, nil
+	case t.text == Terminated.String():
+		return &Atom{Kind: Terminated}, nil
+	}
+	kind, ok := atomKind(t.text)
+	if !ok {
+		return nil, p.errorf(t, "unknown proposition %q", t.text)
+	}
+	if !p.is(tokOp, "(") {
+		return nil, p.errorf(p.peek(), "expected ( after %s", t.text)
+	}
+	p.next()
+	arg := p.next()
+	if arg.kind != tokIdent {
+		return nil, p.errorf(arg, "expected name in %s", t.text)
+	}
+	if !p.is(tokOp, ")") {
+		return nil, p.errorf(p.peek(), "expected )")
+	}
+	p.next()
+	return &Atom{Kind: kind, Arg: arg.text}, nil
+}
+
+func atomKind(name string) (AtomKind, bool) {
+	for k, n := range atomNames {
+		if n == name && AtomKind(k) != Terminated {
+			return AtomKind(k), true
+		}
+	}
+	return 0, false
+}
@@ -540,3 +540,17 @@ type SyncRWMutexRUnlock struct {
 func (m *SyncRWMutexRUnlock) String() string {
 	return fmt.Sprintf("runlock %s", nameFilter.Replace(m.Name))
 }
+
+// ExtStatement is a user-defined Statement.
+//
+// ExtStatement declares how program transformations and analyses should
+// treat the Statement: an ExtStatement with IsTau returning true is treated
+// as a τ-action, otherwise it is treated as a non-τ action. Nested blocks of
+// statements (e.g. branches) are returned by Blocks. Transformations do not
+// modify the blocks, they rewrite the Statement with WithBlocks instead.
+type ExtStatement interface {
+	Statement
+	IsTau() bool                                  // Statement is reducible to τ.
+	Blocks() [][]Statement                        // Nested blocks of statements, or nil.
+	WithBlocks(blocks [][]Statement) ExtStatement // Copy with blocks in place of Blocks.
+}
@@ -0,0 +1,19 @@
+package migoutil
+
+import (
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/passes/normalise"
+)
+
+// NormaliseProgram takes the input Program prog and rewrites the
+// control-flow statements (if and select) in each function to a
+// canonical form.
+//
+// It flattens if statements with identical branches, hoists common
+// prefixes and suffixes out of if branches, drops redundant τ and merges
+// duplicated select cases. A select with a single case is replaced by its
+// prefix and continuation.
+func NormaliseProgram(prog *migo.Program) *migo.Program {
+	normalise.Rewrite(prog)
+	return prog
+}
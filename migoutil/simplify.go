@@ -10,15 +10,26 @@ import (
 // SimplifyProgram takes the input Program prog and reduce it
 // to a smaller equivalent Program.
 //
-// It removes functions that reduces to τ, and
-// removes call to functions that do not exist.
-func SimplifyProgram(prog *migo.Program) *migo.Program {
+// It removes functions that reduces to τ, functions that are not
+// reachable from "main".main, and removes call to functions that do not exist.
+//
+// Returns a *migo.ErrUnsupportedStatement error if prog contains
+// a statement kind that is unknown and not a migo.ExtStatement.
+func SimplifyProgram(prog *migo.Program) (*migo.Program, error) {
 	if mainmain, hasMM := prog.Function(`"main".main`); hasMM {
-		taufunc.Find(prog, taufunc.RemoveExcept(mainmain))
-		unused.Remove(prog, mainmain)
+		if err := taufunc.Find(prog, taufunc.RemoveExcept(mainmain)); err != nil {
+			return nil, err
+		}
+		if _, err := unused.Remove(prog, mainmain); err != nil {
+			return nil, err
+		}
 	} else {
-		taufunc.Find(prog, taufunc.Remove)
+		if err := taufunc.Find(prog, taufunc.Remove); err != nil {
+			return nil, err
+		}
+	}
+	if err := deadcall.Remove(prog); err != nil {
+		return nil, err
 	}
-	deadcall.Remove(prog)
-	return prog
+	return prog, nil
 }
@@ -489,3 +489,21 @@ def work(): tau;
 		}
 	}
 }
+
+type unknownStmt struct{}
+
+func (unknownStmt) String() string { return "unknown" }
+
+// Tests SimplifyProgram returns an error for unsupported statements.
+func TestSimplifyProgramUnsupported(t *testing.T) {
+	p := migo.NewProgram()
+	mainFunc := migo.NewFunction(`"main".main`)
+	mainFunc.AddStmts(&migo.SendStatement{Chan: "ch"}, unknownStmt{})
+	p.AddFunction(mainFunc)
+	_, err := migoutil.SimplifyProgram(p)
+	if e, ok := err.(*migo.ErrUnsupportedStatement); !ok {
+		t.Errorf("Expects *migo.ErrUnsupportedStatement but got %v", err)
+	} else if e.Func != mainFunc.Name {
+		t.Errorf("Expects error in %s but got %s", mainFunc.Name, e.Func)
+	}
+}
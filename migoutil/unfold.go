@@ -0,0 +1,20 @@
+package migoutil
+
+import (
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/passes/unfold"
+)
+
+// UnfoldProgram takes the input Program prog and unfolds recursion and
+// loops to a finite depth, so that the resulting Program is loop-free.
+//
+// Recursive functions are unfolded depth times, and for-loops are expanded
+// in place to at most loopBound iterations. Recursive calls and spawns past
+// depth, and iterations past loopBound, are replaced by the statements
+// stub, or τ if no stub is given.
+func UnfoldProgram(prog *migo.Program, depth, loopBound int, stub ...migo.Statement) (*migo.Program, error) {
+	if err := unfold.Unfold(prog, depth, loopBound, stub); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
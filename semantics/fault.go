@@ -0,0 +1,63 @@
+package semantics
+
+import "github.com/JorgeGCoelho/migo/v3"
+
+// Faults returns the reductions of State s which panic or are fatal errors
+// at runtime, and so are not Successors of s: a send (or a select case
+// sending) on a closed channel, a close of a closed channel, an unlock of a
+// mutex which is not locked, and a runlock of a mutex which is not read
+// locked.
+//
+// The Kind of the returned labels is Send, Close, Unlock or RUnlock.
+func Faults(s *State) []Label {
+	var faults []Label
+	closed := func(g *Goroutine, name string) (int, bool) {
+		obj, ok := s.Lookup(g, name)
+		return obj, ok && s.Objects[obj].Kind == Chan && s.Objects[obj].Closed
+	}
+	mutex := func(g *Goroutine, name string) (int, bool) {
+		obj, ok := s.Lookup(g, name)
+		return obj, ok && (s.Objects[obj].Kind == Mutex || s.Objects[obj].Kind == RWMutex)
+	}
+	for _, g := range s.Goroutines {
+		if g.Terminated() {
+			continue
+		}
+		l := Label{G: g.ID, Stmt: g.Next(), Case: -1, Peer: -1, PeerCase: -1, Obj: -1}
+		switch stmt := g.Next().(type) {
+		case *migo.SendStatement:
+			if obj, ok := closed(g, stmt.Chan); ok {
+				l.Kind, l.Obj, l.ObjName = Send, obj, s.Objects[obj].Name
+				faults = append(faults, l)
+			}
+		case *migo.CloseStatement:
+			if obj, ok := closed(g, stmt.Chan); ok {
+				l.Kind, l.Obj, l.ObjName = Close, obj, s.Objects[obj].Name
+				faults = append(faults, l)
+			}
+		case *migo.SyncMutexUnlock:
+			if obj, ok := mutex(g, stmt.Name); ok && !s.Objects[obj].Locked {
+				l.Kind, l.Obj, l.ObjName = Unlock, obj, s.Objects[obj].Name
+				faults = append(faults, l)
+			}
+		case *migo.SyncRWMutexRUnlock:
+			if obj, ok := mutex(g, stmt.Name); ok && s.Objects[obj].Readers == 0 {
+				l.Kind, l.Obj, l.ObjName = RUnlock, obj, s.Objects[obj].Name
+				faults = append(faults, l)
+			}
+		case *migo.SelectStatement:
+			for i, c := range stmt.Cases {
+				if len(c) == 0 {
+					continue
+				}
+				if send, ok := c[0].(*migo.SendStatement); ok {
+					if obj, ok := closed(g, send.Chan); ok {
+						l.Kind, l.Case, l.Obj, l.ObjName = Send, i, obj, s.Objects[obj].Name
+						faults = append(faults, l)
+					}
+				}
+			}
+		}
+	}
+	return faults
+}
@@ -0,0 +1,154 @@
+package semantics
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+)
+
+// stmtIDs identifies the statements of a program.
+//
+// The identifiers are assigned when the initial state is built, and the
+// map is only read afterwards, so states can compute their keys
+// concurrently.
+type stmtIDs map[migo.Statement]int
+
+// newStmtIDs assigns identifiers to the statements of Program prog.
+func newStmtIDs(prog *migo.Program) stmtIDs {
+	ids := make(stmtIDs)
+	for _, fn := range prog.Funcs {
+		ids.add(fn.Stmts)
+	}
+	return ids
+}
+
+func (ids stmtIDs) add(stmts []migo.Statement) {
+	for _, stmt := range stmts {
+		if reflect.TypeOf(stmt).Comparable() {
+			if _, ok := ids[stmt]; !ok {
+				ids[stmt] = len(ids)
+			}
+		}
+		switch stmt := stmt.(type) {
+		case *migo.IfStatement:
+			ids.add(stmt.Then)
+			ids.add(stmt.Else)
+		case *migo.IfForStatement:
+			ids.add(stmt.Then)
+			ids.add(stmt.Else)
+		case *migo.SelectStatement:
+			for _, c := range stmt.Cases {
+				ids.add(c)
+			}
+		case migo.ExtStatement:
+			for _, b := range stmt.Blocks() {
+				ids.add(b)
+			}
+		}
+	}
+}
+
+// write writes the identifier of stmt to sb, or its String if it has
+// none, e.g. if it is not comparable.
+func (ids stmtIDs) write(sb *strings.Builder, stmt migo.Statement) {
+	if reflect.TypeOf(stmt).Comparable() {
+		if id, ok := ids[stmt]; ok {
+			writeInt(sb, int64(id))
+			return
+		}
+	}
+	sb.WriteString(stmt.String())
+}
+
+// Key returns a string which uniquely identifies the configuration of
+// State s, i.e. two states have the same Key if and only if they have
+// the same goroutines (continuations, call stacks and bindings)
+// and runtime objects.
+func (s *State) Key() string {
+	var sb strings.Builder
+	for _, g := range s.Goroutines {
+		s.writeGoroutine(&sb, g)
+		sb.WriteString("|")
+	}
+	for _, o := range s.Objects {
+		writeObject(&sb, o)
+	}
+	return sb.String()
+}
+
+// SymmetricKey returns a string which identifies the configuration of
+// State s up to permutation of goroutines, i.e. two states have the same
+// SymmetricKey if they have the same Key after reordering the goroutines
+// other than the entry goroutine.
+//
+// Goroutines running the same function with the same bindings are
+// interchangeable, so states with the same SymmetricKey have the same
+// behaviour, up to goroutine IDs.
+func (s *State) SymmetricKey() string {
+	gs := make([]string, len(s.Goroutines))
+	for i, g := range s.Goroutines {
+		var sb strings.Builder
+		s.writeGoroutine(&sb, g)
+		gs[i] = sb.String()
+	}
+	if len(gs) > 1 {
+		sort.Strings(gs[1:])
+	}
+	var sb strings.Builder
+	for _, g := range gs {
+		sb.WriteString(g)
+		sb.WriteString("|")
+	}
+	for _, o := range s.Objects {
+		writeObject(&sb, o)
+	}
+	return sb.String()
+}
+
+func (s *State) writeGoroutine(sb *strings.Builder, g *Goroutine) {
+	for _, f := range g.Frames {
+		sb.WriteString(f.Func.Name)
+		sb.WriteString("(")
+		for i, stmt := range f.Stmts {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			s.ids.write(sb, stmt)
+		}
+		sb.WriteString(")")
+		names := make([]string, 0, len(f.Env))
+		for name := range f.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sb.WriteString(name)
+			sb.WriteString("=")
+			writeInt(sb, int64(f.Env[name]))
+			sb.WriteString(";")
+		}
+	}
+}
+
+func writeObject(sb *strings.Builder, o *Object) {
+	writeInt(sb, int64(o.Kind))
+	sb.WriteString(":")
+	writeInt(sb, o.Buffered)
+	sb.WriteString("/")
+	writeInt(sb, o.Size)
+	sb.WriteString(":")
+	sb.WriteString(strconv.FormatBool(o.Closed))
+	sb.WriteString(":")
+	sb.WriteString(strconv.FormatBool(o.Locked))
+	sb.WriteString(":")
+	writeInt(sb, int64(o.Readers))
+	sb.WriteString(";")
+}
+
+func writeInt(sb *strings.Builder, i int64) {
+	var buf [20]byte
+	sb.Write(strconv.AppendInt(buf[:0], i, 10))
+}
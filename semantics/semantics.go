@@ -0,0 +1,384 @@
+// Package semantics implements the reduction semantics of MiGo programs.
+//
+// A MiGo program is executed as a set of goroutines, each running a
+// continuation of statements, communicating over channels and sharing
+// memory and mutexes. The semantics is given as a labelled transition
+// system (LTS): a configuration of the program is a State, and the
+// reductions of a State are its Successors.
+//
+// The reduction rules are:
+//
+//	tau                    τ-action, always enabled
+//	if/ifFor               choice of one of the branches
+//	call f(x)              unfold the body of f with parameters bound
+//	spawn f(x)             start a new goroutine running f
+//	let x = newchan T, n   create a channel of capacity n
+//	send x / recv x        synchronise (n = 0) or use the buffer (n > 0)
+//	close x                close the channel, pending and future
+//	                       receives are enabled
+//	select                 choice of one of the enabled cases
+//	letmem x, read, write  create and access a shared variable
+//	letsync x mutex, lock  create, lock and unlock a mutex
+//	letsync x rwmutex      create a RWMutex with rlock and runlock
+//
+// Names that are not bound by a parameter or a let-like statement are free
+// names, and refer to global channels, memory or mutexes, depending on how
+// they are used in the program. Free channels are unbuffered.
+//
+// # Usage
+//
+// To compute the initial state and its successors:
+//
+//	s, err := semantics.Initial(prog, "main.main")
+//	for _, t := range semantics.Successors(s) {
+//		fmt.Println(t.Label, t.Next)
+//	}
+package semantics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/usage"
+)
+
+// ObjKind is the kind of a runtime Object.
+type ObjKind int
+
+// Kinds of runtime objects.
+const (
+	Chan    ObjKind = iota // Channel.
+	Mem                    // Shared memory.
+	Mutex                  // sync.Mutex.
+	RWMutex                // sync.RWMutex.
+)
+
+func (k ObjKind) String() string {
+	switch k {
+	case Chan:
+		return "chan"
+	case Mem:
+		return "mem"
+	case Mutex:
+		return "mutex"
+	case RWMutex:
+		return "rwmutex"
+	}
+	return fmt.Sprintf("ObjKind(%d)", int(k))
+}
+
+// Object is a runtime instance of a channel, shared memory or mutex.
+//
+// Objects are immutable, reductions create new Objects.
+type Object struct {
+	Kind     ObjKind
+	Name     string         // Name the object is bound to when created.
+	Site     migo.Statement // Statement creating the object, nil for free names.
+	Size     int64          // Buffer size of a channel.
+	Buffered int64          // Number of items in the buffer of a channel.
+	Closed   bool           // Channel is closed.
+	Locked   bool           // Mutex is locked (for writing).
+	Readers  int            // Number of read locks held on a RWMutex.
+}
+
+func (o *Object) String() string {
+	switch o.Kind {
+	case Chan:
+		closed := ""
+		if o.Closed {
+			closed = ", closed"
+		}
+		return fmt.Sprintf("chan %s [%d/%d%s]", o.Name, o.Buffered, o.Size, closed)
+	case Mutex, RWMutex:
+		state := "unlocked"
+		if o.Locked {
+			state = "locked"
+		} else if o.Readers > 0 {
+			state = fmt.Sprintf("rlocked×%d", o.Readers)
+		}
+		return fmt.Sprintf("%s %s [%s]", o.Kind, o.Name, state)
+	}
+	return fmt.Sprintf("%s %s", o.Kind, o.Name)
+}
+
+// Frame is a function activation on the call stack of a Goroutine.
+type Frame struct {
+	Func  *migo.Function   // Function being executed.
+	Stmts []migo.Statement // Remaining statements (continuation).
+	Env   map[string]int   // Local names to Object index in State.
+}
+
+// Goroutine is a thread of execution in a State.
+//
+// Goroutines are immutable, reductions create new Goroutines.
+type Goroutine struct {
+	ID     int                  // Index of the goroutine in State.
+	Parent int                  // ID of the spawning goroutine, -1 for entry.
+	Spawn  *migo.SpawnStatement // Statement spawning the goroutine, nil for entry.
+	Root   *migo.Function       // Function the goroutine started with.
+	Frames []*Frame             // Call stack, innermost frame last.
+}
+
+// Terminated returns true if the goroutine has no more statements.
+func (g *Goroutine) Terminated() bool {
+	return len(g.Frames) == 0
+}
+
+// Next returns the next statement to be reduced by the goroutine,
+// or nil if the goroutine is terminated.
+func (g *Goroutine) Next() migo.Statement {
+	if g.Terminated() {
+		return nil
+	}
+	return g.top().Stmts[0]
+}
+
+// Func returns the function being executed by the goroutine,
+// or nil if the goroutine is terminated.
+func (g *Goroutine) Func() *migo.Function {
+	if g.Terminated() {
+		return nil
+	}
+	return g.top().Func
+}
+
+func (g *Goroutine) top() *Frame {
+	return g.Frames[len(g.Frames)-1]
+}
+
+func (g *Goroutine) String() string {
+	if g.Terminated() {
+		return fmt.Sprintf("g%d (%s): terminated", g.ID, g.Root.SimpleName())
+	}
+	return fmt.Sprintf("g%d (%s): %s: %s", g.ID, g.Root.SimpleName(), g.Func().SimpleName(), g.Next())
+}
+
+// State is a configuration of a MiGo program, i.e. its goroutines
+// and runtime objects.
+//
+// States are immutable, reductions create new States.
+type State struct {
+	Goroutines []*Goroutine // Goroutines by ID.
+	Objects    []*Object    // Runtime objects.
+
+	prog    *migo.Program
+	globals map[string]int // Free names to Object index.
+	ids     stmtIDs        // Statement identifiers, for Key.
+}
+
+// Program returns the Program the state belongs to.
+func (s *State) Program() *migo.Program {
+	return s.prog
+}
+
+// Lookup returns the index of the Object bound to name in the current frame
+// of Goroutine g, or false if name is not bound.
+func (s *State) Lookup(g *Goroutine, name string) (int, bool) {
+	if !g.Terminated() {
+		if obj, ok := g.top().Env[name]; ok {
+			return obj, true
+		}
+	}
+	obj, ok := s.globals[name]
+	return obj, ok
+}
+
+// Terminated returns true if all goroutines in the state are terminated.
+func (s *State) Terminated() bool {
+	for _, g := range s.Goroutines {
+		if !g.Terminated() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *State) String() string {
+	var sb strings.Builder
+	for _, g := range s.Goroutines {
+		sb.WriteString(g.String())
+		sb.WriteString("\n")
+	}
+	for i, o := range s.Objects {
+		sb.WriteString(fmt.Sprintf("#%d %s\n", i, o))
+	}
+	return sb.String()
+}
+
+// Initial returns the initial state of Program prog, with a single goroutine
+// running the function entry.
+//
+// Returns a *migo.ErrFuncNotFound error if entry is not in prog, and a
+// *migo.ErrUnsupportedStatement error if prog contains a statement kind
+// that is unknown, or a migo.ExtStatement that is not reducible to τ.
+func Initial(prog *migo.Program, entry string) (*State, error) {
+	entryFn, found := prog.Function(entry)
+	if !found {
+		return nil, &migo.ErrFuncNotFound{Name: entry}
+	}
+	s := &State{
+		prog:    prog,
+		globals: make(map[string]int),
+		ids:     newStmtIDs(prog),
+	}
+	b := &binder{uses: usage.Compute(prog), untyped: make(map[string]bool)}
+	for _, fn := range prog.Funcs {
+		bound := make(map[string]bool)
+		if fn != entryFn {
+			for _, p := range fn.Params {
+				bound[p.Callee.Name()] = true
+			}
+		}
+		if err := s.bindFree(b, fn, fn.Stmts, bound); err != nil {
+			return nil, err
+		}
+	}
+	// Free names only passed to functions which never use them default
+	// to channels.
+	for _, name := range b.order {
+		if _, ok := s.globals[name]; !ok && b.untyped[name] {
+			s.global(name, Chan)
+		}
+	}
+	s.Goroutines = []*Goroutine{newGoroutine(0, -1, nil, entryFn, map[string]int{})}
+	return s, nil
+}
+
+// binder is the data used to create the global objects of free names.
+type binder struct {
+	uses    usage.Uses      // Uses of names, to infer the kind of arguments.
+	untyped map[string]bool // Free names of unknown kind.
+	order   []string        // Untyped names in order.
+}
+
+// global creates a global object of kind for the free name, if not yet
+// created.
+func (s *State) global(name string, kind ObjKind) {
+	if obj, ok := s.globals[name]; ok {
+		if s.Objects[obj].Kind == Mutex && kind == RWMutex {
+			s.Objects[obj].Kind = RWMutex
+		}
+		return
+	}
+	s.globals[name] = len(s.Objects)
+	s.Objects = append(s.Objects, &Object{Kind: kind, Name: name})
+}
+
+// kindOf returns the kind of object used by u, or false if u does not
+// tell.
+func kindOf(u usage.Use) (ObjKind, bool) {
+	switch {
+	case u&(usage.RLock|usage.RUnlock) != 0:
+		return RWMutex, true
+	case u&(usage.Lock|usage.Unlock) != 0:
+		return Mutex, true
+	case u&(usage.Read|usage.Write) != 0:
+		return Mem, true
+	case u&(usage.Send|usage.Recv|usage.Close) != 0:
+		return Chan, true
+	}
+	return Chan, false
+}
+
+// bindFree creates global objects for the free names in stmts of fn.
+//
+// The kind of a free name passed to a call or spawn is inferred from the
+// uses of the name, including in the callee.
+func (s *State) bindFree(b *binder, fn *migo.Function, stmts []migo.Statement, bound map[string]bool) error {
+	free := func(name string, kind ObjKind) {
+		if !bound[name] {
+			s.global(name, kind)
+		}
+	}
+	arg := func(name string) {
+		if bound[name] {
+			return
+		}
+		if kind, ok := kindOf(b.uses[fn][name]); ok {
+			s.global(name, kind)
+		} else if !b.untyped[name] {
+			b.untyped[name] = true
+			b.order = append(b.order, name)
+		}
+	}
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *migo.NewChanStatement:
+			bound[stmt.Name.Name()] = true
+		case *migo.NewMem:
+			bound[stmt.Name.Name()] = true
+		case *migo.NewSyncMutex:
+			bound[stmt.Name.Name()] = true
+		case *migo.NewSyncRWMutex:
+			bound[stmt.Name.Name()] = true
+		case *migo.SendStatement:
+			free(stmt.Chan, Chan)
+		case *migo.RecvStatement:
+			free(stmt.Chan, Chan)
+		case *migo.CloseStatement:
+			free(stmt.Chan, Chan)
+		case *migo.MemRead:
+			free(stmt.Name, Mem)
+		case *migo.MemWrite:
+			free(stmt.Name, Mem)
+		case *migo.SyncMutexLock:
+			free(stmt.Name, Mutex)
+		case *migo.SyncMutexUnlock:
+			free(stmt.Name, Mutex)
+		case *migo.SyncRWMutexRLock:
+			free(stmt.Name, RWMutex)
+		case *migo.SyncRWMutexRUnlock:
+			free(stmt.Name, RWMutex)
+		case *migo.CallStatement:
+			for _, p := range stmt.Params {
+				arg(p.Caller.Name())
+			}
+		case *migo.SpawnStatement:
+			for _, p := range stmt.Params {
+				arg(p.Caller.Name())
+			}
+		case *migo.TauStatement:
+		case *migo.IfStatement:
+			if err := s.bindFreeBlocks(b, fn, bound, stmt.Then, stmt.Else); err != nil {
+				return err
+			}
+		case *migo.IfForStatement:
+			if err := s.bindFreeBlocks(b, fn, bound, stmt.Then, stmt.Else); err != nil {
+				return err
+			}
+		case *migo.SelectStatement:
+			if err := s.bindFreeBlocks(b, fn, bound, stmt.Cases...); err != nil {
+				return err
+			}
+		case migo.ExtStatement:
+			if !stmt.IsTau() {
+				return &migo.ErrUnsupportedStatement{Func: fn.Name, Stmt: stmt}
+			}
+			if err := s.bindFreeBlocks(b, fn, bound, stmt.Blocks()...); err != nil {
+				return err
+			}
+		default:
+			return &migo.ErrUnsupportedStatement{Func: fn.Name, Stmt: stmt}
+		}
+	}
+	return nil
+}
+
+func (s *State) bindFreeBlocks(b *binder, fn *migo.Function, bound map[string]bool, blocks ...[]migo.Statement) error {
+	for _, stmts := range blocks {
+		if err := s.bindFree(b, fn, stmts, bound); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newGoroutine returns a goroutine running fn with parameters bound by env.
+func newGoroutine(id, parent int, spawn *migo.SpawnStatement, fn *migo.Function, env map[string]int) *Goroutine {
+	g := &Goroutine{ID: id, Parent: parent, Spawn: spawn, Root: fn}
+	if len(fn.Stmts) > 0 {
+		g.Frames = []*Frame{{Func: fn, Stmts: fn.Stmts, Env: env}}
+	}
+	return g
+}
@@ -0,0 +1,407 @@
+package semantics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// run reduces State s by always taking the first transition, and returns
+// the labels of the reductions and the final state.
+func run(s *semantics.State, limit int) ([]semantics.Label, *semantics.State) {
+	var labels []semantics.Label
+	for i := 0; i < limit; i++ {
+		ts := semantics.Successors(s)
+		if len(ts) == 0 {
+			break
+		}
+		labels = append(labels, ts[0].Label)
+		s = ts[0].Next
+	}
+	return labels, s
+}
+
+// reachable returns the number of reachable states from s, and the states
+// with no successors.
+func reachable(s *semantics.State) (int, []*semantics.State) {
+	visited := map[string]bool{s.Key(): true}
+	queue := []*semantics.State{s}
+	var stuck []*semantics.State
+	for len(queue) > 0 {
+		s, queue = queue[0], queue[1:]
+		ts := semantics.Successors(s)
+		if len(ts) == 0 {
+			stuck = append(stuck, s)
+		}
+		for _, t := range ts {
+			if !visited[t.Next.Key()] {
+				visited[t.Next.Key()] = true
+				queue = append(queue, t.Next)
+			}
+		}
+	}
+	return len(visited), stuck
+}
+
+func TestSyncComm(t *testing.T) {
+	src := `
+def main(): let ch = newchan T, 0; spawn snd(ch); recv ch;
+def snd(c): send c;`
+	prog, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels, final := run(s, 10)
+	if !final.Terminated() {
+		t.Errorf("expected terminated state but got:\n%s", final)
+	}
+	kinds := []semantics.Kind{semantics.NewChan, semantics.Spawn, semantics.Comm}
+	if want, got := len(kinds), len(labels); want != got {
+		t.Fatalf("expected %d reductions but got %d: %v", want, got, labels)
+	}
+	for i, kind := range kinds {
+		if labels[i].Kind != kind {
+			t.Errorf("reduction %d: expected %s but got %s", i, kind, labels[i])
+		}
+	}
+	if want, got := 1, labels[2].G; want != got {
+		t.Errorf("expected sender g%d but got g%d", want, got)
+	}
+	if want, got := 0, labels[2].Peer; want != got {
+		t.Errorf("expected receiver g%d but got g%d", want, got)
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	src := `def main(): let ch = newchan T, 0; send ch;`
+	prog, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, final := run(s, 10)
+	if final.Terminated() {
+		t.Errorf("expected send on unbuffered channel to block")
+	}
+	if want, got := 0, len(semantics.Successors(final)); want != got {
+		t.Errorf("expected %d successors but got %d", want, got)
+	}
+}
+
+func TestBuffered(t *testing.T) {
+	src := `def main(): let ch = newchan T, 2; send ch; send ch; recv ch; send ch; recv ch; recv ch;`
+	prog, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels, final := run(s, 10)
+	if !final.Terminated() {
+		t.Errorf("expected terminated state but got:\n%s", final)
+	}
+	if want, got := 7, len(labels); want != got {
+		t.Errorf("expected %d reductions but got %d: %v", want, got, labels)
+	}
+	src = `def main(): let ch = newchan T, 1; send ch; send ch;`
+	prog, err = parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, final = run(s, 10)
+	if final.Terminated() {
+		t.Errorf("expected send on full channel to block")
+	}
+}
+
+func TestClose(t *testing.T) {
+	src := `def main(): let ch = newchan T, 0; close ch; recv ch; recv ch;`
+	prog, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, final := run(s, 10)
+	if !final.Terminated() {
+		t.Errorf("expected receive on closed channel to succeed but got:\n%s", final)
+	}
+	src = `def main(): let ch = newchan T, 0; close ch; send ch;`
+	prog, err = parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, final = run(s, 10)
+	if final.Terminated() {
+		t.Errorf("expected send on closed channel to be stuck")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	src := `
+def main():
+	let a = newchan A, 0;
+	let b = newchan B, 0;
+	spawn snd(b);
+	select
+	  case recv a;
+	  case recv b; close a;
+	endselect;
+def snd(x): send x;`
+	prog, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, stuck := reachable(s)
+	if want, got := 1, len(stuck); want != got {
+		t.Fatalf("expected %d final state but got %d", want, got)
+	}
+	if !stuck[0].Terminated() {
+		t.Errorf("expected final state to be terminated but got:\n%s", stuck[0])
+	}
+	if n < 5 {
+		t.Errorf("expected at least 5 states but got %d", n)
+	}
+	// A select with a τ case is always enabled.
+	src = `def main(): let a = newchan A, 0; select case recv a; case tau; endselect;`
+	prog, err = parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stuck = reachable(s)
+	if want, got := 1, len(stuck); want != got || !stuck[0].Terminated() {
+		t.Errorf("expected select with tau case to terminate")
+	}
+}
+
+func TestIfCall(t *testing.T) {
+	src := `
+def main(): let ch = newchan T, 0; spawn r(ch); call f(ch);
+def f(x): if send x; else call f(x); endif;
+def r(y): recv y;`
+	prog, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, stuck := reachable(s)
+	if want, got := 1, len(stuck); want != got {
+		t.Fatalf("expected %d final state but got %d", want, got)
+	}
+	if !stuck[0].Terminated() {
+		t.Errorf("expected final state to be terminated but got:\n%s", stuck[0])
+	}
+	// Tail recursion gives a finite state space.
+	if n > 20 {
+		t.Errorf("expected finite state space but got %d states", n)
+	}
+}
+
+func TestMutex(t *testing.T) {
+	src := `
+def main(): letsync mu mutex; spawn f(mu); lock mu; unlock mu;
+def f(m): lock m; unlock m;`
+	prog, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stuck := reachable(s)
+	for _, st := range stuck {
+		if !st.Terminated() {
+			t.Errorf("expected all final states to be terminated but got:\n%s", st)
+		}
+	}
+	src = `def main(): letsync mu mutex; lock mu; lock mu;`
+	prog, err = parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, final := run(s, 10)
+	if final.Terminated() {
+		t.Errorf("expected double lock to be stuck")
+	}
+	src = `def main(): letsync mu rwmutex; rlock mu; rlock mu; runlock mu; runlock mu; lock mu;`
+	prog, err = parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, final = run(s, 10)
+	if !final.Terminated() {
+		t.Errorf("expected rwmutex to be unlocked but got:\n%s", final)
+	}
+}
+
+func TestFreeNames(t *testing.T) {
+	src := `
+def main(): spawn f(); recv x; write m;
+def f(): send x; read m;`
+	prog, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stuck := reachable(s)
+	for _, st := range stuck {
+		if !st.Terminated() {
+			t.Errorf("expected free names to be shared globally but got:\n%s", st)
+		}
+	}
+}
+
+func TestEntryNotFound(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(`def f(): tau;`))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, err := semantics.Initial(prog, "main"); err == nil {
+		t.Errorf("expected error for missing entry function")
+	}
+}
+
+func TestFaults(t *testing.T) {
+	src := `
+def main(): let ch = newchan T, 0; close ch; spawn f(ch); close ch;
+def f(c): select case recv c; case send c; endselect; send c;`
+	prog, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	faults := 0
+	visited := map[string]bool{s.Key(): true}
+	queue := []*semantics.State{s}
+	for len(queue) > 0 {
+		s, queue = queue[0], queue[1:]
+		faults += len(semantics.Faults(s))
+		for _, tr := range semantics.Successors(s) {
+			if !visited[tr.Next.Key()] {
+				visited[tr.Next.Key()] = true
+				queue = append(queue, tr.Next)
+			}
+		}
+	}
+	if faults == 0 {
+		t.Errorf("expected faults to be found")
+	}
+	src = `def main(): let ch = newchan T, 0; close ch; close ch;`
+	prog, err = parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, final := run(s, 10)
+	if want, got := 1, len(semantics.Faults(final)); want != got {
+		t.Fatalf("expected %d fault but got %d", want, got)
+	}
+	if want, got := semantics.Close, semantics.Faults(final)[0].Kind; want != got {
+		t.Errorf("expected fault %s but got %s", want, got)
+	}
+}
+
+func TestUnlockFaults(t *testing.T) {
+	for _, tc := range []struct {
+		prog string
+		want semantics.Kind
+	}{
+		{`def main(): letsync mu mutex; lock mu; unlock mu; unlock mu;`, semantics.Unlock},
+		{`def main(): letsync mu rwmutex; rlock mu; runlock mu; runlock mu;`, semantics.RUnlock},
+	} {
+		prog, err := parser.Parse(strings.NewReader(tc.prog))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := semantics.Initial(prog, "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		labels, final := run(s, 10)
+		if want, got := 3, len(labels); want != got {
+			t.Errorf("%s: expected %d reductions before the fault but got %d", tc.prog, want, got)
+		}
+		faults := semantics.Faults(final)
+		if len(faults) != 1 || faults[0].Kind != tc.want || faults[0].ObjName != "mu" {
+			t.Errorf("%s: expected %s fault on mu but got %v", tc.prog, tc.want, faults)
+		}
+	}
+}
+
+func TestFreeArgumentKind(t *testing.T) {
+	src := `
+def main(): spawn w(mu, m, rw, ch, x);
+def w(l, v, r, c, y): call lk(l); write v; rlock r; send c;
+def lk(a): lock a; unlock a;`
+	prog, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := semantics.Initial(prog, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]semantics.ObjKind)
+	for _, o := range s.Objects {
+		kinds[o.Name] = o.Kind
+	}
+	for name, want := range map[string]semantics.ObjKind{
+		"mu": semantics.Mutex, "m": semantics.Mem, "rw": semantics.RWMutex,
+		"ch": semantics.Chan, "x": semantics.Chan,
+	} {
+		if got, ok := kinds[name]; !ok || got != want {
+			t.Errorf("expected free name %s to be %s but got %s", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package semantics
+
+import (
+	"fmt"
+
+	"github.com/JorgeGCoelho/migo/v3"
+)
+
+// Kind is the kind of a reduction.
+type Kind int
+
+// Kinds of reductions.
+const (
+	Tau      Kind = iota // τ-action, including a τ case of select.
+	Choice               // Choice of a branch of if.
+	Call                 // Function call.
+	Spawn                // Goroutine spawn.
+	NewChan              // Channel creation.
+	Send                 // Send to a channel buffer.
+	Recv                 // Receive from a channel buffer or a closed channel.
+	Comm                 // Synchronous communication between two goroutines.
+	Close                // Channel close.
+	NewMem               // Shared memory creation.
+	Read                 // Shared memory read.
+	Write                // Shared memory write.
+	NewMutex             // Mutex or RWMutex creation.
+	Lock                 // Mutex lock.
+	Unlock               // Mutex unlock.
+	RLock                // RWMutex read lock.
+	RUnlock              // RWMutex read unlock.
+)
+
+var kindNames = [...]string{
+	Tau:      "tau",
+	Choice:   "choice",
+	Call:     "call",
+	Spawn:    "spawn",
+	NewChan:  "newchan",
+	Send:     "send",
+	Recv:     "recv",
+	Comm:     "comm",
+	Close:    "close",
+	NewMem:   "letmem",
+	Read:     "read",
+	Write:    "write",
+	NewMutex: "letsync",
+	Lock:     "lock",
+	Unlock:   "unlock",
+	RLock:    "rlock",
+	RUnlock:  "runlock",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Label describes a reduction.
+type Label struct {
+	Kind     Kind
+	G        int            // Goroutine reducing, the sender for Comm.
+	Stmt     migo.Statement // Statement reduced by G.
+	Case     int            // Branch of if or case of select chosen by G, -1 otherwise.
+	Peer     int            // Receiving goroutine for Comm, -1 otherwise.
+	PeerStmt migo.Statement // Statement reduced by Peer.
+	PeerCase int            // Case of select chosen by Peer, -1 otherwise.
+	Obj      int            // Object index, -1 if none.
+	ObjName  string         // Name of the object, if any.
+}
+
+// IsTau returns true if the reduction is internal to a goroutine,
+// i.e. it does not interact with a channel, memory or mutex.
+func (l Label) IsTau() bool {
+	switch l.Kind {
+	case Tau, Choice, Call, Spawn, NewChan, NewMem, NewMutex:
+		return true
+	}
+	return false
+}
+
+// Action returns a description of the reduction without goroutine
+// identifiers, e.g. "send ch", for comparing reductions across states.
+func (l Label) Action() string {
+	if l.IsTau() {
+		return Tau.String()
+	}
+	return fmt.Sprintf("%s %s", l.Kind, l.ObjName)
+}
+
+func (l Label) String() string {
+	switch {
+	case l.Kind == Comm:
+		return fmt.Sprintf("g%d→g%d: comm %s", l.G, l.Peer, l.ObjName)
+	case l.Obj >= 0:
+		return fmt.Sprintf("g%d: %s %s", l.G, l.Kind, l.ObjName)
+	case l.Case >= 0:
+		return fmt.Sprintf("g%d: %s #%d", l.G, l.Kind, l.Case)
+	}
+	return fmt.Sprintf("g%d: %s", l.G, l.Kind)
+}
+
+// Transition is a reduction from a State to Next.
+type Transition struct {
+	Label Label
+	Next  *State
+}
+
+// offer is a pending send or receive of a goroutine.
+type offer struct {
+	g    *Goroutine
+	stmt migo.Statement
+	obj  int
+	cse  int              // Case of select, -1 if not in a select.
+	cont []migo.Statement // Continuation after the prefix.
+}
+
+// Successors returns all reductions of State s.
+//
+// Reductions are ordered by goroutine, and are the same
+// for equal states.
+func Successors(s *State) []Transition {
+	var ts []Transition
+	var sends, recvs []offer
+	for _, g := range s.Goroutines {
+		if g.Terminated() {
+			continue
+		}
+		switch stmt := g.Next().(type) {
+		case *migo.SendStatement:
+			if obj, ok := s.Lookup(g, stmt.Chan); ok {
+				sends = append(sends, offer{g: g, stmt: stmt, obj: obj, cse: -1, cont: g.top().Stmts[1:]})
+			}
+		case *migo.RecvStatement:
+			if obj, ok := s.Lookup(g, stmt.Chan); ok {
+				recvs = append(recvs, offer{g: g, stmt: stmt, obj: obj, cse: -1, cont: g.top().Stmts[1:]})
+			}
+		case *migo.SelectStatement:
+			for i, c := range stmt.Cases {
+				if len(c) == 0 {
+					c = []migo.Statement{&migo.TauStatement{}}
+				}
+				cont := concat(c[1:], g.top().Stmts[1:])
+				switch prefix := c[0].(type) {
+				case *migo.SendStatement:
+					if obj, ok := s.Lookup(g, prefix.Chan); ok {
+						sends = append(sends, offer{g: g, stmt: stmt, obj: obj, cse: i, cont: cont})
+					}
+				case *migo.RecvStatement:
+					if obj, ok := s.Lookup(g, prefix.Chan); ok {
+						recvs = append(recvs, offer{g: g, stmt: stmt, obj: obj, cse: i, cont: cont})
+					}
+				default:
+					ts = append(ts, Transition{
+						Label: Label{Kind: Tau, G: g.ID, Stmt: stmt, Case: i, Peer: -1, PeerCase: -1, Obj: -1},
+						Next:  s.update(g.ID, s.continueWith(g, cont)),
+					})
+				}
+			}
+		default:
+			ts = append(ts, s.step(g)...)
+		}
+	}
+	for _, snd := range sends {
+		ch := s.Objects[snd.obj]
+		if ch.Kind != Chan || ch.Closed { // send on closed channel is a fault
+			continue
+		}
+		if ch.Size > 0 {
+			if ch.Buffered < ch.Size {
+				next := s.update(snd.g.ID, s.continueWith(snd.g, snd.cont))
+				next.setObject(snd.obj, func(o *Object) { o.Buffered++ })
+				ts = append(ts, Transition{Label: snd.label(Send, ch), Next: next})
+			}
+			continue
+		}
+		for _, rcv := range recvs {
+			if rcv.obj != snd.obj || rcv.g == snd.g {
+				continue
+			}
+			next := s.update(snd.g.ID, s.continueWith(snd.g, snd.cont))
+			next = next.update(rcv.g.ID, s.continueWith(rcv.g, rcv.cont))
+			l := snd.label(Comm, ch)
+			l.Peer, l.PeerStmt, l.PeerCase = rcv.g.ID, rcv.stmt, rcv.cse
+			ts = append(ts, Transition{Label: l, Next: next})
+		}
+	}
+	for _, rcv := range recvs {
+		ch := s.Objects[rcv.obj]
+		if ch.Kind != Chan {
+			continue
+		}
+		if ch.Buffered > 0 {
+			next := s.update(rcv.g.ID, s.continueWith(rcv.g, rcv.cont))
+			next.setObject(rcv.obj, func(o *Object) { o.Buffered-- })
+			ts = append(ts, Transition{Label: rcv.label(Recv, ch), Next: next})
+		} else if ch.Closed {
+			next := s.update(rcv.g.ID, s.continueWith(rcv.g, rcv.cont))
+			ts = append(ts, Transition{Label: rcv.label(Recv, ch), Next: next})
+		}
+	}
+	return ts
+}
+
+func (o offer) label(kind Kind, ch *Object) Label {
+	return Label{Kind: kind, G: o.g.ID, Stmt: o.stmt, Case: o.cse, Peer: -1, PeerCase: -1, Obj: o.obj, ObjName: ch.Name}
+}
+
+// step returns the reductions of a goroutine g that does not
+// communicate over channels.
+func (s *State) step(g *Goroutine) []Transition {
+	stmt := g.Next()
+	rest := g.top().Stmts[1:]
+	label := Label{G: g.ID, Stmt: stmt, Case: -1, Peer: -1, PeerCase: -1, Obj: -1}
+	single := func(kind Kind, next *State) []Transition {
+		label.Kind = kind
+		return []Transition{{Label: label, Next: next}}
+	}
+	// withObj sets the object of the label to the object bound to name.
+	withObj := func(name string) (int, bool) {
+		obj, ok := s.Lookup(g, name)
+		if ok {
+			label.Obj, label.ObjName = obj, s.Objects[obj].Name
+		}
+		return obj, ok
+	}
+	switch stmt := stmt.(type) {
+	case *migo.TauStatement:
+		return single(Tau, s.update(g.ID, s.continueWith(g, rest)))
+
+	case *migo.IfStatement:
+		return s.choice(g, label, rest, stmt.Then, stmt.Else)
+
+	case *migo.IfForStatement:
+		return s.choice(g, label, rest, stmt.Then, stmt.Else)
+
+	case migo.ExtStatement:
+		if blocks := stmt.Blocks(); len(blocks) > 0 {
+			return s.choice(g, label, rest, blocks...)
+		}
+		return single(Tau, s.update(g.ID, s.continueWith(g, rest)))
+
+	case *migo.CallStatement:
+		fn, found := s.prog.Function(stmt.Name)
+		if !found { // undefined functions are τ
+			return single(Call, s.update(g.ID, s.continueWith(g, rest)))
+		}
+		frames := g.Frames[:len(g.Frames)-1]
+		if len(rest) > 0 {
+			frames = append(frames[:len(frames):len(frames)], &Frame{Func: g.top().Func, Stmts: rest, Env: g.top().Env})
+		} // otherwise it is a tail call, and the caller frame is dropped
+		if len(fn.Stmts) > 0 {
+			frames = append(frames[:len(frames):len(frames)], &Frame{Func: fn, Stmts: fn.Stmts, Env: s.bindParams(g, fn, stmt.Params)})
+		}
+		return single(Call, s.update(g.ID, &Goroutine{ID: g.ID, Parent: g.Parent, Spawn: g.Spawn, Root: g.Root, Frames: frames}))
+
+	case *migo.SpawnStatement:
+		next := s.update(g.ID, s.continueWith(g, rest))
+		if fn, found := s.prog.Function(stmt.Name); found {
+			id := len(next.Goroutines)
+			next.Goroutines = append(next.Goroutines, newGoroutine(id, g.ID, stmt, fn, s.bindParams(g, fn, stmt.Params)))
+		}
+		return single(Spawn, next)
+
+	case *migo.NewChanStatement:
+		obj := &Object{Kind: Chan, Name: stmt.Name.Name(), Site: stmt, Size: stmt.Size}
+		return single(NewChan, s.create(g, rest, stmt.Name.Name(), obj, &label))
+
+	case *migo.NewMem:
+		obj := &Object{Kind: Mem, Name: stmt.Name.Name(), Site: stmt}
+		return single(NewMem, s.create(g, rest, stmt.Name.Name(), obj, &label))
+
+	case *migo.NewSyncMutex:
+		obj := &Object{Kind: Mutex, Name: stmt.Name.Name(), Site: stmt}
+		return single(NewMutex, s.create(g, rest, stmt.Name.Name(), obj, &label))
+
+	case *migo.NewSyncRWMutex:
+		obj := &Object{Kind: RWMutex, Name: stmt.Name.Name(), Site: stmt}
+		return single(NewMutex, s.create(g, rest, stmt.Name.Name(), obj, &label))
+
+	case *migo.CloseStatement:
+		obj, ok := withObj(stmt.Chan)
+		if !ok || s.Objects[obj].Kind != Chan || s.Objects[obj].Closed { // close of closed channel is a fault
+			return nil
+		}
+		next := s.update(g.ID, s.continueWith(g, rest))
+		next.setObject(obj, func(o *Object) { o.Closed = true })
+		return single(Close, next)
+
+	case *migo.MemRead:
+		if _, ok := withObj(stmt.Name); !ok {
+			return nil
+		}
+		return single(Read, s.update(g.ID, s.continueWith(g, rest)))
+
+	case *migo.MemWrite:
+		if _, ok := withObj(stmt.Name); !ok {
+			return nil
+		}
+		return single(Write, s.update(g.ID, s.continueWith(g, rest)))
+
+	case *migo.SyncMutexLock:
+		obj, ok := withObj(stmt.Name)
+		if !ok || s.Objects[obj].Locked || s.Objects[obj].Readers > 0 {
+			return nil
+		}
+		next := s.update(g.ID, s.continueWith(g, rest))
+		next.setObject(obj, func(o *Object) { o.Locked = true })
+		return single(Lock, next)
+
+	case *migo.SyncMutexUnlock:
+		obj, ok := withObj(stmt.Name)
+		if !ok || !s.Objects[obj].Locked { // unlock of unlocked mutex is a fault
+			return nil
+		}
+		next := s.update(g.ID, s.continueWith(g, rest))
+		next.setObject(obj, func(o *Object) { o.Locked = false })
+		return single(Unlock, next)
+
+	case *migo.SyncRWMutexRLock:
+		obj, ok := withObj(stmt.Name)
+		if !ok || s.Objects[obj].Locked {
+			return nil
+		}
+		next := s.update(g.ID, s.continueWith(g, rest))
+		next.setObject(obj, func(o *Object) { o.Readers++ })
+		return single(RLock, next)
+
+	case *migo.SyncRWMutexRUnlock:
+		obj, ok := withObj(stmt.Name)
+		if !ok || s.Objects[obj].Readers == 0 { // runlock of unlocked mutex is a fault
+			return nil
+		}
+		next := s.update(g.ID, s.continueWith(g, rest))
+		next.setObject(obj, func(o *Object) { o.Readers-- })
+		return single(RUnlock, next)
+	}
+	return nil
+}
+
+// choice returns one reduction for each of the blocks.
+func (s *State) choice(g *Goroutine, label Label, rest []migo.Statement, blocks ...[]migo.Statement) []Transition {
+	ts := make([]Transition, len(blocks))
+	for i, block := range blocks {
+		l := label
+		l.Kind, l.Case = Choice, i
+		ts[i] = Transition{Label: l, Next: s.update(g.ID, s.continueWith(g, concat(block, rest)))}
+	}
+	return ts
+}
+
+// create returns the state after g creates object obj bound to name.
+func (s *State) create(g *Goroutine, rest []migo.Statement, name string, obj *Object, label *Label) *State {
+	next := s.update(g.ID, nil)
+	idx := len(next.Objects)
+	next.Objects = append(next.Objects[:idx:idx], obj)
+	label.Obj, label.ObjName = idx, obj.Name
+	env := make(map[string]int, len(g.top().Env)+1)
+	for k, v := range g.top().Env {
+		env[k] = v
+	}
+	env[name] = idx
+	frames := append(g.Frames[:len(g.Frames)-1:len(g.Frames)-1], &Frame{Func: g.top().Func, Stmts: rest, Env: env})
+	next.Goroutines[g.ID] = normalise(&Goroutine{ID: g.ID, Parent: g.Parent, Spawn: g.Spawn, Root: g.Root, Frames: frames})
+	return next
+}
+
+// bindParams returns the environment of callee fn called by g with params.
+func (s *State) bindParams(g *Goroutine, fn *migo.Function, params []*migo.Parameter) map[string]int {
+	env := make(map[string]int, len(fn.Params))
+	for i, p := range params {
+		if i >= len(fn.Params) {
+			break
+		}
+		if obj, ok := s.Lookup(g, p.Caller.Name()); ok {
+			env[fn.Params[i].Callee.Name()] = obj
+		}
+	}
+	return env
+}
+
+// continueWith returns g with the statements of its current frame
+// replaced by stmts.
+func (s *State) continueWith(g *Goroutine, stmts []migo.Statement) *Goroutine {
+	frames := append(g.Frames[:len(g.Frames)-1:len(g.Frames)-1], &Frame{Func: g.top().Func, Stmts: stmts, Env: g.top().Env})
+	return normalise(&Goroutine{ID: g.ID, Parent: g.Parent, Spawn: g.Spawn, Root: g.Root, Frames: frames})
+}
+
+// normalise pops all empty frames from the call stack of g.
+func normalise(g *Goroutine) *Goroutine {
+	for len(g.Frames) > 0 && len(g.top().Stmts) == 0 {
+		g.Frames = g.Frames[:len(g.Frames)-1]
+	}
+	return g
+}
+
+// update returns a copy of s with goroutine id replaced by g,
+// or unchanged if g is nil.
+func (s *State) update(id int, g *Goroutine) *State {
+	next := &State{
+		Goroutines: make([]*Goroutine, len(s.Goroutines)),
+		Objects:    s.Objects,
+		prog:       s.prog,
+		globals:    s.globals,
+		ids:        s.ids,
+	}
+	copy(next.Goroutines, s.Goroutines)
+	if g != nil {
+		next.Goroutines[id] = g
+	}
+	return next
+}
+
+// setObject replaces Object obj of s by a copy modified by fn.
+//
+// setObject must only be used on states not yet shared.
+func (s *State) setObject(obj int, fn func(o *Object)) {
+	objects := make([]*Object, len(s.Objects))
+	copy(objects, s.Objects)
+	o := *objects[obj]
+	fn(&o)
+	objects[obj] = &o
+	s.Objects = objects
+}
+
+// concat returns a new slice of a followed by b.
+func concat(a, b []migo.Statement) []migo.Statement {
+	ss := make([]migo.Statement, 0, len(a)+len(b))
+	ss = append(ss, a...)
+	return append(ss, b...)
+}
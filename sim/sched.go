@@ -0,0 +1,106 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// Scheduler chooses the reduction taken at each step of an execution.
+//
+// Schedulers may be stateful, so a Scheduler is used by one execution at a
+// time.
+type Scheduler interface {
+	// Choose returns the index in enabled of the reduction of State s to
+	// take, where enabled are the Successors of s and are not empty, or an
+	// error to stop the execution.
+	Choose(s *semantics.State, enabled []semantics.Transition) (int, error)
+}
+
+type random struct {
+	rnd *rand.Rand
+}
+
+// Random returns a Scheduler choosing reductions uniformly at random,
+// from a pseudo-random source with the seed, so executions with the same
+// seed are the same.
+func Random(seed int64) Scheduler {
+	return &random{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (r *random) Choose(_ *semantics.State, enabled []semantics.Transition) (int, error) {
+	return r.rnd.Intn(len(enabled)), nil
+}
+
+type roundRobin struct {
+	last int // Goroutine scheduled last.
+}
+
+// RoundRobin returns a Scheduler taking turns between goroutines in order
+// of their IDs, where the goroutine after the one scheduled last which can
+// reduce takes its first reduction, e.g. the first branch of an if.
+func RoundRobin() Scheduler {
+	return &roundRobin{last: -1}
+}
+
+func (r *roundRobin) Choose(s *semantics.State, enabled []semantics.Transition) (int, error) {
+	n := len(s.Goroutines)
+	for d := 1; d <= n; d++ {
+		g := (r.last + d) % n
+		for i, t := range enabled {
+			if t.Label.G == g || t.Label.Peer == g {
+				r.last = g
+				return i, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// ErrReplay is the error if a replayed execution diverges from the
+// recorded one.
+type ErrReplay struct {
+	Step  int             // Index of the step diverging.
+	Label semantics.Label // Recorded label, zero if the recording ended.
+	Ended bool            // The recording ended before the execution.
+}
+
+func (e *ErrReplay) Error() string {
+	if e.Ended {
+		return fmt.Sprintf("replay: recording ended at step %d", e.Step)
+	}
+	return fmt.Sprintf("replay: step %d: %s is not enabled", e.Step, e.Label)
+}
+
+type replay struct {
+	labels []semantics.Label
+	step   int
+}
+
+// Replay returns a Scheduler taking the reductions of a recorded execution,
+// e.g. Execution.Labels, in order.
+//
+// Reductions are matched by kind, goroutines, branch or case and object,
+// so a recording can be replayed on the program parsed again. Choose
+// returns an *ErrReplay if the recorded reduction is not enabled, or the
+// recording ends before the execution stops.
+func Replay(labels []semantics.Label) Scheduler {
+	return &replay{labels: labels}
+}
+
+func (r *replay) Choose(_ *semantics.State, enabled []semantics.Transition) (int, error) {
+	if r.step >= len(r.labels) {
+		return 0, &ErrReplay{Step: r.step, Ended: true}
+	}
+	want := r.labels[r.step]
+	for i, t := range enabled {
+		l := t.Label
+		if l.Kind == want.Kind && l.G == want.G && l.Case == want.Case && l.Peer == want.Peer &&
+			l.PeerCase == want.PeerCase && l.Obj == want.Obj && l.ObjName == want.ObjName {
+			r.step++
+			return i, nil
+		}
+	}
+	return 0, &ErrReplay{Step: r.step, Label: want}
+}
@@ -0,0 +1,196 @@
+// Package sim executes MiGo programs, as given by the semantics package,
+// under a scheduler choosing a reduction at each step.
+//
+// A simulation follows a single execution, so it is a cheap smoke test
+// before a verification exploring all executions (see package verify).
+//
+// # Usage
+//
+// To run a program under a random scheduler:
+//
+//	exec, err := sim.Run(prog, "main.main", sim.Random(42))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(exec.Outcome)
+//
+// The execution can be replayed with sim.Replay(exec.Labels()).
+package sim
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// DefaultMaxSteps is the step limit used if none is given in Config.
+const DefaultMaxSteps = 10000
+
+// Outcome is the reason an execution stops.
+type Outcome int
+
+// Outcomes of an execution.
+const (
+	Terminated Outcome = iota // The entry goroutine terminated, so the program exits.
+	Deadlock                  // No goroutine can reduce, and the entry goroutine is blocked.
+	StepLimit                 // The step limit is reached.
+	Fault                     // No goroutine can reduce but by a runtime fault, so the program panics.
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Terminated:
+		return "terminated"
+	case Deadlock:
+		return "deadlock"
+	case StepLimit:
+		return "step limit"
+	case Fault:
+		return "fault"
+	}
+	return fmt.Sprintf("Outcome(%d)", int(o))
+}
+
+// Execution is the result of a simulation.
+type Execution struct {
+	Trace   []semantics.Transition // Reductions taken, in order.
+	Final   *semantics.State       // State the execution stops in.
+	Outcome Outcome
+
+	// Faults are the runtime faults of Final if Outcome is Fault (see
+	// semantics.Faults), e.g. a close of a closed channel.
+	Faults []semantics.Label
+}
+
+// Labels returns the labels of the reductions of the execution, to replay
+// the execution.
+func (e *Execution) Labels() []semantics.Label {
+	labels := make([]semantics.Label, len(e.Trace))
+	for i, t := range e.Trace {
+		labels[i] = t.Label
+	}
+	return labels
+}
+
+// Config is the configuration of simulations.
+type Config struct {
+	MaxSteps int // Maximum number of reductions, DefaultMaxSteps if not positive.
+
+	// Workers is the number of simulations run in parallel by RunSeeds,
+	// runtime.GOMAXPROCS(0) if not positive.
+	Workers int
+}
+
+func (c Config) maxSteps() int {
+	if c.MaxSteps <= 0 {
+		return DefaultMaxSteps
+	}
+	return c.MaxSteps
+}
+
+func (c Config) workers() int {
+	if c.Workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return c.Workers
+}
+
+// Run executes Program prog from function entry under Scheduler sched,
+// until the entry goroutine terminates, no goroutine can reduce, or
+// DefaultMaxSteps reductions are taken.
+//
+// An execution where no goroutine can reduce is a Fault if a goroutine is
+// stuck on a runtime fault, and a Deadlock otherwise.
+func Run(prog *migo.Program, entry string, sched Scheduler) (*Execution, error) {
+	return Config{}.Run(prog, entry, sched)
+}
+
+// Run executes Program prog from function entry under Scheduler sched,
+// until the entry goroutine terminates, no goroutine can reduce, or
+// c.MaxSteps reductions are taken.
+//
+// Returns the execution up to the step sched fails at, with the error of
+// sched.
+func (c Config) Run(prog *migo.Program, entry string, sched Scheduler) (*Execution, error) {
+	init, err := semantics.Initial(prog, entry)
+	if err != nil {
+		return nil, err
+	}
+	return c.run(init, sched)
+}
+
+func (c Config) run(init *semantics.State, sched Scheduler) (*Execution, error) {
+	exec := &Execution{Final: init, Outcome: StepLimit}
+	for step := 0; step < c.maxSteps(); step++ {
+		s := exec.Final
+		if s.Goroutines[0].Terminated() {
+			exec.Outcome = Terminated
+			return exec, nil
+		}
+		enabled := semantics.Successors(s)
+		if len(enabled) == 0 {
+			exec.stuck()
+			return exec, nil
+		}
+		i, err := sched.Choose(s, enabled)
+		if err != nil {
+			return exec, err
+		}
+		if i < 0 || i >= len(enabled) {
+			return exec, fmt.Errorf("sim: scheduler chose reduction %d of %d", i, len(enabled))
+		}
+		exec.Trace = append(exec.Trace, enabled[i])
+		exec.Final = enabled[i].Next
+	}
+	if exec.Final.Goroutines[0].Terminated() {
+		exec.Outcome = Terminated
+	} else if len(semantics.Successors(exec.Final)) == 0 {
+		exec.stuck()
+	}
+	return exec, nil
+}
+
+// stuck sets the outcome of an execution whose final state has no
+// successors, and where the entry goroutine has not terminated.
+func (e *Execution) stuck() {
+	if e.Faults = semantics.Faults(e.Final); len(e.Faults) > 0 {
+		e.Outcome = Fault
+	} else {
+		e.Outcome = Deadlock
+	}
+}
+
+// RunSeeds executes Program prog from function entry once for each seed,
+// under a Random scheduler with the seed, running c.Workers simulations in
+// parallel.
+//
+// The executions are returned in the order of seeds, and are the same as
+// those of sequential runs.
+func (c Config) RunSeeds(prog *migo.Program, entry string, seeds []int64) ([]*Execution, error) {
+	init, err := semantics.Initial(prog, entry)
+	if err != nil {
+		return nil, err
+	}
+	execs := make([]*Execution, len(seeds))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < c.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// Random never fails.
+				execs[i], _ = c.run(init, Random(seeds[i]))
+			}
+		}()
+	}
+	for i := range seeds {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return execs, nil
+}
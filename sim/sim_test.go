@@ -0,0 +1,201 @@
+package sim_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+	"github.com/JorgeGCoelho/migo/v3/sim"
+)
+
+// actions returns the reductions of exec, e.g. "g0: send ch".
+func actions(exec *sim.Execution) []string {
+	var as []string
+	for _, l := range exec.Labels() {
+		as = append(as, l.String())
+	}
+	return as
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		prog string
+		want sim.Outcome
+	}{
+		{"buffered", `
+def main.main():
+	let ch = newchan ch, 1;
+	send ch;
+	recv ch;
+`, sim.Terminated},
+		{"unbuffered", `
+def main.main():
+	let ch = newchan ch, 0;
+	send ch;
+`, sim.Deadlock},
+		{"double close", `
+def main.main():
+	let ch = newchan ch, 0;
+	close ch;
+	close ch;
+`, sim.Fault},
+		{"loop", `
+def main.main():
+	tau;
+	call main.main();
+`, sim.StepLimit},
+		{"select", `
+def main.main():
+	let ch = newchan ch, 0;
+	spawn s(ch);
+	select
+		case recv ch;
+		case tau; recv ch;
+	endselect;
+def s(c):
+	send c;
+`, sim.Terminated},
+	}
+	for _, test := range tests {
+		prog, err := parser.Parse(strings.NewReader(test.prog))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sched := range []sim.Scheduler{sim.Random(1), sim.RoundRobin()} {
+			exec, err := sim.Config{MaxSteps: 50}.Run(prog, "main.main", sched)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if exec.Outcome != test.want {
+				t.Errorf("%s: expected %s but got %s", test.name, test.want, exec.Outcome)
+			}
+			if want, got := exec.Outcome == sim.Fault, len(exec.Faults) > 0; want != got {
+				t.Errorf("%s: expected faults only for a fault outcome but got %v", test.name, exec.Faults)
+			}
+		}
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan ch, 2;
+	spawn w(ch);
+	send ch;
+	send ch;
+	recv ch;
+def w(c):
+	tau;
+	tau;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec, err := sim.Run(prog, "main.main", sim.RoundRobin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"g0: newchan ch", "g0: spawn", "g1: tau", "g0: send ch", "g1: tau", "g0: send ch", "g0: recv ch"}
+	if got := actions(exec); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected %v but got %v", want, got)
+	}
+	if exec.Outcome != sim.Terminated {
+		t.Errorf("expected terminated but got %s", exec.Outcome)
+	}
+}
+
+const racy = `
+def main.main():
+	let ch = newchan ch, 0;
+	spawn w(ch);
+	spawn w(ch);
+	spawn w(ch);
+	recv ch;
+	recv ch;
+	recv ch;
+def w(c):
+	if tau; else tau; tau; endif;
+	send c;
+`
+
+func TestRandomReplay(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(racy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	distinct := make(map[string]bool)
+	for seed := int64(0); seed < 20; seed++ {
+		exec, err := sim.Run(prog, "main.main", sim.Random(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := sim.Run(prog, "main.main", sim.Random(seed))
+		if !reflect.DeepEqual(actions(exec), actions(again)) {
+			t.Errorf("seed %d: executions differ", seed)
+		}
+		distinct[strings.Join(actions(exec), ", ")] = true
+
+		// Replay on the program parsed again.
+		reparsed, err := parser.Parse(strings.NewReader(racy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		replayed, err := sim.Run(reparsed, "main.main", sim.Replay(exec.Labels()))
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if !reflect.DeepEqual(actions(exec), actions(replayed)) || replayed.Outcome != exec.Outcome {
+			t.Errorf("seed %d: expected replay %v but got %v", seed, actions(exec), actions(replayed))
+		}
+	}
+	if len(distinct) < 2 {
+		t.Errorf("expected different executions for different seeds")
+	}
+}
+
+func TestReplayDiverges(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(racy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := []semantics.Label{{Kind: semantics.Comm, G: 1, Peer: 0, Case: -1, PeerCase: -1}}
+	exec, err := sim.Run(prog, "main.main", sim.Replay(labels))
+	e, ok := err.(*sim.ErrReplay)
+	if !ok || e.Step != 0 || e.Ended {
+		t.Fatalf("expected replay to diverge at step 0 but got %v", err)
+	}
+	if len(exec.Trace) != 0 {
+		t.Errorf("expected empty trace but got %v", actions(exec))
+	}
+
+	exec, _ = sim.Run(prog, "main.main", sim.Random(0))
+	_, err = sim.Run(prog, "main.main", sim.Replay(exec.Labels()[:3]))
+	if e, ok := err.(*sim.ErrReplay); !ok || e.Step != 3 || !e.Ended {
+		t.Errorf("expected recording to end at step 3 but got %v", err)
+	}
+}
+
+func TestRunSeeds(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(racy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds := make([]int64, 64)
+	for i := range seeds {
+		seeds[i] = int64(i)
+	}
+	execs, err := sim.Config{Workers: 8}.RunSeeds(prog, "main.main", seeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, seed := range seeds {
+		exec, _ := sim.Run(prog, "main.main", sim.Random(seed))
+		if !reflect.DeepEqual(actions(exec), actions(execs[i])) {
+			t.Errorf("seed %d: parallel execution differs from sequential", seed)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package slice projects MiGo programs onto a subset of their variables.
+//
+// A slice of a program keeps the operations on the selected channels,
+// memory or mutexes, and the call/spawn structure that reaches them. Every
+// other prefix is replaced by τ, and the result is simplified so that
+// functions which reduce to τ are removed.
+//
+// # Usage
+//
+// To only keep the behaviour of main.main on channel ch:
+//
+//	sliced, err := slice.ByVariable(prog, "main.main", "ch")
+package slice
+
+import (
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/alias"
+	"github.com/JorgeGCoelho/migo/v3/internal/clone"
+	"github.com/JorgeGCoelho/migo/v3/internal/passes/deadcall"
+	"github.com/JorgeGCoelho/migo/v3/internal/passes/normalise"
+	"github.com/JorgeGCoelho/migo/v3/internal/passes/taufunc"
+	"github.com/JorgeGCoelho/migo/v3/internal/passes/unused"
+)
+
+// ByVariable returns a new Program which is the projection of Program prog
+// onto the operations on the variables names, as seen from the function
+// entry. Variables are tracked across call and spawn through parameters.
+//
+// The input Program prog is not modified, the statements kept are copies.
+func ByVariable(prog *migo.Program, entry string, names ...string) (*migo.Program, error) {
+	entryFn, found := prog.Function(entry)
+	if !found {
+		return nil, &migo.ErrFuncNotFound{Name: entry}
+	}
+	s := slicer{prog: prog, names: alias.Track(prog, entryFn, names...)}
+	sliced := migo.NewProgram()
+	var slicedEntry *migo.Function
+	for _, fn := range prog.Funcs {
+		if _, reachable := s.names[fn]; !reachable {
+			continue
+		}
+		newFn := s.function(fn)
+		if fn == entryFn {
+			slicedEntry = newFn
+		}
+		sliced.AddFunction(newFn)
+	}
+	if err := taufunc.Find(sliced, taufunc.RemoveExcept(slicedEntry)); err != nil {
+		return nil, err
+	}
+	if _, err := unused.Remove(sliced, slicedEntry); err != nil {
+		return nil, err
+	}
+	if err := deadcall.Remove(sliced); err != nil {
+		return nil, err
+	}
+	normalise.Rewrite(sliced)
+	return sliced, nil
+}
+
+type slicer struct {
+	prog  *migo.Program
+	names alias.Names
+}
+
+// function returns the projection of fn.
+func (s *slicer) function(fn *migo.Function) *migo.Function {
+	newFn := migo.NewFunction(fn.Name)
+	newFn.Params = s.params(fn)
+	newFn.Stmts = s.stmts(fn, fn.Stmts)
+	newFn.HasComm = fn.HasComm
+	return newFn
+}
+
+// params returns the parameters of fn which are tracked.
+func (s *slicer) params(fn *migo.Function) []*migo.Parameter {
+	params := []*migo.Parameter{}
+	for _, p := range fn.Params {
+		if s.names.Has(fn, p.Callee.Name()) {
+			params = append(params, &migo.Parameter{Caller: p.Caller, Callee: p.Callee})
+		}
+	}
+	return params
+}
+
+// args returns the arguments of a call to function name,
+// matching the parameters kept by params.
+func (s *slicer) args(name string, args []*migo.Parameter) []*migo.Parameter {
+	callee, found := s.prog.Function(name)
+	if !found {
+		return clone.Params(args)
+	}
+	kept := []*migo.Parameter{}
+	for i, arg := range args {
+		if i < len(callee.Params) && s.names.Has(callee, callee.Params[i].Callee.Name()) {
+			kept = append(kept, &migo.Parameter{Caller: arg.Caller, Callee: arg.Callee})
+		}
+	}
+	return kept
+}
+
+func (s *slicer) stmts(fn *migo.Function, stmts []migo.Statement) []migo.Statement {
+	ss := make([]migo.Statement, len(stmts))
+	for i, stmt := range stmts {
+		ss[i] = s.stmt(fn, stmt)
+	}
+	return ss
+}
+
+// stmt returns the projection of stmt in function fn.
+func (s *slicer) stmt(fn *migo.Function, stmt migo.Statement) migo.Statement {
+	var name string
+	switch stmt := stmt.(type) {
+	case *migo.SendStatement:
+		name = stmt.Chan
+	case *migo.RecvStatement:
+		name = stmt.Chan
+	case *migo.CloseStatement:
+		name = stmt.Chan
+	case *migo.NewChanStatement:
+		name = stmt.Name.Name()
+	case *migo.NewMem:
+		name = stmt.Name.Name()
+	case *migo.MemRead:
+		name = stmt.Name
+	case *migo.MemWrite:
+		name = stmt.Name
+	case *migo.NewSyncMutex:
+		name = stmt.Name.Name()
+	case *migo.SyncMutexLock:
+		name = stmt.Name
+	case *migo.SyncMutexUnlock:
+		name = stmt.Name
+	case *migo.NewSyncRWMutex:
+		name = stmt.Name.Name()
+	case *migo.SyncRWMutexRLock:
+		name = stmt.Name
+	case *migo.SyncRWMutexRUnlock:
+		name = stmt.Name
+
+	case *migo.CallStatement:
+		return &migo.CallStatement{Name: stmt.Name, Params: s.args(stmt.Name, stmt.Params)}
+
+	case *migo.SpawnStatement:
+		return &migo.SpawnStatement{Name: stmt.Name, Params: s.args(stmt.Name, stmt.Params)}
+
+	case *migo.IfStatement:
+		return &migo.IfStatement{Then: s.stmts(fn, stmt.Then), Else: s.stmts(fn, stmt.Else)}
+
+	case *migo.IfForStatement:
+		return &migo.IfForStatement{
+			ForCond: stmt.ForCond,
+			Then:    s.stmts(fn, stmt.Then),
+			Else:    s.stmts(fn, stmt.Else),
+		}
+
+	case *migo.SelectStatement:
+		cases := make([][]migo.Statement, len(stmt.Cases))
+		for i, c := range stmt.Cases {
+			cases[i] = s.stmts(fn, c)
+		}
+		return &migo.SelectStatement{Cases: cases}
+
+	case migo.ExtStatement:
+		if stmt.IsTau() && len(stmt.Blocks()) == 0 {
+			return &migo.TauStatement{}
+		}
+		var blocks [][]migo.Statement
+		for _, b := range stmt.Blocks() {
+			blocks = append(blocks, s.stmts(fn, b))
+		}
+		return stmt.WithBlocks(blocks)
+
+	default:
+		return clone.Stmt(stmt)
+	}
+	if s.names.Has(fn, name) {
+		return clone.Stmt(stmt)
+	}
+	return &migo.TauStatement{}
+}
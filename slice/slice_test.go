@@ -0,0 +1,167 @@
+package slice_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/slice"
+)
+
+// Tests slicing a program by one of its two channels.
+func TestByVariable(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan ch, 0;
+	let done = newchan done, 0;
+	spawn worker(ch, done);
+	send ch;
+	recv done;
+def worker(c, d):
+	recv c;
+	call finish(d);
+def finish(d):
+	send d;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	sliced, err := slice.ByVariable(prog, "main.main", "ch")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if want, got := 2, len(sliced.Funcs); want != got {
+		t.Errorf("expects %d functions but got %d:\n%s", want, got, sliced)
+	}
+	if _, found := sliced.Function("finish"); found {
+		t.Errorf("expects finish to be removed but got:\n%s", sliced)
+	}
+	mainFn, _ := sliced.Function("main.main")
+	if want, got := 3, len(mainFn.Stmts); want != got {
+		t.Errorf("expects %d statements in main.main but got %d:\n%s", want, got, mainFn)
+	}
+	worker, found := sliced.Function("worker")
+	if !found {
+		t.Errorf("expects worker in sliced program but got:\n%s", sliced)
+		t.FailNow()
+	}
+	if want, got := 1, len(worker.Params); want != got {
+		t.Errorf("expects %d parameters in worker but got %d:\n%s", want, got, worker)
+	}
+	if recv, ok := worker.Stmts[0].(*migo.RecvStatement); !ok || recv.Chan != "c" {
+		t.Errorf("expects worker to receive on c but got:\n%s", worker)
+	}
+	// Original program is unchanged.
+	if want, got := 3, len(prog.Funcs); want != got {
+		t.Errorf("expects %d functions in original program but got %d", want, got)
+	}
+}
+
+// Tests slicing a program by a mutex.
+func TestByVariableMutex(t *testing.T) {
+	s := `
+def main.main():
+	letsync mu mutex;
+	letmem x;
+	spawn f(mu, x);
+	lock mu;
+	write x;
+	unlock mu;
+def f(m, y):
+	read y;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	sliced, err := slice.ByVariable(prog, "main.main", "mu")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if want, got := 1, len(sliced.Funcs); want != got {
+		t.Errorf("expects %d functions but got %d:\n%s", want, got, sliced)
+	}
+	if want, got := 3, len(sliced.Funcs[0].Stmts); want != got {
+		t.Errorf("expects %d statements but got %d:\n%s", want, got, sliced)
+	}
+}
+
+func TestByVariableNoEntry(t *testing.T) {
+	prog, err := parser.Parse(strings.NewReader(`def f(): send x;`))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, err := slice.ByVariable(prog, "main.main", "x"); err == nil {
+		t.Errorf("expects error for missing entry function")
+	}
+}
+
+// extStmt is a user-defined Statement with blocks.
+type extStmt struct{ blocks [][]migo.Statement }
+
+func (s extStmt) String() string {
+	var blocks []string
+	for _, b := range s.blocks {
+		var stmts []string
+		for _, stmt := range b {
+			stmts = append(stmts, stmt.String())
+		}
+		blocks = append(blocks, strings.Join(stmts, "; "))
+	}
+	return "ext { " + strings.Join(blocks, " | ") + " }"
+}
+func (s extStmt) IsTau() bool                { return false }
+func (s extStmt) Blocks() [][]migo.Statement { return s.blocks }
+func (s extStmt) WithBlocks(blocks [][]migo.Statement) migo.ExtStatement {
+	return extStmt{blocks: blocks}
+}
+
+// Tests that slicing does not modify the statements of the original program.
+func TestByVariableUnchanged(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan ch, 0;
+	let done = newchan done, 0;
+	spawn worker(ch, done);
+	send ch;
+	recv done;
+def worker(c, d):
+	recv c;
+	send d;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	mainFn, _ := prog.Function("main.main")
+	mainFn.AddStmts(extStmt{blocks: [][]migo.Statement{
+		{&migo.TauStatement{}, &migo.SendStatement{Chan: "ch"}, &migo.CallStatement{Name: "undefined"}},
+		{&migo.RecvStatement{Chan: "done"}},
+	}})
+	before := prog.String()
+	sliced, err := slice.ByVariable(prog, "main.main", "ch")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if after := prog.String(); before != after {
+		t.Errorf("expects original program to be unchanged:\n%s\nbut got:\n%s", before, after)
+	}
+	slicedMain, _ := sliced.Function("main.main")
+	ext, ok := slicedMain.Stmts[len(slicedMain.Stmts)-1].(extStmt)
+	if !ok {
+		t.Errorf("expects ext statement to be kept but got:\n%s", slicedMain)
+		t.FailNow()
+	}
+	if want, got := "ext { send ch (-) | tau }", ext.String(); want != got {
+		t.Errorf("expects sliced %s but got %s", want, got)
+	}
+}
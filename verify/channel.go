@@ -0,0 +1,149 @@
+package verify
+
+import (
+	"fmt"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/explore"
+	"github.com/JorgeGCoelho/migo/v3/internal/usage"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// ChannelIssueKind is the kind of a channel safety issue.
+type ChannelIssueKind int
+
+// Kinds of channel safety issues.
+const (
+	SendOnClosed    ChannelIssueKind = iota // Send on a closed channel.
+	CloseOfClosed                           // Close of a closed channel.
+	CloseOfRecvOnly                         // Close of a parameter only received on.
+)
+
+func (k ChannelIssueKind) String() string {
+	switch k {
+	case SendOnClosed:
+		return "send on closed channel"
+	case CloseOfClosed:
+		return "close of closed channel"
+	case CloseOfRecvOnly:
+		return "close of receive-only channel"
+	}
+	return fmt.Sprintf("ChannelIssueKind(%d)", int(k))
+}
+
+// ChannelIssue is a channel safety issue.
+type ChannelIssue struct {
+	Kind  ChannelIssueKind
+	Func  string         // Name of the function of the statement.
+	Stmt  migo.Statement // Statement causing the issue.
+	At    *Actor         // Goroutine causing the issue, nil if found statically.
+	Trace *Trace         // Trace to the issue, nil if found statically.
+}
+
+func (i ChannelIssue) String() string {
+	if i.At != nil {
+		return fmt.Sprintf("%s: %s", i.Kind, *i.At)
+	}
+	return fmt.Sprintf("%s: %s in %s", i.Kind, i.Stmt, i.Func)
+}
+
+// ChannelReport is the result of a channel safety check.
+type ChannelReport struct {
+	Issues   []ChannelIssue // Issues found, at most one per statement and kind.
+	States   int            // Number of states explored.
+	Complete bool           // All reachable states are explored.
+}
+
+// ChannelSafety explores Program prog from its entry function, and reports
+// reachable states where a goroutine sends on a closed channel, or closes a
+// closed channel, with the shortest trace to the state.
+//
+// ChannelSafety also reports close statements on a parameter of a function
+// which, including the functions it calls or spawns, only ever receives on
+// the parameter.
+func ChannelSafety(prog *migo.Program, opts Options) (*ChannelReport, error) {
+	init, err := initial(prog, opts)
+	if err != nil {
+		return nil, err
+	}
+	report := new(ChannelReport)
+	type issueKey struct {
+		kind ChannelIssueKind
+		stmt migo.Statement
+	}
+	found := make(map[issueKey]bool)
+	res := explore.BFSContext(opts.context(), init, opts.reduced(false, true), func(n *explore.Node, succs []semantics.Transition) bool {
+		for _, fault := range semantics.Faults(n.State) {
+			var kind ChannelIssueKind
+			switch fault.Kind {
+			case semantics.Send:
+				kind = SendOnClosed
+			case semantics.Close:
+				kind = CloseOfClosed
+			default: // Not a channel fault.
+				continue
+			}
+			key := issueKey{kind: kind, stmt: fault.Stmt}
+			if found[key] {
+				continue
+			}
+			found[key] = true
+			at := newActor(n.State, fault.G, fault.Stmt, fault.Case)
+			report.Issues = append(report.Issues, ChannelIssue{
+				Kind:  kind,
+				Func:  n.State.Goroutines[fault.G].Func().Name,
+				Stmt:  fault.Stmt,
+				At:    &at,
+				Trace: NewTrace(n.Path(), n.State),
+			})
+		}
+		return true
+	})
+	report.States, report.Complete = res.States, res.Complete
+	report.Issues = append(report.Issues, closeOfRecvOnly(prog)...)
+	return report, nil
+}
+
+// closeOfRecvOnly returns the close statements on parameters which are
+// only received on.
+func closeOfRecvOnly(prog *migo.Program) []ChannelIssue {
+	uses := usage.Compute(prog)
+	var issues []ChannelIssue
+	for _, fn := range prog.Funcs {
+		params := make(map[string]bool)
+		for _, p := range fn.Params {
+			params[p.Callee.Name()] = true
+		}
+		forEachClose(fn.Stmts, func(stmt *migo.CloseStatement) {
+			u := uses[fn][stmt.Chan]
+			if params[stmt.Chan] && u.Has(usage.Recv) && !u.Has(usage.Send) {
+				issues = append(issues, ChannelIssue{Kind: CloseOfRecvOnly, Func: fn.Name, Stmt: stmt})
+			}
+		})
+	}
+	return issues
+}
+
+// forEachClose calls fn on each close statement in stmts.
+func forEachClose(stmts []migo.Statement, fn func(*migo.CloseStatement)) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *migo.CloseStatement:
+			fn(stmt)
+		case *migo.IfStatement:
+			forEachClose(stmt.Then, fn)
+			forEachClose(stmt.Else, fn)
+		case *migo.IfForStatement:
+			forEachClose(stmt.Then, fn)
+			forEachClose(stmt.Else, fn)
+		case *migo.SelectStatement:
+			for _, c := range stmt.Cases {
+				forEachClose(c, fn)
+			}
+		case migo.ExtStatement:
+			for _, b := range stmt.Blocks() {
+				forEachClose(b, fn)
+			}
+		}
+	}
+}
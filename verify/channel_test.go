@@ -0,0 +1,151 @@
+package verify_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/verify"
+)
+
+func TestChannelSafety(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan T, 1;
+	let done = newchan D, 0;
+	spawn producer(ch, done);
+	close ch;
+	recv done;
+def producer(c, d):
+	send c;
+	close d;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.ChannelSafety(prog, verify.Options{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if !report.Complete {
+		t.Errorf("expected complete exploration")
+	}
+	if want, got := 1, len(report.Issues); want != got {
+		t.Fatalf("expected %d issue but got %d: %v", want, got, report.Issues)
+	}
+	issue := report.Issues[0]
+	if want, got := verify.SendOnClosed, issue.Kind; want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+	if want, got := "producer", issue.Func; want != got {
+		t.Errorf("expected issue in %s but got %s", want, got)
+	}
+	if issue.Trace == nil || len(issue.Trace.Steps) == 0 {
+		t.Errorf("expected a trace to the issue")
+	}
+}
+
+func TestChannelSafetyDoubleClose(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan T, 0;
+	spawn closer(ch);
+	spawn closer(ch);
+	recv ch;
+def closer(c):
+	close c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.ChannelSafety(prog, verify.Options{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if want, got := 1, len(report.Issues); want != got {
+		t.Fatalf("expected %d issue but got %d: %v", want, got, report.Issues)
+	}
+	if want, got := verify.CloseOfClosed, report.Issues[0].Kind; want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+}
+
+func TestChannelSafetyCloseOfRecvOnly(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan T, 0;
+	spawn consumer(ch);
+	send ch;
+def consumer(c):
+	call get(c);
+	close c;
+def get(x):
+	recv x;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.ChannelSafety(prog, verify.Options{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if want, got := 1, len(report.Issues); want != got {
+		t.Fatalf("expected %d issue but got %d: %v", want, got, report.Issues)
+	}
+	issue := report.Issues[0]
+	if want, got := verify.CloseOfRecvOnly, issue.Kind; want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+	if want, got := "consumer", issue.Func; want != got {
+		t.Errorf("expected issue in %s but got %s", want, got)
+	}
+	if issue.Trace != nil {
+		t.Errorf("expected no trace for static issue")
+	}
+}
+
+// extStmt is a user-defined Statement with blocks.
+type extStmt struct{ blocks [][]migo.Statement }
+
+func (s extStmt) String() string             { return "ext" }
+func (s extStmt) IsTau() bool                { return true }
+func (s extStmt) Blocks() [][]migo.Statement { return s.blocks }
+func (s extStmt) WithBlocks(blocks [][]migo.Statement) migo.ExtStatement {
+	return extStmt{blocks: blocks}
+}
+
+// Tests that closes in blocks of user-defined statements are checked.
+func TestChannelSafetyCloseOfRecvOnlyExt(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan T, 0;
+	spawn consumer(ch);
+	send ch;
+def consumer(c):
+	recv c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer, _ := prog.Function("consumer")
+	consumer.AddStmts(extStmt{blocks: [][]migo.Statement{{&migo.CloseStatement{Chan: "c"}}}})
+	report, err := verify.ChannelSafety(prog, verify.Options{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if want, got := 1, len(report.Issues); want != got {
+		t.Fatalf("expected %d issue but got %d: %v", want, got, report.Issues)
+	}
+	if want, got := verify.CloseOfRecvOnly, report.Issues[0].Kind; want != got {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+}
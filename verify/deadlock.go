@@ -0,0 +1,87 @@
+package verify
+
+import (
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/explore"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// DeadlockReport is the result of a global deadlock check.
+type DeadlockReport struct {
+	Found    bool                   // A deadlock is found.
+	Trace    []semantics.Transition // Shortest trace to the deadlocked state.
+	State    *semantics.State       // Deadlocked state.
+	Blocked  []*semantics.Goroutine // Goroutines blocked in the deadlocked state.
+	States   int                    // Number of states explored.
+	Complete bool                   // All reachable states are explored.
+
+	// Faults are the runtime faults of the first reachable state which is
+	// stuck because of them (see semantics.Faults), e.g. a send on a closed
+	// channel, which is not a deadlock as the program panics.
+	Faults     []semantics.Label
+	FaultTrace []semantics.Transition // Shortest trace to the faulted state.
+}
+
+// Deadlock explores Program prog from its entry function, and reports a
+// global deadlock, i.e. a reachable state where the entry goroutine has not
+// terminated, some goroutines are blocked (e.g. on send, recv, select or
+// lock), and no goroutine can reduce.
+//
+// States where the entry goroutine is terminated are not deadlocks, as the
+// program exits when the entry function returns. States with no successors
+// because of runtime faults are not deadlocks either, and are reported in
+// the Faults of the report.
+func Deadlock(prog *migo.Program, opts Options) (*DeadlockReport, error) {
+	init, err := initial(prog, opts)
+	if err != nil {
+		return nil, err
+	}
+	report := new(DeadlockReport)
+	res := explore.BFSContext(opts.context(), init, opts.reduced(true, true), func(n *explore.Node, succs []semantics.Transition) bool {
+		if len(succs) > 0 {
+			return true
+		}
+		if faults := semantics.Faults(n.State); len(faults) > 0 {
+			if report.Faults == nil {
+				report.Faults, report.FaultTrace = faults, n.Path()
+			}
+			return true
+		}
+		if !isDeadlock(n.State) {
+			return true
+		}
+		report.Found = true
+		report.Trace = n.Path()
+		report.State = n.State
+		report.Blocked = blocked(n.State)
+		return false
+	})
+	report.States, report.Complete = res.States, res.Complete
+	return report, nil
+}
+
+// isDeadlock returns true if State s with no successors and no faults is
+// deadlocked.
+func isDeadlock(s *semantics.State) bool {
+	return !s.Goroutines[0].Terminated()
+}
+
+// blocked returns the goroutines not terminated in s.
+func blocked(s *semantics.State) []*semantics.Goroutine {
+	var gs []*semantics.Goroutine
+	for _, g := range s.Goroutines {
+		if !g.Terminated() {
+			gs = append(gs, g)
+		}
+	}
+	return gs
+}
+
+// Counterexample returns the trace to the deadlocked state with
+// Go source positions, or nil if no deadlock is found.
+func (r *DeadlockReport) Counterexample() *Trace {
+	if !r.Found {
+		return nil
+	}
+	return NewTrace(r.Trace, r.State)
+}
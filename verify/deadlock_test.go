@@ -0,0 +1,216 @@
+package verify_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+	"github.com/JorgeGCoelho/migo/v3/verify"
+)
+
+func TestDeadlock(t *testing.T) {
+	s := `
+def main.main():
+	let a = newchan A, 0;
+	let b = newchan B, 0;
+	spawn f(a, b);
+	recv a;
+	send b;
+def f(x, y):
+	recv y;
+	send x;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.Deadlock(prog, verify.Options{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if !report.Found {
+		t.Fatalf("expected deadlock to be found")
+	}
+	if want, got := 2, len(report.Blocked); want != got {
+		t.Errorf("expected %d blocked goroutines but got %d", want, got)
+	}
+	if want, got := 3, len(report.Trace); want != got {
+		t.Errorf("expected shortest trace of length %d but got %d: %v", want, got, report.Trace)
+	}
+	if _, ok := report.Blocked[0].Next().(*migo.RecvStatement); !ok {
+		t.Errorf("expected main blocked on recv but got %s", report.Blocked[0])
+	}
+}
+
+func TestNoDeadlock(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan T, 0;
+	letsync mu mutex;
+	spawn worker(ch, mu);
+	spawn worker(ch, mu);
+	recv ch;
+	recv ch;
+def worker(c, m):
+	lock m;
+	unlock m;
+	send c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.Deadlock(prog, verify.Options{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if report.Found {
+		t.Errorf("expected no deadlock but got:\n%s", report.State)
+	}
+	if !report.Complete {
+		t.Errorf("expected complete exploration")
+	}
+}
+
+// Tests that leaked goroutines are not reported as global deadlocks.
+func TestDeadlockMainTerminated(t *testing.T) {
+	s := `
+def main.main(): let ch = newchan T, 0; spawn f(ch);
+def f(c): recv c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.Deadlock(prog, verify.Options{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if report.Found {
+		t.Errorf("expected no deadlock but got:\n%s", report.State)
+	}
+}
+
+func TestDeadlockLimits(t *testing.T) {
+	s := `
+def main.main(): call f();
+def f(): let ch = newchan T, 1; send ch; call f();
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.Deadlock(prog, verify.Options{MaxStates: 100})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if report.Complete {
+		t.Errorf("expected incomplete exploration of unbounded program")
+	}
+	if want, got := 100, report.States; want != got {
+		t.Errorf("expected %d states explored but got %d", want, got)
+	}
+	report, err = verify.Deadlock(prog, verify.Options{MaxDepth: 10})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if report.Complete {
+		t.Errorf("expected incomplete exploration of unbounded program")
+	}
+}
+
+func TestDeadlockEntry(t *testing.T) {
+	s := `def f(): lock mu; lock mu;`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verify.Deadlock(prog, verify.Options{}); err == nil {
+		t.Errorf("expected error for missing entry function")
+	}
+	report, err := verify.Deadlock(prog, verify.Options{Entry: "f"})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if !report.Found {
+		t.Errorf("expected deadlock to be found")
+	}
+	if want, got := semantics.Lock, report.Trace[len(report.Trace)-1].Label.Kind; want != got {
+		t.Errorf("expected last reduction to be %s but got %s", want, got)
+	}
+}
+
+func TestDeadlockReduced(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan ch, 0;
+	spawn w(ch);
+	spawn w(ch);
+	spawn w(ch);
+	recv ch;
+	recv ch;
+	let done = newchan done, 0;
+	recv done;
+def w(c):
+	letmem x;
+	write x;
+	send c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := verify.Deadlock(prog, verify.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reduced, err := verify.Deadlock(prog, verify.Options{PartialOrder: true, Symmetry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plain.Found || !reduced.Found {
+		t.Fatalf("expected deadlock to be found with and without reduction")
+	}
+	if reduced.States >= plain.States {
+		t.Errorf("expected fewer than %d states with reduction but got %d", plain.States, reduced.States)
+	}
+}
+
+// Tests that states stuck because of a runtime fault are not deadlocks.
+func TestDeadlockFault(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan T, 0;
+	close ch;
+	send ch;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.Deadlock(prog, verify.Options{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if report.Found {
+		t.Errorf("expected no deadlock but got:\n%s", report.State)
+	}
+	if want, got := 1, len(report.Faults); want != got {
+		t.Fatalf("expected %d fault but got %v", want, report.Faults)
+	}
+	if want, got := semantics.Send, report.Faults[0].Kind; want != got {
+		t.Errorf("expected fault %s but got %s", want, got)
+	}
+	if want, got := 2, len(report.FaultTrace); want != got {
+		t.Errorf("expected trace of length %d to the fault but got %v", want, report.FaultTrace)
+	}
+}
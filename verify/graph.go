@@ -0,0 +1,89 @@
+package verify
+
+import (
+	"github.com/JorgeGCoelho/migo/v3/internal/explore"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// stateGraph is an explored state space, for properties over paths.
+type stateGraph struct {
+	order    []string // Keys of the states in breadth-first order.
+	vertices map[string]*vertex
+	preds    map[string][]string
+	res      explore.Result
+}
+
+// vertex is a state in a stateGraph, with its outgoing transitions.
+type vertex struct {
+	node  *explore.Node
+	succs []semantics.Transition
+	keys  []string     // Keys of the successors.
+	moves map[int]bool // Goroutines reducing in the outgoing transitions.
+}
+
+// exploreGraph explores the states reachable from init.
+func exploreGraph(init *semantics.State, opts Options) *stateGraph {
+	g := &stateGraph{vertices: make(map[string]*vertex), preds: make(map[string][]string)}
+	g.res = explore.BFSContext(opts.context(), init, opts.explore(), func(n *explore.Node, succs []semantics.Transition) bool {
+		key := n.State.Key()
+		v := &vertex{node: n, succs: succs, moves: make(map[int]bool)}
+		for _, t := range succs {
+			v.keys = append(v.keys, t.Next.Key())
+			v.moves[t.Label.G] = true
+			if t.Label.Peer >= 0 {
+				v.moves[t.Label.Peer] = true
+			}
+		}
+		g.vertices[key] = v
+		g.order = append(g.order, key)
+		return true
+	})
+	for _, key := range g.order {
+		for _, succ := range g.vertices[key].keys {
+			g.preds[succ] = append(g.preds[succ], key)
+		}
+	}
+	return g
+}
+
+// movable returns the states from which goroutine id eventually reduces.
+func (g *stateGraph) movable(id int) map[string]bool {
+	return g.reaching(func(v *vertex) bool { return v.moves[id] })
+}
+
+// open returns the states from which a state with successors not
+// explored is reachable, i.e. whose future is not fully known.
+func (g *stateGraph) open() map[string]bool {
+	return g.reaching(func(v *vertex) bool {
+		for _, key := range v.keys {
+			if _, ok := g.vertices[key]; !ok {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// reaching returns the states from which a state satisfying f is
+// reachable.
+func (g *stateGraph) reaching(f func(*vertex) bool) map[string]bool {
+	m := make(map[string]bool)
+	var queue []string
+	for _, key := range g.order {
+		if f(g.vertices[key]) {
+			m[key] = true
+			queue = append(queue, key)
+		}
+	}
+	for len(queue) > 0 {
+		var key string
+		key, queue = queue[0], queue[1:]
+		for _, pred := range g.preds[key] {
+			if !m[pred] {
+				m[pred] = true
+				queue = append(queue, pred)
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,162 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// Leak is a spawned goroutine stuck forever after the entry function
+// returns.
+type Leak struct {
+	Spawn  *migo.SpawnStatement // Statement spawning the goroutine.
+	Func   string               // Function containing Spawn.
+	Stmt   migo.Statement       // Statement the goroutine is blocked on.
+	At     Actor                // Goroutine blocked.
+	Reason string               // Why no partner arrives.
+	Trace  *Trace               // Shortest trace to a state where the goroutine is stuck.
+}
+
+func (l Leak) String() string {
+	return fmt.Sprintf("goroutine leak: %s: %s", l.At, l.Reason)
+}
+
+// LeakReport is the result of a goroutine leak check.
+type LeakReport struct {
+	Leaks    []Leak // Leaks found, at most one per spawn and blocking statement.
+	States   int    // Number of states explored.
+	Complete bool   // All reachable states are explored.
+
+	// Unconfirmed are goroutines blocked in states from which states not
+	// explored within the limits are reachable, so the goroutines may
+	// still reduce. Only found if exploration is not Complete.
+	Unconfirmed []Leak
+}
+
+// GoroutineLeaks explores Program prog from its entry function, and
+// reports goroutines which are stuck forever at a state where the entry
+// goroutine is terminated, i.e. goroutines which are not terminated, and
+// cannot reduce in any state reachable from the state.
+//
+// Global deadlock checks miss these, as the entry goroutine terminates.
+//
+// If exploration stops at the limits of opts, goroutines blocked in states
+// whose future is not fully explored are not reported as leaks, but as
+// Unconfirmed.
+func GoroutineLeaks(prog *migo.Program, opts Options) (*LeakReport, error) {
+	init, err := initial(prog, opts)
+	if err != nil {
+		return nil, err
+	}
+	graph := exploreGraph(init, opts)
+	canMove := make(map[int]map[string]bool)
+	movable := func(id int) map[string]bool {
+		if _, ok := canMove[id]; !ok {
+			canMove[id] = graph.movable(id)
+		}
+		return canMove[id]
+	}
+	open := graph.open()
+	report := &LeakReport{States: graph.res.States, Complete: graph.res.Complete}
+	type leakKey struct {
+		spawn *migo.SpawnStatement
+		stmt  migo.Statement
+	}
+	found := make(map[leakKey]bool)
+	unconfirmed := make(map[leakKey]bool)
+	for _, key := range graph.order {
+		n := graph.vertices[key].node
+		s := n.State
+		if !s.Goroutines[0].Terminated() {
+			continue
+		}
+		for _, g := range s.Goroutines[1:] {
+			if g.Terminated() || movable(g.ID)[key] {
+				continue
+			}
+			k := leakKey{spawn: g.Spawn, stmt: g.Next()}
+			if found[k] || (open[key] && unconfirmed[k]) {
+				continue
+			}
+			leak := Leak{
+				Spawn:  g.Spawn,
+				Func:   spawner(s, g),
+				Stmt:   g.Next(),
+				At:     newActor(s, g.ID, g.Next(), -1),
+				Reason: leakReason(s, g),
+				Trace:  NewTrace(n.Path(), s),
+			}
+			if open[key] {
+				unconfirmed[k] = true
+				report.Unconfirmed = append(report.Unconfirmed, leak)
+				continue
+			}
+			found[k] = true
+			report.Leaks = append(report.Leaks, leak)
+		}
+	}
+	return report, nil
+}
+
+// spawner returns the name of the function spawning goroutine g.
+func spawner(s *semantics.State, g *semantics.Goroutine) string {
+	for _, t := range s.Goroutines[g.Parent].Frames {
+		if containsStmt(t.Func.Stmts, g.Spawn) {
+			return t.Func.Name
+		}
+	}
+	for _, fn := range s.Program().Funcs {
+		if containsStmt(fn.Stmts, g.Spawn) {
+			return fn.Name
+		}
+	}
+	return ""
+}
+
+func containsStmt(stmts []migo.Statement, stmt migo.Statement) bool {
+	for _, s := range stmts {
+		if s == stmt {
+			return true
+		}
+		switch s := s.(type) {
+		case *migo.IfStatement:
+			if containsStmt(s.Then, stmt) || containsStmt(s.Else, stmt) {
+				return true
+			}
+		case *migo.IfForStatement:
+			if containsStmt(s.Then, stmt) || containsStmt(s.Else, stmt) {
+				return true
+			}
+		case *migo.SelectStatement:
+			for _, c := range s.Cases {
+				if containsStmt(c, stmt) {
+					return true
+				}
+			}
+		case migo.ExtStatement:
+			for _, b := range s.Blocks() {
+				if containsStmt(b, stmt) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// leakReason explains why goroutine g is stuck in s.
+func leakReason(s *semantics.State, g *semantics.Goroutine) string {
+	var others []string
+	for _, h := range s.Goroutines {
+		if h.ID != g.ID && !h.Terminated() {
+			others = append(others, fmt.Sprintf("g%d", h.ID))
+		}
+	}
+	what, _ := describe(g.Next(), -1)
+	if len(others) == 0 {
+		return fmt.Sprintf("%s after entry returned, and all other goroutines terminated", what)
+	}
+	return fmt.Sprintf("%s after entry returned, and no partner is reachable from %s", what, strings.Join(others, ", "))
+}
@@ -0,0 +1,150 @@
+package verify_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/verify"
+)
+
+func TestGoroutineLeaks(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan ch, 0;
+	let done = newchan done, 0;
+	spawn worker(ch);
+	spawn finisher(done);
+	recv done;
+def worker(c):
+	recv c;
+def finisher(d):
+	send d;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.GoroutineLeaks(prog, verify.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Complete {
+		t.Errorf("expected exploration to be complete")
+	}
+	if want, got := 1, len(report.Leaks); want != got {
+		t.Fatalf("expected %d leak but got %d: %v", want, got, report.Leaks)
+	}
+	leak := report.Leaks[0]
+	if want, got := "worker", leak.Spawn.Name; want != got {
+		t.Errorf("expected leaked goroutine spawned as %s but got %s", want, got)
+	}
+	if want, got := "main.main", leak.Func; want != got {
+		t.Errorf("expected spawn site in %s but got %s", want, got)
+	}
+	if _, ok := leak.Stmt.(*migo.RecvStatement); !ok {
+		t.Errorf("expected goroutine blocked on recv but got %s", leak.Stmt)
+	}
+	if want, got := "recv c after entry returned, and all other goroutines terminated", leak.Reason; want != got {
+		t.Errorf("expected reason %q but got %q", want, got)
+	}
+	text := leak.Trace.String()
+	if !strings.Contains(text, "blocked:\n    g1 [main.main > worker] recv c") {
+		t.Errorf("expected trace to show g1 blocked but got:\n%s", text)
+	}
+}
+
+func TestGoroutineLeaksNone(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan ch, 1;
+	spawn worker(ch);
+	spawn loop();
+def worker(c):
+	send c;
+def loop():
+	call loop();
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.GoroutineLeaks(prog, verify.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Leaks) != 0 {
+		t.Errorf("expected no leaks but got %v", report.Leaks)
+	}
+}
+
+func TestGoroutineLeaksBlockedPartner(t *testing.T) {
+	// Both goroutines wait for each other after main returns.
+	s := `
+def main.main():
+	let a = newchan a, 0;
+	let b = newchan b, 0;
+	spawn f(a, b);
+	spawn f(b, a);
+def f(x, y):
+	recv x;
+	send y;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.GoroutineLeaks(prog, verify.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(report.Leaks); want != got {
+		t.Fatalf("expected %d leaks but got %d: %v", want, got, report.Leaks)
+	}
+	if want, got := "recv x after entry returned, and no partner is reachable from g2", report.Leaks[0].Reason; want != got {
+		t.Errorf("expected reason %q but got %q", want, got)
+	}
+}
+
+func TestGoroutineLeaksBounded(t *testing.T) {
+	// The worker is released by the sender only beyond the depth bound.
+	s := `
+def main.main():
+	let ch = newchan ch, 0;
+	spawn worker(ch);
+	spawn sender(ch);
+def worker(c):
+	recv c;
+def sender(c):
+	tau;
+	tau;
+	tau;
+	tau;
+	send c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.GoroutineLeaks(prog, verify.Options{MaxDepth: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Complete {
+		t.Errorf("expected exploration to be incomplete")
+	}
+	if len(report.Leaks) != 0 {
+		t.Errorf("expected no leaks but got %v", report.Leaks)
+	}
+	if len(report.Unconfirmed) == 0 {
+		t.Errorf("expected unconfirmed leaks")
+	}
+	report, err = verify.GoroutineLeaks(prog, verify.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Leaks) != 0 || len(report.Unconfirmed) != 0 {
+		t.Errorf("expected no leaks but got %v, unconfirmed %v", report.Leaks, report.Unconfirmed)
+	}
+}
@@ -0,0 +1,258 @@
+package verify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/tarjan"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// Fairness is an assumption on the scheduling of goroutines, under which
+// liveness is checked.
+type Fairness int
+
+// Fairness assumptions.
+const (
+	// StrongFairness assumes a goroutine enabled infinitely often
+	// eventually reduces, so only goroutines which stay blocked are starved.
+	StrongFairness Fairness = iota
+	// WeakFairness assumes a goroutine enabled continuously eventually
+	// reduces.
+	WeakFairness
+	// NoFairness assumes nothing, so any goroutine not scheduled forever
+	// is starved.
+	NoFairness
+)
+
+func (f Fairness) String() string {
+	switch f {
+	case StrongFairness:
+		return "strong"
+	case WeakFairness:
+		return "weak"
+	case NoFairness:
+		return "none"
+	}
+	return fmt.Sprintf("Fairness(%d)", int(f))
+}
+
+// LivenessViolation is a channel action of a goroutine which never
+// communicates, while other goroutines reduce forever.
+type LivenessViolation struct {
+	Stmt  migo.Statement         // Channel action (send, recv or select) starved.
+	At    Actor                  // Goroutine starved.
+	Stem  []semantics.Transition // Reductions from the initial state to the cycle.
+	Cycle []semantics.Transition // Reductions repeated forever.
+}
+
+func (v LivenessViolation) String() string {
+	return fmt.Sprintf("liveness violation: %s never communicates", v.At)
+}
+
+// Lasso is a lasso-shaped counterexample, i.e. a stem from the initial
+// state followed by a cycle repeated forever.
+type Lasso struct {
+	Stem *Trace `json:"stem"`
+	// Cycle is empty if the execution stutters in a state without
+	// reductions, and Blocked lists the goroutines starved or blocked.
+	Cycle *Trace `json:"cycle"`
+}
+
+func (l *Lasso) String() string {
+	var sb strings.Builder
+	sb.WriteString("stem:\n")
+	_ = l.Stem.WriteText(&sb)
+	sb.WriteString("cycle:\n")
+	_ = l.Cycle.WriteText(&sb)
+	return sb.String()
+}
+
+// Counterexample returns the lasso of the violation with Go source
+// positions.
+func (v LivenessViolation) Counterexample() *Lasso {
+	cycle := NewTrace(v.Cycle, nil)
+	cycle.Blocked = []Actor{v.At}
+	return &Lasso{Stem: NewTrace(v.Stem, nil), Cycle: cycle}
+}
+
+// LivenessReport is the result of a liveness check.
+type LivenessReport struct {
+	Violations []LivenessViolation // At most one per spawn and statement.
+	States     int                 // Number of states explored.
+	Complete   bool                // All reachable states are explored.
+}
+
+// Liveness explores Program prog from its entry function, and reports
+// channel actions which are ready but never communicate, i.e. reachable
+// cycles of reductions while the entry goroutine runs, where a goroutine
+// waits on a send, recv or select throughout and never reduces, under
+// the fairness assumption opts.Fairness.
+//
+// Goroutines blocked in states without successors are not reported, see
+// Deadlock and GoroutineLeaks.
+func Liveness(prog *migo.Program, opts Options) (*LivenessReport, error) {
+	init, err := initial(prog, opts)
+	if err != nil {
+		return nil, err
+	}
+	graph := exploreGraph(init, opts)
+	report := &LivenessReport{States: graph.res.States, Complete: graph.res.Complete}
+	type violationKey struct {
+		spawn *migo.SpawnStatement
+		stmt  migo.Statement
+	}
+	found := make(map[violationKey]bool)
+	for id := 0; ; id++ {
+		waiting := waitingStates(graph, id)
+		if waiting == nil {
+			break
+		}
+		for _, scc := range starvingSCCs(graph, id, waiting, opts.Fairness) {
+			entry := scc[0]
+			for _, key := range scc {
+				if opts.Fairness != WeakFairness || !graph.vertices[key].moves[id] {
+					entry = key
+					break
+				}
+			}
+			n := graph.vertices[entry].node
+			g := n.State.Goroutines[id]
+			k := violationKey{spawn: g.Spawn, stmt: g.Next()}
+			if found[k] {
+				continue
+			}
+			found[k] = true
+			report.Violations = append(report.Violations, LivenessViolation{
+				Stmt:  g.Next(),
+				At:    newActor(n.State, id, g.Next(), -1),
+				Stem:  n.Path(),
+				Cycle: cycleFrom(graph, id, entry, scc),
+			})
+		}
+	}
+	return report, nil
+}
+
+// waitingStates returns the states where the entry goroutine runs, and
+// goroutine id waits on a channel action, or nil if goroutine id is not in
+// any state.
+func waitingStates(graph *stateGraph, id int) map[string]bool {
+	var waiting map[string]bool
+	for _, key := range graph.order {
+		s := graph.vertices[key].node.State
+		if id >= len(s.Goroutines) {
+			continue
+		}
+		if waiting == nil {
+			waiting = make(map[string]bool)
+		}
+		if s.Goroutines[0].Terminated() {
+			continue
+		}
+		switch s.Goroutines[id].Next().(type) {
+		case *migo.SendStatement, *migo.RecvStatement, *migo.SelectStatement:
+			waiting[key] = true
+		}
+	}
+	return waiting
+}
+
+// starvingSCCs returns the strongly connected components of the waiting
+// states, connected by reductions not involving goroutine id, which
+// contain a cycle starving goroutine id under fairness.
+func starvingSCCs(graph *stateGraph, id int, waiting map[string]bool, fairness Fairness) [][]string {
+	inSub := func(key string) bool {
+		return waiting[key] && (fairness != StrongFairness || !graph.vertices[key].moves[id])
+	}
+	succs := func(key string) []string {
+		var keys []string
+		v := graph.vertices[key]
+		for i, t := range v.succs {
+			if t.Label.G != id && t.Label.Peer != id && inSub(v.keys[i]) {
+				if _, explored := graph.vertices[v.keys[i]]; explored {
+					keys = append(keys, v.keys[i])
+				}
+			}
+		}
+		return keys
+	}
+	var roots []string
+	for _, key := range graph.order {
+		if inSub(key) {
+			roots = append(roots, key)
+		}
+	}
+	// Order the states by exploration, for the shortest stem.
+	order := make(map[string]int)
+	for i, key := range graph.order {
+		order[key] = i
+	}
+	var sccs [][]string
+	for _, scc := range tarjan.SCCs(roots, succs) {
+		cyclic := len(scc) > 1
+		for _, succ := range succs(scc[0]) {
+			cyclic = cyclic || succ == scc[0]
+		}
+		if !cyclic {
+			continue
+		}
+		if fairness == WeakFairness {
+			// A goroutine enabled throughout the cycle is scheduled.
+			disabled := false
+			for _, key := range scc {
+				disabled = disabled || !graph.vertices[key].moves[id]
+			}
+			if !disabled {
+				continue
+			}
+		}
+		sort.Slice(scc, func(i, j int) bool { return order[scc[i]] < order[scc[j]] })
+		sccs = append(sccs, scc)
+	}
+	return sccs
+}
+
+// cycleFrom returns a shortest cycle from entry back to entry in scc, by
+// reductions not involving goroutine id.
+func cycleFrom(graph *stateGraph, id int, entry string, scc []string) []semantics.Transition {
+	inSCC := make(map[string]bool)
+	for _, key := range scc {
+		inSCC[key] = true
+	}
+	type step struct {
+		from string
+		t    semantics.Transition
+	}
+	prev := make(map[string]step)
+	queue := []string{entry}
+	for len(queue) > 0 {
+		var key string
+		key, queue = queue[0], queue[1:]
+		v := graph.vertices[key]
+		for i, t := range v.succs {
+			next := v.keys[i]
+			if t.Label.G == id || t.Label.Peer == id || !inSCC[next] {
+				continue
+			}
+			if _, seen := prev[next]; seen {
+				continue
+			}
+			prev[next] = step{from: key, t: t}
+			if next == entry {
+				var cycle []semantics.Transition
+				for k := entry; ; {
+					s := prev[k]
+					cycle = append([]semantics.Transition{s.t}, cycle...)
+					if k = s.from; k == entry {
+						return cycle
+					}
+				}
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
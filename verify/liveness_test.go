@@ -0,0 +1,112 @@
+package verify_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/verify"
+)
+
+func TestLiveness(t *testing.T) {
+	// r waits on a recv no one sends on, while main loops.
+	s := `
+def main.main():
+	let ch = newchan ch, 0;
+	spawn r(ch);
+	call loop();
+def loop():
+	tau;
+	call loop();
+def r(c):
+	recv c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fairness := range []verify.Fairness{verify.StrongFairness, verify.WeakFairness, verify.NoFairness} {
+		report, err := verify.Liveness(prog, verify.Options{Fairness: fairness})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := 1, len(report.Violations); want != got {
+			t.Fatalf("%s: expected %d violation but got %d", fairness, want, got)
+		}
+		v := report.Violations[0]
+		if want, got := "g1 [main.main > r] recv c", v.At.String(); want != got {
+			t.Errorf("%s: expected %s to be starved but got %s", fairness, want, got)
+		}
+		if len(v.Cycle) == 0 {
+			t.Errorf("%s: expected non-empty cycle", fairness)
+		}
+		lasso := v.Counterexample().String()
+		if !strings.Contains(lasso, "cycle:\n") || !strings.Contains(lasso, "blocked:\n    g1 [main.main > r] recv c") {
+			t.Errorf("%s: unexpected lasso:\n%s", fairness, lasso)
+		}
+	}
+}
+
+func TestLivenessFairness(t *testing.T) {
+	// r can receive whenever main is at the select, but main may always
+	// choose tau.
+	s := `
+def main.main():
+	let ch = newchan ch, 0;
+	spawn r(ch);
+	call loop(ch);
+def loop(c):
+	select
+		case send c; call loop(c);
+		case tau; call loop(c);
+	endselect;
+def r(c):
+	recv c;
+	call r(c);
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		fairness verify.Fairness
+		want     int
+	}{
+		{verify.StrongFairness, 0},
+		{verify.WeakFairness, 1},
+		{verify.NoFairness, 1},
+	} {
+		report, err := verify.Liveness(prog, verify.Options{Fairness: tc.fairness})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Complete {
+			t.Errorf("%s: expected complete exploration", tc.fairness)
+		}
+		if want, got := tc.want, len(report.Violations); want != got {
+			t.Errorf("%s fairness: expected %d violations but got %d: %v", tc.fairness, want, got, report.Violations)
+		}
+	}
+}
+
+func TestLivenessTerminating(t *testing.T) {
+	s := `
+def main.main():
+	let ch = newchan ch, 0;
+	spawn r(ch);
+	send ch;
+def r(c):
+	recv c;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.Liveness(prog, verify.Options{Fairness: verify.NoFairness})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Violations) != 0 {
+		t.Errorf("expected no violations but got %v", report.Violations)
+	}
+}
@@ -0,0 +1,288 @@
+package verify
+
+import (
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/tarjan"
+	"github.com/JorgeGCoelho/migo/v3/ltl"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// LTLReport is the result of an LTL check.
+type LTLReport struct {
+	Formula        ltl.Formula // Formula checked.
+	Violated       bool        // An execution violating the formula is found.
+	Counterexample *Lasso      // Execution violating the formula, if Violated.
+	States         int         // Number of states explored.
+	Complete       bool        // All reachable states are explored.
+}
+
+// LTL explores Program prog from its entry function, and checks that all
+// executions satisfy formula f, by searching the product of the state
+// space and a Büchi automaton of the negation of f for an accepting cycle.
+//
+// Executions which terminate or deadlock stutter forever in their final
+// state, so the cycle of a counterexample ending in such a state is empty.
+func LTL(prog *migo.Program, f ltl.Formula, opts Options) (*LTLReport, error) {
+	init, err := initial(prog, opts)
+	if err != nil {
+		return nil, err
+	}
+	graph := exploreGraph(init, opts)
+	report := &LTLReport{Formula: f, States: graph.res.States, Complete: graph.res.Complete}
+	p := newProduct(graph, ltl.NewAutomaton(&ltl.Not{F: f}))
+	if stem, cycle, entry, ok := p.acceptingLasso(); ok {
+		report.Violated = true
+		var final *semantics.State
+		if len(cycle) == 0 {
+			final = graph.vertices[p.nodes[entry].state].node.State
+		}
+		report.Counterexample = &Lasso{Stem: NewTrace(stem, nil), Cycle: NewTrace(cycle, final)}
+	}
+	return report, nil
+}
+
+// productNode is a position of an execution, i.e. a state and the
+// reduction taken from it, paired with a state of the automaton.
+type productNode struct {
+	state string // Key of the state.
+	edge  int    // Index of the reduction in the successors, -1 to stutter.
+	q     int    // Automaton state.
+	prev  int    // Predecessor on a shortest path from an initial node, -1 if initial.
+}
+
+// product is the product of a state graph and an automaton, explored
+// breadth-first from the initial state.
+type product struct {
+	graph *stateGraph
+	aut   *ltl.Automaton
+	nodes []productNode
+	index map[productNode]int // Nodes by state, edge and q, with prev -1.
+	succs [][]int
+}
+
+func newProduct(graph *stateGraph, aut *ltl.Automaton) *product {
+	p := &product{graph: graph, aut: aut, index: make(map[productNode]int)}
+	if len(graph.order) == 0 {
+		return p
+	}
+	var queue []int
+	add := func(state string, qs []int, prev int) []int {
+		var ids []int
+		for _, edge := range p.edges(state) {
+			for _, q := range qs {
+				if !aut.States[q].Holds(func(a *ltl.Atom) bool { return p.holds(a, state, edge) }) {
+					continue
+				}
+				key := productNode{state: state, edge: edge, q: q, prev: -1}
+				id, ok := p.index[key]
+				if !ok {
+					id = len(p.nodes)
+					p.index[key] = id
+					n := key
+					n.prev = prev
+					p.nodes = append(p.nodes, n)
+					p.succs = append(p.succs, nil)
+					queue = append(queue, id)
+				}
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	var initials []int
+	for _, s := range aut.States {
+		if s.Initial {
+			initials = append(initials, s.ID)
+		}
+	}
+	add(graph.order[0], initials, -1)
+	for len(queue) > 0 {
+		var id int
+		id, queue = queue[0], queue[1:]
+		n := p.nodes[id]
+		next := n.state
+		if n.edge >= 0 {
+			next = graph.vertices[n.state].keys[n.edge]
+			if _, explored := graph.vertices[next]; !explored {
+				continue
+			}
+		}
+		p.succs[id] = add(next, aut.States[n.q].Succs, id)
+	}
+	return p
+}
+
+// edges returns the positions of state, i.e. its reductions, or a stutter
+// if it has none.
+func (p *product) edges(state string) []int {
+	v := p.graph.vertices[state]
+	if len(v.succs) == 0 {
+		return []int{-1}
+	}
+	edges := make([]int, len(v.succs))
+	for i := range edges {
+		edges[i] = i
+	}
+	return edges
+}
+
+// holds returns true if atomic proposition a holds at the position of
+// state taking the reduction edge.
+func (p *product) holds(a *ltl.Atom, state string, edge int) bool {
+	v := p.graph.vertices[state]
+	s := v.node.State
+	var l semantics.Label
+	if edge >= 0 {
+		l = v.succs[edge].Label
+	} else {
+		l = semantics.Label{Kind: semantics.Tau, Obj: -1}
+	}
+	switch a.Kind {
+	case ltl.Send:
+		return (l.Kind == semantics.Send || l.Kind == semantics.Comm) && l.ObjName == a.Arg
+	case ltl.Recv:
+		return (l.Kind == semantics.Recv || l.Kind == semantics.Comm) && l.ObjName == a.Arg
+	case ltl.Close:
+		return l.Kind == semantics.Close && l.ObjName == a.Arg
+	case ltl.Lock:
+		return (l.Kind == semantics.Lock || l.Kind == semantics.RLock) && l.ObjName == a.Arg
+	case ltl.Unlock:
+		return (l.Kind == semantics.Unlock || l.Kind == semantics.RUnlock) && l.ObjName == a.Arg
+	case ltl.Held:
+		for _, o := range s.Objects {
+			if o.Name == a.Arg && (o.Kind == semantics.Mutex || o.Kind == semantics.RWMutex) && (o.Locked || o.Readers > 0) {
+				return true
+			}
+		}
+	case ltl.Terminated:
+		return s.Goroutines[0].Terminated()
+	case ltl.Blocked:
+		for _, g := range s.Goroutines {
+			if g.Terminated() || v.moves[g.ID] {
+				continue
+			}
+			if fn := g.Func(); fn.Name == a.Arg || fn.SimpleName() == a.Arg {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// acceptingLasso returns the reductions of a stem and a cycle of the
+// product visiting each accepting set of the automaton, if any, and the
+// node the cycle starts at.
+func (p *product) acceptingLasso() (stem, cycle []semantics.Transition, entry int, ok bool) {
+	roots := make([]int, len(p.nodes))
+	for id := range p.nodes {
+		roots[id] = id
+	}
+	sccs := tarjan.SCCs(roots, func(id int) []int { return p.succs[id] })
+	accepting := make([]map[int]bool, len(p.aut.Accepting))
+	for i, set := range p.aut.Accepting {
+		accepting[i] = make(map[int]bool)
+		for _, q := range set {
+			accepting[i][q] = true
+		}
+	}
+	// Pick the accepting component closest to the initial state.
+	var best map[int]bool
+	entry = -1
+	for _, ids := range sccs {
+		scc := make(map[int]bool)
+		first := -1
+		for _, id := range ids {
+			scc[id] = true
+			if first < 0 || id < first {
+				first = id
+			}
+		}
+		if !p.cyclic(scc, first) || (entry >= 0 && first >= entry) {
+			continue
+		}
+		visits := true
+		for _, set := range accepting {
+			found := false
+			for id := range scc {
+				found = found || set[p.nodes[id].q]
+			}
+			visits = visits && found
+		}
+		if visits {
+			best, entry = scc, first
+		}
+	}
+	if entry < 0 {
+		return nil, nil, -1, false
+	}
+	for id := entry; p.nodes[id].prev >= 0; id = p.nodes[id].prev {
+		if prev := p.nodes[id].prev; p.nodes[prev].edge >= 0 {
+			stem = append([]semantics.Transition{p.transition(prev)}, stem...)
+		}
+	}
+	// Visit each accepting set in turn, then return to the entry.
+	var path []int
+	at := entry
+	for _, set := range accepting {
+		steps := p.shortestPath(best, at, func(id int) bool { return set[p.nodes[id].q] })
+		if len(steps) > 0 {
+			at = steps[len(steps)-1]
+		}
+		path = append(path, steps...)
+	}
+	path = append(path, p.shortestPath(best, at, func(id int) bool { return id == entry })...)
+	from := entry
+	for _, id := range path {
+		if p.nodes[from].edge >= 0 {
+			cycle = append(cycle, p.transition(from))
+		}
+		from = id
+	}
+	return stem, cycle, entry, true
+}
+
+// cyclic returns true if the component scc containing id has a cycle.
+func (p *product) cyclic(scc map[int]bool, id int) bool {
+	if len(scc) > 1 {
+		return true
+	}
+	for _, succ := range p.succs[id] {
+		if succ == id {
+			return true
+		}
+	}
+	return false
+}
+
+// shortestPath returns the nodes on a shortest path in scc of at least one
+// step from node from to a node satisfying target, excluding from.
+func (p *product) shortestPath(scc map[int]bool, from int, target func(int) bool) []int {
+	prev := map[int]int{}
+	queue := []int{from}
+	for len(queue) > 0 {
+		var id int
+		id, queue = queue[0], queue[1:]
+		for _, succ := range p.succs[id] {
+			if _, seen := prev[succ]; seen || !scc[succ] {
+				continue
+			}
+			prev[succ] = id
+			if target(succ) {
+				path := []int{succ}
+				for n := id; n != from; n = prev[n] {
+					path = append([]int{n}, path...)
+				}
+				return path
+			}
+			queue = append(queue, succ)
+		}
+	}
+	return nil
+}
+
+// transition returns the reduction taken at node id, which is not a
+// stutter.
+func (p *product) transition(id int) semantics.Transition {
+	n := p.nodes[id]
+	return p.graph.vertices[n.state].succs[n.edge]
+}
@@ -0,0 +1,132 @@
+package verify_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3/ltl"
+	"github.com/JorgeGCoelho/migo/v3/parser"
+	"github.com/JorgeGCoelho/migo/v3/verify"
+)
+
+func TestLTL(t *testing.T) {
+	// w closes done after a send on out, holding mu only around a tau.
+	s := `
+def main.main():
+	let out = newchan out, 1;
+	let done = newchan done, 0;
+	letsync mu mutex;
+	spawn w(out, done, mu);
+	recv out;
+	recv done;
+def w(out, done, mu):
+	lock mu;
+	tau;
+	unlock mu;
+	send out;
+	close done;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		formula  string
+		violated bool
+	}{
+		{"F close(done)", false},
+		{"G !(held(mu) && send(out))", false},
+		{"F terminated", false},
+		{"G (lock(mu) -> F unlock(mu))", false},
+		{"!close(done) U send(out)", false},
+		{"G !close(done)", true},
+		{"G !held(mu)", true},
+		{"F send(done)", true},
+		{"G !blocked(main.main)", true},
+	}
+	for _, test := range tests {
+		report, err := verify.LTL(prog, ltl.MustParse(test.formula), verify.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Complete {
+			t.Errorf("%s: expected complete exploration", test.formula)
+		}
+		if report.Violated != test.violated {
+			t.Errorf("%s: expected violated to be %t but got %t", test.formula, test.violated, report.Violated)
+		}
+		if report.Violated && report.Counterexample == nil {
+			t.Errorf("%s: expected counterexample", test.formula)
+		}
+	}
+}
+
+func TestLTLCounterexample(t *testing.T) {
+	// w sends on out while holding mu.
+	s := `
+def main.main():
+	let out = newchan out, 0;
+	letsync mu mutex;
+	spawn w(out, mu);
+	call loop(out);
+def loop(c):
+	recv c;
+	call loop(c);
+def w(out, mu):
+	lock mu;
+	send out;
+	unlock mu;
+	call w(out, mu);
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.LTL(prog, ltl.MustParse("G !(held(mu) && send(out))"), verify.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Violated {
+		t.Fatal("expected violation")
+	}
+	lasso := report.Counterexample
+	steps := lasso.Stem.Steps
+	if len(steps) == 0 || steps[len(steps)-1].Action != "comm" {
+		t.Errorf("expected stem to end with send out but got:\n%s", lasso)
+	}
+	if len(lasso.Cycle.Steps) == 0 {
+		t.Errorf("expected non-empty cycle but got:\n%s", lasso)
+	}
+
+	// The response property holds, as w unlocks after each send.
+	report, err = verify.LTL(prog, ltl.MustParse("G (lock(mu) -> F unlock(mu))"), verify.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Violated {
+		t.Errorf("expected no violation but got:\n%s", report.Counterexample)
+	}
+}
+
+func TestLTLTerminal(t *testing.T) {
+	// main blocks forever on recv ch, so the counterexample stutters.
+	s := `
+def main.main():
+	let ch = newchan ch, 0;
+	recv ch;
+`
+	prog, err := parser.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := verify.LTL(prog, ltl.MustParse("F terminated"), verify.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Violated {
+		t.Fatal("expected violation")
+	}
+	if s := report.Counterexample.String(); !strings.Contains(s, "blocked:\n    g0 [main.main] recv ch") {
+		t.Errorf("unexpected counterexample:\n%s", s)
+	}
+}
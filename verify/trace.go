@@ -0,0 +1,180 @@
+package verify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"strings"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// Actor is a goroutine taking part in a Step of a Trace,
+// or blocked at the end of a Trace.
+type Actor struct {
+	Goroutine int      `json:"goroutine"`     // Goroutine ID.
+	Chain     []string `json:"chain"`         // Spawn chain of def names, entry first.
+	Stmt      string   `json:"stmt"`          // MiGo statement.
+	Pos       string   `json:"pos,omitempty"` // Go source position (file:line:col).
+
+	// Cases are the guards of a blocked select with their Go source
+	// positions, e.g. "recv ch at main.go:7:2". Pos is the position of
+	// the first guard which has one.
+	Cases []string `json:"cases,omitempty"`
+}
+
+func (a Actor) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "g%d [%s] %s", a.Goroutine, strings.Join(a.Chain, " > "), a.Stmt)
+	if len(a.Cases) > 0 {
+		fmt.Fprintf(&sb, " {%s}", strings.Join(a.Cases, "; "))
+	} else if a.Pos != "" {
+		fmt.Fprintf(&sb, " at %s", a.Pos)
+	}
+	return sb.String()
+}
+
+// Step is a reduction in a Trace.
+type Step struct {
+	Action string  `json:"action"`           // Kind of reduction.
+	Object string  `json:"object,omitempty"` // Name of the object acted on.
+	Actors []Actor `json:"actors"`           // Goroutines reducing.
+}
+
+// Trace is a counterexample trace, i.e. a sequence of reductions from the
+// initial state of a program to a state violating a property, with the
+// goroutines which are blocked in the final state.
+type Trace struct {
+	Steps   []Step  `json:"steps"`
+	Blocked []Actor `json:"blocked,omitempty"`
+}
+
+// NewTrace returns the Trace of the reductions path, which ends in the
+// State final.
+func NewTrace(path []semantics.Transition, final *semantics.State) *Trace {
+	trace := &Trace{Steps: make([]Step, len(path))}
+	for i, t := range path {
+		l := t.Label
+		step := Step{Action: l.Kind.String(), Object: l.ObjName}
+		step.Actors = append(step.Actors, newActor(t.Next, l.G, l.Stmt, l.Case))
+		if l.Peer >= 0 {
+			step.Actors = append(step.Actors, newActor(t.Next, l.Peer, l.PeerStmt, l.PeerCase))
+		}
+		trace.Steps[i] = step
+	}
+	if final != nil {
+		for _, g := range final.Goroutines {
+			if !g.Terminated() {
+				trace.Blocked = append(trace.Blocked, newActor(final, g.ID, g.Next(), -1))
+			}
+		}
+	}
+	return trace
+}
+
+// newActor returns the Actor of goroutine id in state s, reducing stmt.
+func newActor(s *semantics.State, id int, stmt migo.Statement, cse int) Actor {
+	str, pos := describe(stmt, cse)
+	a := Actor{Goroutine: id, Chain: chain(s, id), Stmt: str}
+	if sel, ok := stmt.(*migo.SelectStatement); ok && cse < 0 {
+		for _, c := range sel.Cases {
+			if len(c) == 0 {
+				continue
+			}
+			guard, guardPos := describe(c[0], -1)
+			if guardPos.IsValid() {
+				guard = fmt.Sprintf("%s at %s", guard, guardPos)
+				if !pos.IsValid() {
+					pos = guardPos
+				}
+			}
+			a.Cases = append(a.Cases, guard)
+		}
+	}
+	if pos.IsValid() {
+		a.Pos = pos.String()
+	}
+	return a
+}
+
+// chain returns the spawn chain of goroutine id in s.
+func chain(s *semantics.State, id int) []string {
+	var names []string
+	for id >= 0 {
+		g := s.Goroutines[id]
+		names = append([]string{g.Root.SimpleName()}, names...)
+		id = g.Parent
+	}
+	return names
+}
+
+// describe returns a description of stmt and its Go source position.
+// If stmt is a select, cse is the case chosen.
+func describe(stmt migo.Statement, cse int) (string, token.Position) {
+	switch stmt := stmt.(type) {
+	case *migo.SendStatement:
+		return fmt.Sprintf("send %s", stmt.Chan), stmt.Pos
+	case *migo.RecvStatement:
+		return fmt.Sprintf("recv %s", stmt.Chan), stmt.Pos
+	case *migo.SelectStatement:
+		if cse >= 0 && cse < len(stmt.Cases) && len(stmt.Cases[cse]) > 0 {
+			prefix, pos := describe(stmt.Cases[cse][0], -1)
+			return fmt.Sprintf("select case %s", prefix), pos
+		}
+		return "select", token.Position{}
+	case *migo.IfStatement:
+		return describeBranch("if", cse), token.Position{}
+	case *migo.IfForStatement:
+		return describeBranch(fmt.Sprintf("ifFor (int %s)", stmt.ForCond), cse), token.Position{}
+	}
+	return stmt.String(), token.Position{}
+}
+
+func describeBranch(s string, cse int) string {
+	switch cse {
+	case 0:
+		return s + " then"
+	case 1:
+		return s + " else"
+	}
+	return s
+}
+
+// WriteText writes the trace in plain text to w.
+func (t *Trace) WriteText(w io.Writer) error {
+	var buf bytes.Buffer
+	for i, step := range t.Steps {
+		if step.Object != "" {
+			fmt.Fprintf(&buf, "%d. %s %s\n", i+1, step.Action, step.Object)
+		} else {
+			fmt.Fprintf(&buf, "%d. %s\n", i+1, step.Action)
+		}
+		for _, a := range step.Actors {
+			fmt.Fprintf(&buf, "    %s\n", a)
+		}
+	}
+	if len(t.Blocked) > 0 {
+		buf.WriteString("blocked:\n")
+		for _, a := range t.Blocked {
+			fmt.Fprintf(&buf, "    %s\n", a)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteJSON writes the trace in JSON to w.
+func (t *Trace) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+func (t *Trace) String() string {
+	var sb strings.Builder
+	_ = t.WriteText(&sb)
+	return sb.String()
+}
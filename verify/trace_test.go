@@ -0,0 +1,126 @@
+package verify_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/verify"
+)
+
+type named string
+
+func (n named) Name() string   { return string(n) }
+func (n named) String() string { return string(n) }
+
+// deadlockedProg returns a program where main.sndr blocks on a send
+// at main.go:12:5 which is never received.
+func deadlockedProg() *migo.Program {
+	prog := migo.NewProgram()
+	mainFn := migo.NewFunction("main.main")
+	mainFn.AddStmts(
+		&migo.NewChanStatement{Name: named("ch"), Chan: "main.main.ch", Size: 0},
+		&migo.NewChanStatement{Name: named("done"), Chan: "main.main.done", Size: 0},
+		&migo.SpawnStatement{Name: "main.sndr", Params: []*migo.Parameter{
+			{Caller: named("ch"), Callee: named("ch")},
+			{Caller: named("done"), Callee: named("done")},
+		}},
+		&migo.RecvStatement{Chan: "done", Pos: token.Position{Filename: "main.go", Line: 7, Column: 2}},
+	)
+	sndr := migo.NewFunction("main.sndr")
+	sndr.AddParams(
+		&migo.Parameter{Caller: named("ch"), Callee: named("ch")},
+		&migo.Parameter{Caller: named("done"), Callee: named("done")},
+	)
+	sndr.AddStmts(&migo.SendStatement{Chan: "ch", Pos: token.Position{Filename: "main.go", Line: 12, Column: 5}})
+	prog.AddFunction(mainFn)
+	prog.AddFunction(sndr)
+	return prog
+}
+
+func TestCounterexample(t *testing.T) {
+	report, err := verify.Deadlock(deadlockedProg(), verify.Options{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	trace := report.Counterexample()
+	if trace == nil {
+		t.Fatalf("expected deadlock to be found")
+	}
+	if want, got := 3, len(trace.Steps); want != got {
+		t.Errorf("expected %d steps but got %d", want, got)
+	}
+	if want, got := 2, len(trace.Blocked); want != got {
+		t.Fatalf("expected %d blocked goroutines but got %d", want, got)
+	}
+	sndr := trace.Blocked[1]
+	if want, got := "main.go:12:5", sndr.Pos; want != got {
+		t.Errorf("expected blocked send at %s but got %s", want, got)
+	}
+	if want, got := "main.main main.sndr", strings.Join(sndr.Chain, " "); want != got {
+		t.Errorf("expected spawn chain %s but got %s", want, got)
+	}
+	if want, got := "send ch", sndr.Stmt; want != got {
+		t.Errorf("expected blocked statement %q but got %q", want, got)
+	}
+	text := trace.String()
+	for _, want := range []string{"spawn", "g1 [main.main > main.sndr] send ch at main.go:12:5", "g0 [main.main] recv done at main.go:7:2"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected text trace to contain %q but got:\n%s", want, text)
+		}
+	}
+	var buf bytes.Buffer
+	if err := trace.WriteJSON(&buf); err != nil {
+		t.Error(err)
+	}
+	var decoded verify.Trace
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Error(err)
+	}
+	if want, got := len(trace.Steps), len(decoded.Steps); want != got {
+		t.Errorf("expected %d steps in JSON but got %d", want, got)
+	}
+	if want, got := "main.go:12:5", decoded.Blocked[1].Pos; want != got {
+		t.Errorf("expected blocked send at %s in JSON but got %s", want, got)
+	}
+}
+
+func TestCounterexampleSelect(t *testing.T) {
+	prog := migo.NewProgram()
+	mainFn := migo.NewFunction("main.main")
+	mainFn.AddStmts(
+		&migo.NewChanStatement{Name: named("a"), Chan: "main.main.a", Size: 0},
+		&migo.NewChanStatement{Name: named("b"), Chan: "main.main.b", Size: 0},
+		&migo.SelectStatement{Cases: [][]migo.Statement{
+			{&migo.RecvStatement{Chan: "a", Pos: token.Position{Filename: "main.go", Line: 8, Column: 7}}},
+			{&migo.SendStatement{Chan: "b", Pos: token.Position{Filename: "main.go", Line: 9, Column: 7}}},
+		}},
+	)
+	prog.AddFunction(mainFn)
+	report, err := verify.Deadlock(prog, verify.Options{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	trace := report.Counterexample()
+	if trace == nil {
+		t.Fatalf("expected deadlock to be found")
+	}
+	if want, got := 1, len(trace.Blocked); want != got {
+		t.Fatalf("expected %d blocked goroutine but got %d", want, got)
+	}
+	sel := trace.Blocked[0]
+	if want, got := "main.go:8:7", sel.Pos; want != got {
+		t.Errorf("expected blocked select at %s but got %s", want, got)
+	}
+	if want, got := "recv a at main.go:8:7; send b at main.go:9:7", strings.Join(sel.Cases, "; "); want != got {
+		t.Errorf("expected select cases %q but got %q", want, got)
+	}
+	if want := "g0 [main.main] select {recv a at main.go:8:7; send b at main.go:9:7}"; !strings.Contains(trace.String(), want) {
+		t.Errorf("expected text trace to contain %q but got:\n%s", want, trace)
+	}
+}
@@ -0,0 +1,88 @@
+// Package verify checks behavioural properties of MiGo programs by
+// exploring the state space of the program, as given by the semantics
+// package.
+//
+// Exploration starts from a single goroutine running the entry function of
+// the program, and is bounded by the number of states, the depth of the
+// exploration and time. A property holds for the program if no violation
+// is found and the exploration is complete.
+//
+// # Usage
+//
+// To check a program for global deadlocks:
+//
+//	report, err := verify.Deadlock(prog, verify.Options{Entry: "main.main"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	if report.Found {
+//		fmt.Println(report.Trace)
+//	}
+package verify
+
+import (
+	"context"
+	"time"
+
+	"github.com/JorgeGCoelho/migo/v3"
+	"github.com/JorgeGCoelho/migo/v3/internal/explore"
+	"github.com/JorgeGCoelho/migo/v3/semantics"
+)
+
+// DefaultEntry is the entry function used if none is given in Options.
+const DefaultEntry = "main.main"
+
+// Options are the options of a verification.
+type Options struct {
+	Entry     string        // Entry function, DefaultEntry if empty.
+	MaxStates int           // Maximum number of states explored, 0 for no limit.
+	MaxDepth  int           // Maximum length of traces explored, 0 for no limit.
+	Timeout   time.Duration // Maximum duration of exploration, 0 for no limit.
+	Workers   int           // Number of goroutines exploring, 1 if not positive.
+
+	// Fairness is the scheduling assumption used by Liveness.
+	Fairness Fairness
+
+	// Context cancels the exploration, context.Background() if nil.
+	// Results are reported as incomplete if the exploration is cancelled.
+	Context context.Context
+
+	// PartialOrder enables partial-order reduction, which only preserves
+	// deadlocked states, and is used by Deadlock.
+	PartialOrder bool
+	// Symmetry enables symmetry reduction over goroutines running the same
+	// function with the same bindings, and is used by Deadlock and
+	// ChannelSafety.
+	Symmetry bool
+}
+
+func (o Options) entry() string {
+	if o.Entry == "" {
+		return DefaultEntry
+	}
+	return o.Entry
+}
+
+func (o Options) explore() explore.Options {
+	return explore.Options{MaxStates: o.MaxStates, MaxDepth: o.MaxDepth, Timeout: o.Timeout, Workers: o.Workers}
+}
+
+func (o Options) context() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// reduced returns the exploration options with the reductions enabled.
+func (o Options) reduced(partialOrder, symmetry bool) explore.Options {
+	opts := o.explore()
+	opts.PartialOrder = partialOrder && o.PartialOrder
+	opts.Symmetry = symmetry && o.Symmetry
+	return opts
+}
+
+// initial returns the initial state of prog given opts.
+func initial(prog *migo.Program, opts Options) (*semantics.State, error) {
+	return semantics.Initial(prog, opts.entry())
+}